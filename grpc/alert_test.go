@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+// alertReceiver serves a webhook that sends each alert it receives on the
+// returned channel.
+func alertReceiver(t *testing.T) (string, <-chan alertPayload) {
+	t.Helper()
+	got := make(chan alertPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p alertPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		got <- p
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, got
+}
+
+func TestAlertSink(t *testing.T) {
+	target, got := alertReceiver(t)
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	alert := newAlertSink(slog.Default(), target, 0.5, time.Minute, 5*time.Minute, clock)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithClock(clock), WithSink(alert))
+
+	// Below the threshold, and then too few events to judge.
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(15, 5)})
+	clock.Advance(2 * time.Minute)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(0, 10)})
+	// 20 denied of 30 in the last minute: past the threshold, so this
+	// alerts, and the denials that follow keep it firing without another.
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(10, 10)})
+	for range 3 {
+		clock.Advance(10 * time.Second)
+		svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(0, 10)})
+	}
+
+	select {
+	case p := <-got:
+		want := alertPayload{Alert: "deny_rate", DenyRate: 20.0 / 30, Threshold: 0.5, WindowSeconds: 60, Allowed: 10, Denied: 20, At: "2026-02-16T21:02:00Z"}
+		if p != want {
+			t.Errorf("expected %+v, got %+v", want, p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an alert once the denial rate crossed the threshold")
+	}
+
+	// Recovering re-arms the alert, but a new crossing within the cooldown
+	// stays quiet.
+	clock.Advance(2 * time.Minute)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(30, 0)})
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(0, 40)})
+	alert.Close()
+	select {
+	case p := <-got:
+		t.Errorf("expected a single alert within the cooldown, got another: %+v", p)
+	default:
+	}
+}
+
+func TestAlertSink_Rearms(t *testing.T) {
+	target, got := alertReceiver(t)
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	alert := newAlertSink(slog.Default(), target, 0.5, time.Minute, time.Minute, clock)
+	defer alert.Close()
+
+	for i := range 2 {
+		alert.Publish(makeEvents(0, 30))
+		select {
+		case <-got:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("crossing %d: expected an alert", i+1)
+		}
+		// Let the denials leave the window and the rate recover.
+		clock.Advance(2 * time.Minute)
+		alert.Publish(makeEvents(20, 0))
+	}
+}
+
+func TestValidAlertURL(t *testing.T) {
+	for _, target := range []string{"http://example.com/hook", "https://example.com/hook"} {
+		if err := validAlertURL(target); err != nil {
+			t.Errorf("%s: %v", target, err)
+		}
+	}
+	for _, target := range []string{"", "/hook", "ftp://example.com/hook", "http://"} {
+		if err := validAlertURL(target); err == nil {
+			t.Errorf("%q: expected an error", target)
+		}
+	}
+}
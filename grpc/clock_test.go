@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signalled when a ticker is created
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) eventcore.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time), interval: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// waitForTickers blocks until at least n tickers have been created, so a
+// test can be sure the code under test is listening before it advances.
+func (c *fakeClock) waitForTickers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.tickers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Advance moves the clock forward by d. Each running ticker that came due
+// ticks once, dropping any further ticks it missed as a time.Ticker does.
+// Ticks are delivered before Advance returns, and delivering one waits for
+// the receiver to take it, so by then the receiver has finished handling
+// all of its earlier ticks.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*fakeTicker
+	for _, t := range c.tickers {
+		if !t.stopped && !now.Before(t.next) {
+			due = append(due, t)
+			for !now.Before(t.next) {
+				t.next = t.next.Add(t.interval)
+			}
+		}
+	}
+	c.mu.Unlock()
+	for _, t := range due {
+		t.c <- now
+	}
+}
+
+type fakeTicker struct {
+	clock    *fakeClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestWithClock_RateLimit(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	// The limiter is created by an option that runs before WithClock, and
+	// must still go by the service's clock.
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithRateLimit(1, 1), WithClock(clock))
+
+	if !svc.limiter.Allow("10.0.0.1") {
+		t.Fatal("expected the first publish allowed")
+	}
+	if svc.limiter.Allow("10.0.0.1") {
+		t.Fatal("expected the burst used up")
+	}
+	clock.Advance(time.Second)
+	if !svc.limiter.Allow("10.0.0.1") {
+		t.Error("expected a token back after the fake clock advanced a second")
+	}
+}
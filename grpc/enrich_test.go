@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+func TestPathTemplateEnricher(t *testing.T) {
+	enrich, err := NewPathTemplateEnricher([]string{
+		"/users/{id}",
+		"/users/{id}/orders/{order}",
+		"/users/me/orders/{order}",
+		"/health",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ path, want string }{
+		{"/users/42", "/users/{id}"},
+		{"/users/alice", "/users/{id}"},
+		{"/users/42/orders/7", "/users/{id}/orders/{order}"},
+		// The first matching template wins.
+		{"/users/me/orders/7", "/users/{id}/orders/{order}"},
+		{"/health", "/health"},
+		{"/users/", "/users/"},
+		{"/users/42/profile", "/users/42/profile"},
+		{"/teams/42", "/teams/42"},
+	} {
+		ev := &eventsv1.UsageEvent{Key: "k", Path: tc.path}
+		enrich(ev)
+		if ev.GetPath() != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.path, tc.want, ev.GetPath())
+		}
+	}
+
+	for _, bad := range []string{"users/{id}", "/users/id-{id}", "/users/{}"} {
+		if _, err := NewPathTemplateEnricher([]string{bad}); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestPublishEvents_Enrichers(t *testing.T) {
+	templates, err := NewPathTemplateEnricher([]string{"/users/{id}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	svc := NewEventService(slog.Default(), newMemoryStore(0),
+		WithEnricher(templates),
+		WithEnricher(func(ev *eventsv1.UsageEvent) { seen = append(seen, ev.GetPath()) }),
+	)
+	if _, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+		{Key: "a", Path: "/users/1"},
+		{Key: "b", Path: "/other"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := svc.StoredEvents()
+	if len(stored) != 2 || stored[0].GetPath() != "/users/{id}" || stored[1].GetPath() != "/other" {
+		t.Errorf("expected the templated path stored, got %+v", stored)
+	}
+	if len(seen) != 2 || seen[0] != "/users/{id}" {
+		t.Errorf("expected enrichers to run in order on every event, got %v", seen)
+	}
+	plain := testService()
+	plain.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{{Key: "a", Path: "/users/1"}}})
+	if got := plain.StoredEvents()[0].GetPath(); got != "/users/1" {
+		t.Errorf("expected paths kept as sent without enrichers, got %s", got)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+// jsonEvent writes a UsageEvent in the JSON shape of the HTTP template's
+// UsageEvent, so both templates' query APIs and sinks emit the same
+// documents. The generated protobuf struct would otherwise omit every zero
+// value, dropping "allowed": false and "remaining": 0 from denials.
+type jsonEvent struct {
+	*eventsv1.UsageEvent
+}
+
+// usageEventJSON mirrors the HTTP template's UsageEvent field for field.
+// Only the optional tenant_key and request_id are omitted when empty.
+type usageEventJSON struct {
+	Allowed    bool   `json:"allowed"`
+	Key        string `json:"key"`
+	Limit      int64  `json:"limit"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Remaining  int64  `json:"remaining"`
+	RequestId  string `json:"request_id,omitempty"`
+	StatusCode int32  `json:"status_code"`
+	TenantKey  string `json:"tenant_key,omitempty"`
+	Timestamp  string `json:"timestamp"`
+}
+
+func (e jsonEvent) MarshalJSON() ([]byte, error) {
+	ev := e.UsageEvent
+	return json.Marshal(usageEventJSON{
+		Allowed:    ev.GetAllowed(),
+		Key:        ev.GetKey(),
+		Limit:      ev.GetLimit(),
+		Method:     ev.GetMethod(),
+		Path:       ev.GetPath(),
+		Remaining:  ev.GetRemaining(),
+		RequestId:  ev.GetRequestId(),
+		StatusCode: ev.GetStatusCode(),
+		TenantKey:  ev.GetTenantKey(),
+		Timestamp:  ev.GetTimestamp(),
+	})
+}
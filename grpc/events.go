@@ -3,135 +3,494 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
-	"net/http"
-	"strconv"
-	"sync"
-	"sync/atomic"
+	"strings"
 
 	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-const maxStoredEvents = 10000
+// EventStats, RateStats and RemainingStats are the bodies of GET
+// /events/stats, /events/stats/rate and /events/stats/remaining.
+type (
+	EventStats     = eventcore.EventStats
+	RateStats      = eventcore.RateStats
+	RemainingStats = eventcore.RemainingStats
+)
+
+const (
+	defaultMaxBatch = 10000
+	// defaultListLimit and defaultMaxListLimit bound the events returned by
+	// one GET /events.
+	defaultListLimit    = 100
+	defaultMaxListLimit = 1000
+)
 
-type EventStats struct {
-	TotalReceived int64 `json:"total_received"`
-	TotalAllowed  int64 `json:"total_allowed"`
-	TotalDenied   int64 `json:"total_denied"`
-	StoredEvents  int   `json:"stored_events"`
+// eventFields adapts UsageEvent to the shared query API and stores. Events
+// carry no reason, so denials aren't counted by one. The optional strings
+// are always set, as empty strings, which is how the SQLite store has
+// always written them. Stores that serialize events keep them in protobuf
+// wire format.
+var eventFields = eventcore.Fields[*eventsv1.UsageEvent]{
+	View: func(ev *eventsv1.UsageEvent) eventcore.Event {
+		tenantKey, requestID := ev.GetTenantKey(), ev.GetRequestId()
+		return eventcore.Event{
+			Key:        ev.GetKey(),
+			TenantKey:  &tenantKey,
+			Method:     ev.GetMethod(),
+			Path:       ev.GetPath(),
+			Allowed:    ev.GetAllowed(),
+			Remaining:  ev.GetRemaining(),
+			Limit:      ev.GetLimit(),
+			Timestamp:  ev.GetTimestamp(),
+			StatusCode: ev.GetStatusCode(),
+			RequestID:  &requestID,
+		}
+	},
+	Make: func(ev eventcore.Event) *eventsv1.UsageEvent {
+		return &eventsv1.UsageEvent{
+			Key:        ev.Key,
+			TenantKey:  stringValue(ev.TenantKey),
+			Method:     ev.Method,
+			Path:       ev.Path,
+			Allowed:    ev.Allowed,
+			Remaining:  ev.Remaining,
+			Limit:      ev.Limit,
+			Timestamp:  ev.Timestamp,
+			StatusCode: ev.StatusCode,
+			RequestId:  stringValue(ev.RequestID),
+		}
+	},
+	JSON: func(ev *eventsv1.UsageEvent) any { return jsonEvent{ev} },
+	ProtoBatch: func(events []*eventsv1.UsageEvent) proto.Message {
+		return &eventsv1.PublishEventsRequest{Events: events}
+	},
+	// The protobuf bytes go into JSON as base64, the format the Redis
+	// store has always used.
+	Encode: func(ev *eventsv1.UsageEvent) ([]byte, error) {
+		data, err := proto.Marshal(ev)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(data)
+	},
+	Decode: func(data []byte) (*eventsv1.UsageEvent, error) {
+		var raw []byte
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		ev := &eventsv1.UsageEvent{}
+		return ev, proto.Unmarshal(raw, ev)
+	},
+	Size: approxEventBytes,
 }
 
+func stringValue(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// EventService serves the gRPC publish path, and the query API through
+// the embedded eventcore.Service.
 type EventService struct {
+	*eventcore.Service[*eventsv1.UsageEvent]
 	eventsv1.UnimplementedEventServiceServer
+	eventstreamv1.UnimplementedEventStreamServiceServer
 
-	logger *slog.Logger
+	logger   *slog.Logger
+	storage  Store
+	health   *health.Server
+	sinks    []Sink
+	maxBatch int
+	// cfg collects the query API settings given as options until the
+	// Service is built.
+	cfg eventcore.Config
+	// limiter rate limits publishes per source; nil disables it.
+	limiter *eventcore.SourceLimiter
+	// maxFieldLen caps the length in bytes of events' string fields;
+	// events over it are rejected, or with truncateFields shortened.
+	// Zero or a negative value disables the limit.
+	maxFieldLen    int
+	truncateFields bool
+	// rawTimestamps stores event timestamps as sent instead of
+	// normalizing them to RFC 3339.
+	rawTimestamps bool
+	// schema rejects published batches whose events don't match it; nil
+	// disables validation.
+	schema *eventSchema
+	// enrich runs on each accepted event before it is stored.
+	enrich Enricher
+	// sampleRate is the fraction of published events that are stored;
+	// every event is still counted in the stats.
+	sampleRate float64
+	// replayAllow lists the hosts POST /events/replay may send to; when
+	// empty, replay is disabled.
+	replayAllow []string
 
-	mu     sync.RWMutex
-	events []*eventsv1.UsageEvent
+	clock eventcore.Clock
 
-	totalReceived atomic.Int64
-	totalAllowed  atomic.Int64
-	totalDenied   atomic.Int64
+	tracerProvider trace.TracerProvider
+	tracer         trace.Tracer
 }
 
-func NewEventService(logger *slog.Logger) *EventService {
-	return &EventService{
-		logger: logger,
-		events: make([]*eventsv1.UsageEvent, 0, 1024),
-	}
+// Sink receives every accepted batch, after it has been stored. Publish
+// must not block.
+type Sink interface {
+	Publish(batch []*eventsv1.UsageEvent)
 }
 
-func (s *EventService) PublishEvents(_ context.Context, req *eventsv1.PublishEventsRequest) (*eventsv1.PublishEventsResponse, error) {
-	batch := req.GetEvents()
-	count := int64(len(batch))
+// Option configures an EventService.
+type Option func(*EventService)
 
-	var allowed, denied int64
-	for _, ev := range batch {
-		if ev.GetAllowed() {
-			allowed++
-		} else {
-			denied++
+// WithMaxBatch caps the number of events accepted in a single publish.
+// Zero or a negative value disables the limit.
+func WithMaxBatch(n int) Option {
+	return func(s *EventService) { s.maxBatch = n }
+}
+
+// WithRateLimit limits each source, identified by its remote IP, to
+// perSecond publishes per second with bursts of up to burst. Zero or a
+// negative perSecond disables rate limiting.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(s *EventService) {
+		s.limiter = nil
+		if perSecond > 0 {
+			s.limiter = eventcore.NewSourceLimiter(perSecond, burst)
 		}
 	}
+}
 
-	s.store(batch)
-	s.totalReceived.Add(count)
-	s.totalAllowed.Add(allowed)
-	s.totalDenied.Add(denied)
+// WithListLimits sets the number of events GET /events returns when no
+// limit is given, and the largest limit it honours; larger ones are lowered
+// to it. A defaultLimit <= 0 keeps the built-in default, and a maxLimit <= 0
+// removes the ceiling.
+func WithListLimits(defaultLimit, maxLimit int) Option {
+	return func(s *EventService) {
+		if defaultLimit > 0 {
+			s.cfg.ListDefault = defaultLimit
+		}
+		s.cfg.ListMax = maxLimit
+	}
+}
 
-	s.logger.Info("events received", "count", count, "allowed", allowed, "denied", denied)
-	return &eventsv1.PublishEventsResponse{Accepted: count}, nil
+// WithListEnvelope makes GET /events answer with an envelope object
+// instead of a bare array by default. Requests can still choose with the
+// envelope parameter.
+func WithListEnvelope(enabled bool) Option {
+	return func(s *EventService) { s.cfg.ListEnvelope = enabled }
 }
 
-func (s *EventService) HandleListEvents(w http.ResponseWriter, r *http.Request) {
-	tenantFilter := r.URL.Query().Get("tenant_key")
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100
-	if limitStr != "" {
-		if n, err := strconv.Atoi(limitStr); err == nil && n > 0 {
-			limit = n
+// WithMaxFieldLength caps the length in bytes of each string field of a
+// published event at n. Events with a longer field are rejected like other
+// invalid events, or, with truncate, stored with the field shortened to n.
+// Zero or a negative n disables the limit.
+func WithMaxFieldLength(n int, truncate bool) Option {
+	return func(s *EventService) { s.maxFieldLen, s.truncateFields = n, truncate }
+}
+
+// WithTimestampNormalization controls whether published timestamps in
+// other common formats are rewritten to RFC 3339 before storage. It is on
+// by default.
+func WithTimestampNormalization(enabled bool) Option {
+	return func(s *EventService) { s.rawTimestamps = !enabled }
+}
+
+// WithSchema rejects published batches containing an event that doesn't
+// match schema.
+func WithSchema(schema *eventSchema) Option {
+	return func(s *EventService) { s.schema = schema }
+}
+
+// WithWatermarks applies backpressure as the store fills towards capacity
+// events. Once it holds a soft fraction of capacity, publishes still
+// succeed but carry a warning and a Retry-After hint; at hard, they are
+// refused until it drains. A zero fraction disables that watermark, and a
+// capacity <= 0 disables both.
+func WithWatermarks(capacity int, soft, hard float64) Option {
+	return func(s *EventService) {
+		s.cfg.Watermarks = nil
+		if capacity > 0 && (soft > 0 || hard > 0) {
+			s.cfg.Watermarks = &eventcore.Watermarks{Capacity: capacity, Soft: soft, Hard: hard}
+		}
+	}
+}
+
+// WithSampleRate stores only the fraction rate of published events,
+// chosen by a hash of each event's request ID. Every event is still
+// counted in the stats and passed to sinks and the live stream. Rates are
+// clamped to [0, 1].
+func WithSampleRate(rate float64) Option {
+	return func(s *EventService) {
+		s.sampleRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithEnricher runs e on each accepted event before it is stored. It may
+// be given more than once; enrichers run in the order given.
+func WithEnricher(e Enricher) Option {
+	return func(s *EventService) {
+		prev := s.enrich
+		s.enrich = func(ev *eventsv1.UsageEvent) {
+			prev(ev)
+			e(ev)
 		}
 	}
+}
 
-	s.mu.RLock()
-	result := make([]*eventsv1.UsageEvent, 0, min(limit, len(s.events)))
-	for i := len(s.events) - 1; i >= 0 && len(result) < limit; i-- {
-		ev := s.events[i]
-		if tenantFilter != "" && ev.GetTenantKey() != tenantFilter {
-			continue
+// WithTracerProvider records spans for publishes and queries with tp.
+// Without it spans go to the global provider, which discards them unless
+// one has been installed.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *EventService) { s.tracerProvider = tp }
+}
+
+// WithSink forwards accepted events to sink. It may be given more than once.
+func WithSink(sink Sink) Option {
+	return func(s *EventService) { s.sinks = append(s.sinks, sink) }
+}
+
+// WithReplayAllow lets POST /events/replay send events to hosts, each a
+// host name or IP, which allows any port, or a host:port. Empty entries are
+// ignored. Without any, replay is refused, since it would otherwise make
+// requests to whatever target a caller names.
+func WithReplayAllow(hosts []string) Option {
+	return func(s *EventService) {
+		for _, h := range hosts {
+			if h = strings.TrimSpace(h); h != "" {
+				s.replayAllow = append(s.replayAllow, h)
+			}
 		}
-		result = append(result, ev)
 	}
-	s.mu.RUnlock()
+}
+
+// WithClock sets the clock the service tells time by; the default is the
+// system clock.
+func WithClock(c eventcore.Clock) Option {
+	return func(s *EventService) { s.clock = c }
+}
+
+func NewEventService(logger *slog.Logger, storage Store, opts ...Option) *EventService {
+	s := &EventService{
+		logger:   logger,
+		storage:  storage,
+		health:   newHealthServer(),
+		maxBatch: defaultMaxBatch,
+		cfg: eventcore.Config{
+			ListDefault: defaultListLimit,
+			ListMax:     defaultMaxListLimit,
+		},
+		sampleRate: 1,
+		enrich:     noopEnricher,
+		clock:      eventcore.RealClock{},
 
-	writeJSON(w, http.StatusOK, result)
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cfg.Clock = s.clock
+	s.Service = eventcore.NewService(logger, storage, eventFields, s.cfg)
+	if s.limiter != nil {
+		s.limiter.Now = s.clock.Now
+	}
+	s.tracer = s.tracerProvider.Tracer(tracerName)
+	return s
 }
 
-func (s *EventService) HandleStats(w http.ResponseWriter, _ *http.Request) {
-	s.mu.RLock()
-	n := len(s.events)
-	s.mu.RUnlock()
+func (s *EventService) PublishEvents(ctx context.Context, req *eventsv1.PublishEventsRequest) (*eventsv1.PublishEventsResponse, error) {
+	if s.limiter != nil && !s.limiter.Allow(peerHost(ctx)) {
+		return nil, status.Error(codes.ResourceExhausted, "publish rate limit exceeded")
+	}
+	start := s.clock.Now()
+	batch := req.GetEvents()
+	if s.maxBatch > 0 && len(batch) > s.maxBatch {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch of %d events exceeds the maximum of %d", len(batch), s.maxBatch)
+	}
+	strict, err := strictRequested(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	dryRun, err := metadataBool(ctx, dryRunMetadata)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.truncateLongFields(batch)
+	batch, rejected := partitionValid(batch, s.maxFieldLen)
+	if len(rejected) > 0 {
+		if strict {
+			return nil, status.Errorf(codes.InvalidArgument, "%d of the batch's events are invalid: %s", len(rejected), joinRejected(rejected))
+		}
+		trailer := metadata.MD{}
+		for _, r := range rejected {
+			trailer.Append(rejectedMetadata, r.String())
+		}
+		_ = grpc.SetTrailer(ctx, trailer)
+	}
+
+	s.normalizeTimestamps(batch)
+	if s.schema != nil {
+		if problems := s.schema.validate(batch); len(problems) > 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "events do not match the schema: %s", strings.Join(problems, "; "))
+		}
+	}
+	s.enrichBatch(batch)
+	count := int64(len(batch))
+
+	tally := eventFields.Tally(batch)
+	allowed, denied := tally.Allowed, tally.Denied
+
+	// A dry run stops here: the batch has been validated and counted, and
+	// nothing is stored, counted in the stats or passed on.
+	if dryRun {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(dryRunMetadata, "true"))
+		s.logger.Info("dry run", "count", count, "allowed", allowed, "denied", denied, "rejected", len(rejected))
+		return &eventsv1.PublishEventsResponse{Accepted: count}, nil
+	}
+
+	full, warning := s.CheckWatermarks(ctx)
+	if full || warning != "" {
+		md := metadata.Pairs("retry-after", eventcore.WatermarkRetryAfter)
+		if warning != "" {
+			md.Append("warning", warning)
+		}
+		_ = grpc.SetHeader(ctx, md)
+	}
+	if full {
+		return nil, status.Error(codes.Unavailable, "event store is full, retry later")
+	}
 
-	writeJSON(w, http.StatusOK, EventStats{
-		TotalReceived: s.totalReceived.Load(),
-		TotalAllowed:  s.totalAllowed.Load(),
-		TotalDenied:   s.totalDenied.Load(),
-		StoredEvents:  n,
+	err = s.Core().Record(tally, func() error {
+		return s.appendTraced(ctx, s.sample(batch), tally)
 	})
+	if err != nil {
+		if errors.Is(err, eventcore.ErrQueueFull) {
+			return nil, status.Error(codes.Unavailable, "event queue is full, retry later")
+		}
+		s.logger.Error("failed to store events", "error", err)
+		return nil, status.Error(codes.Internal, "failed to store events")
+	}
+	s.ObservePublish(s.clock.Now().Sub(start))
+	if len(batch) > 0 {
+		s.SetLastEventAt(batch[len(batch)-1].GetTimestamp())
+	}
+
+	s.Broadcast(batch)
+	for _, sink := range s.sinks {
+		sink.Publish(batch)
+	}
+
+	s.logEvents(ctx, batch)
+	s.logger.Info("events received", "count", count, "allowed", allowed, "denied", denied)
+	return &eventsv1.PublishEventsResponse{Accepted: count}, nil
 }
 
-func (s *EventService) HandleClearEvents(w http.ResponseWriter, _ *http.Request) {
-	s.mu.Lock()
-	s.events = s.events[:0]
-	s.mu.Unlock()
-	s.totalReceived.Store(0)
-	s.totalAllowed.Store(0)
-	s.totalDenied.Store(0)
+// peerHost returns the remote IP of the gRPC caller, or "" if unknown.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return eventcore.SourceHost(p.Addr.String())
+}
 
-	s.logger.Info("events cleared")
-	w.WriteHeader(http.StatusNoContent)
+// appendTraced stores batch, counted as t, inside a span, and annotates the
+// request's span with the batch's decision counts.
+func (s *EventService) appendTraced(ctx context.Context, batch []*eventsv1.UsageEvent, t eventcore.Tally) error {
+	attrs := []attribute.KeyValue{
+		attribute.Int("events.count", len(batch)),
+		attribute.Int64("events.allowed", t.Allowed),
+		attribute.Int64("events.denied", t.Denied),
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	ctx, span := s.tracer.Start(ctx, "Store.Append", trace.WithAttributes(attrs[0]))
+	defer span.End()
+	err := s.Core().Append(ctx, batch, t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "failed to store events")
+	}
+	return err
 }
 
-func (s *EventService) store(batch []*eventsv1.UsageEvent) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.events = append(s.events, batch...)
-	if len(s.events) > maxStoredEvents {
-		excess := len(s.events) - maxStoredEvents
-		s.events = s.events[excess:]
+// logEvents logs each event of an accepted batch at debug level.
+func (s *EventService) logEvents(ctx context.Context, batch []*eventsv1.UsageEvent) {
+	if !s.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	for _, ev := range batch {
+		s.logger.LogAttrs(ctx, slog.LevelDebug, "event received",
+			slog.String("key", ev.GetKey()),
+			slog.String("tenant_key", ev.GetTenantKey()),
+			slog.String("method", ev.GetMethod()),
+			slog.String("path", ev.GetPath()),
+			slog.Bool("allowed", ev.GetAllowed()),
+			slog.Int64("remaining", ev.GetRemaining()),
+			slog.Int64("limit", ev.GetLimit()),
+			slog.String("request_id", ev.GetRequestId()),
+		)
 	}
 }
 
-func (s *EventService) StoredEvents() []*eventsv1.UsageEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	out := make([]*eventsv1.UsageEvent, len(s.events))
-	copy(out, s.events)
-	return out
+// SubscribeEvents streams each newly accepted event to the caller until it
+// disconnects. Events are skipped, not queued without bound, when the
+// subscriber falls behind.
+func (s *EventService) SubscribeEvents(req *eventstreamv1.SubscribeEventsRequest, stream grpc.ServerStreamingServer[eventsv1.UsageEvent]) error {
+	sub := s.Subscribe(eventcore.Filter{TenantKey: req.GetTenantKey()}, true)
+	defer s.Unsubscribe(sub)
+
+	s.logger.Info("subscriber connected", "tenant_key", req.GetTenantKey())
+	defer s.logger.Info("subscriber disconnected", "tenant_key", req.GetTenantKey())
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-sub.Events:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
 }
 
-func writeJSON(w http.ResponseWriter, code int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(v)
+// PublishEventsStream handles each batch the caller sends as PublishEvents
+// would, and once the caller closes its side answers with the total number
+// of events accepted. A batch PublishEvents rejects ends the call with its
+// error; the batches accepted before it stay stored.
+func (s *EventService) PublishEventsStream(stream grpc.ClientStreamingServer[eventsv1.PublishEventsRequest, eventsv1.PublishEventsResponse]) error {
+	var accepted int64
+	var batches int
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.logger.Info("event stream closed", "batches", batches, "accepted", accepted)
+			return stream.SendAndClose(&eventsv1.PublishEventsResponse{Accepted: accepted})
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.PublishEvents(stream.Context(), req)
+		if err != nil {
+			s.logger.Warn("event stream ended by a rejected batch", "batches", batches, "accepted", accepted, "error", err)
+			return err
+		}
+		accepted += resp.GetAccepted()
+		batches++
+	}
 }
@@ -1,18 +1,86 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// eventsPage and eventsEnvelope are the JSON bodies of GET /events when
+// paging with a cursor and when an envelope is asked for.
+type (
+	eventsPage     = eventcore.EventsPage[jsonEvent]
+	eventsEnvelope = eventcore.EventsEnvelope[jsonEvent]
 )
 
 func testService() *EventService {
-	return NewEventService(slog.Default())
+	return NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents))
+}
+
+// dialBufconn serves svc on an in-memory listener and returns a client
+// connection to it.
+func dialBufconn(t *testing.T, svc *EventService, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	_, conn := serveBufconn(t, svc, opts...)
+	return conn
+}
+
+// serveBufconn is dialBufconn that also returns the server, for tests that
+// stop it themselves.
+func serveBufconn(t *testing.T, svc *EventService, opts ...grpc.ServerOption) (*grpc.Server, *grpc.ClientConn) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	eventsv1.RegisterEventServiceServer(srv, svc)
+	eventstreamv1.RegisterEventStreamServiceServer(srv, svc)
+	healthpb.RegisterHealthServer(srv, svc.health)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
 }
 
 func makeEvents(allowed, denied int) []*eventsv1.UsageEvent {
@@ -59,14 +127,14 @@ func TestPublishEvents_SingleBatch(t *testing.T) {
 	if resp.Accepted != 5 {
 		t.Errorf("expected accepted=5, got %d", resp.Accepted)
 	}
-	if svc.totalReceived.Load() != 5 {
-		t.Errorf("expected totalReceived=5, got %d", svc.totalReceived.Load())
+	if svc.Core().Totals().Received != 5 {
+		t.Errorf("expected totalReceived=5, got %d", svc.Core().Totals().Received)
 	}
-	if svc.totalAllowed.Load() != 3 {
-		t.Errorf("expected totalAllowed=3, got %d", svc.totalAllowed.Load())
+	if svc.Core().Totals().Allowed != 3 {
+		t.Errorf("expected totalAllowed=3, got %d", svc.Core().Totals().Allowed)
 	}
-	if svc.totalDenied.Load() != 2 {
-		t.Errorf("expected totalDenied=2, got %d", svc.totalDenied.Load())
+	if svc.Core().Totals().Denied != 2 {
+		t.Errorf("expected totalDenied=2, got %d", svc.Core().Totals().Denied)
 	}
 }
 
@@ -80,8 +148,8 @@ func TestPublishEvents_MultipleBatches(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
-	if svc.totalReceived.Load() != 9 {
-		t.Errorf("expected totalReceived=9, got %d", svc.totalReceived.Load())
+	if svc.Core().Totals().Received != 9 {
+		t.Errorf("expected totalReceived=9, got %d", svc.Core().Totals().Received)
 	}
 	stored := svc.StoredEvents()
 	if len(stored) != 9 {
@@ -102,6 +170,43 @@ func TestPublishEvents_EmptyBatch(t *testing.T) {
 	}
 }
 
+func TestPublishEvents_MaxBatch(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxBatch(5))
+
+	_, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 2)})
+	if err != nil {
+		t.Fatalf("expected batch at the limit to be accepted, got %v", err)
+	}
+
+	_, err = svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(4, 2)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted above the limit, got %v", err)
+	}
+	if got := len(svc.StoredEvents()); got != 5 {
+		t.Errorf("expected rejected batch not to be stored, got %d stored events", got)
+	}
+	if got := svc.Core().Totals().Received; got != 5 {
+		t.Errorf("expected totalReceived=5, got %d", got)
+	}
+}
+
+func TestPublishEvents_MaxEvents(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(5))
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(4, 0)})
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(0, 4)})
+
+	stored := svc.StoredEvents()
+	if len(stored) != 5 {
+		t.Fatalf("expected the store to trim to 5 events, got %d", len(stored))
+	}
+	if !stored[0].Allowed || stored[1].Allowed {
+		t.Errorf("expected the oldest events to be trimmed first")
+	}
+	if got := svc.Core().Totals().Received; got != 8 {
+		t.Errorf("expected trimming not to affect totalReceived, got %d", got)
+	}
+}
+
 func TestPublishEvents_EventFieldsPreserved(t *testing.T) {
 	svc := testService()
 	_, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{
@@ -136,6 +241,124 @@ func TestPublishEvents_EventFieldsPreserved(t *testing.T) {
 	}
 }
 
+func TestSubscribeEvents(t *testing.T) {
+	svc := testService()
+	conn := dialBufconn(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := eventstreamv1.NewEventStreamServiceClient(conn).SubscribeEvents(ctx, &eventstreamv1.SubscribeEventsRequest{TenantKey: "tenant-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return svc.Subscribers() == 1 })
+
+	_, err = eventsv1.NewEventServiceClient(conn).PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-b", Method: "GET", Path: "/", Allowed: true, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-a", Method: "GET", Path: "/", Allowed: true, Timestamp: "t2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.GetKey() != "k2" || ev.GetTenantKey() != "tenant-a" {
+		t.Errorf("expected tenant-a event k2, got %v", ev)
+	}
+
+	cancel()
+	waitFor(t, func() bool { return svc.Subscribers() == 0 })
+}
+
+func TestPublishEventsStream(t *testing.T) {
+	svc := testService()
+	conn := dialBufconn(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := eventstreamv1.NewEventStreamServiceClient(conn).PublishEventsStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := [][]*eventsv1.UsageEvent{makeEvents(2, 1), makeEvents(4, 0), {}, makeEvents(0, 3)}
+	for _, batch := range batches {
+		if err := stream.Send(&eventsv1.PublishEventsRequest{Events: batch}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != 10 {
+		t.Errorf("expected 10 events accepted across the batches, got %d", resp.GetAccepted())
+	}
+	if n := len(svc.StoredEvents()); n != 10 {
+		t.Errorf("expected 10 stored events, got %d", n)
+	}
+	if allowed, denied := svc.Core().Totals().Allowed, svc.Core().Totals().Denied; allowed != 6 || denied != 4 {
+		t.Errorf("expected 6 allowed and 4 denied counted, got %d and %d", allowed, denied)
+	}
+}
+
+func TestPublishEventsStream_RejectedBatch(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxBatch(3))
+	conn := dialBufconn(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := eventstreamv1.NewEventStreamServiceClient(conn).PublishEventsStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	stream.Send(&eventsv1.PublishEventsRequest{Events: makeEvents(4, 0)})
+	_, err = stream.CloseAndRecv()
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted for the oversized batch, got %v", err)
+	}
+	if n := len(svc.StoredEvents()); n != 3 {
+		t.Errorf("expected the batch before the rejected one to stay stored, got %d events", n)
+	}
+}
+
+func TestPublishEvents_DebugLogs(t *testing.T) {
+	eventRecords := func(level slog.Level) []slog.Record {
+		capture := &captureHandler{level: level}
+		svc := NewEventService(slog.New(capture), newMemoryStore(defaultMaxEvents))
+		svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+		var out []slog.Record
+		for _, r := range capture.records {
+			if r.Message == "event received" {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+
+	if got := eventRecords(slog.LevelInfo); len(got) != 0 {
+		t.Errorf("expected no per-event records at info level, got %d", len(got))
+	}
+	got := eventRecords(slog.LevelDebug)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 per-event records at debug level, got %d", len(got))
+	}
+	attrs := recordAttrs(got[2])
+	if attrs["key"].String() != "10.0.0.1" || attrs["tenant_key"].String() != "tenant-1" ||
+		attrs["method"].String() != "POST" || attrs["allowed"].Bool() ||
+		attrs["limit"].Int64() != 100 || attrs["request_id"].String() != "req-denied-a" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	for _, k := range []string{"path", "remaining"} {
+		if _, ok := attrs[k]; !ok {
+			t.Errorf("missing %s attribute", k)
+		}
+	}
+}
+
 func TestListEvents_Empty(t *testing.T) {
 	svc := testService()
 	req := httptest.NewRequest("GET", "/events", nil)
@@ -156,7 +379,7 @@ func TestListEvents_Empty(t *testing.T) {
 
 func TestListEvents_WithFilter(t *testing.T) {
 	svc := testService()
-	svc.store([]*eventsv1.UsageEvent{
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
 		{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, Timestamp: "t1"},
 		{Key: "k2", TenantKey: "tenant-b", Method: "GET", Path: "/b", Allowed: true, Timestamp: "t2"},
 		{Key: "k3", TenantKey: "tenant-a", Method: "POST", Path: "/a", Allowed: false, Timestamp: "t3"},
@@ -175,13 +398,247 @@ func TestListEvents_WithFilter(t *testing.T) {
 	}
 }
 
+func TestListEvents_AllowedFilter(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-b", Method: "GET", Path: "/b", Allowed: false, Timestamp: "t2"},
+		{Key: "k3", TenantKey: "tenant-a", Method: "POST", Path: "/a", Allowed: false, Timestamp: "t3"},
+	})
+
+	req := httptest.NewRequest("GET", "/events?allowed=false", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	var events []*eventsv1.UsageEvent
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 denied events, got %d", len(events))
+	}
+	for _, ev := range events {
+		if ev.GetAllowed() {
+			t.Errorf("expected only denied events, got %+v", ev)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/events?tenant_key=tenant-a&allowed=false", nil)
+	w = httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	events = nil
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].GetKey() != "k3" {
+		t.Errorf("expected only k3 for tenant-a denied, got %+v", events)
+	}
+}
+
+func TestListEvents_InvalidAllowed(t *testing.T) {
+	svc := testService()
+	req := httptest.NewRequest("GET", "/events?allowed=maybe", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var resp eventcore.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == "" {
+		t.Error("expected error message in response")
+	}
+}
+
+func TestListEvents_MethodAndPathPrefix(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-a", Method: "POST", Path: "/api/v1/billing/invoices", Allowed: true, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-a", Method: "GET", Path: "/api/v1/billing/invoices", Allowed: true, Timestamp: "t2"},
+		{Key: "k3", TenantKey: "tenant-b", Method: "post", Path: "/api/v1/billing", Allowed: false, Timestamp: "t3"},
+		{Key: "k4", TenantKey: "tenant-a", Method: "POST", Path: "/api/v1/users", Allowed: true, Timestamp: "t4"},
+		{Key: "k5", TenantKey: "tenant-a", Method: "POST", Path: "/api/v1/billing/refunds", Allowed: false, Timestamp: "t5"},
+	})
+
+	req := httptest.NewRequest("GET", "/events?method=Post&path_prefix=/api/v1/billing", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	var events []*eventsv1.UsageEvent
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"k5", "k3", "k1"}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, k := range want {
+		if events[i].GetKey() != k {
+			t.Errorf("events[%d]: expected %s, got %s", i, k, events[i].GetKey())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/events?method=POST&path_prefix=/api/v1/billing&tenant_key=tenant-a&limit=1", nil)
+	w = httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	events = nil
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].GetKey() != "k5" {
+		t.Errorf("expected only k5, got %+v", events)
+	}
+}
+
+func TestListEvents_StatusRange(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, StatusCode: 200, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: false, StatusCode: 429, Timestamp: "t2"},
+		{Key: "k3", TenantKey: "tenant-b", Method: "GET", Path: "/a", Allowed: true, StatusCode: 503, Timestamp: "t3"},
+		{Key: "k4", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, StatusCode: 500, Timestamp: "t4"},
+		{Key: "k5", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, StatusCode: 204, Timestamp: "t5"},
+	})
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"status_min=500&status_max=599", []string{"k4", "k3"}},
+		{"status_min=200&status_max=429", []string{"k5", "k2", "k1"}},
+		{"status_min=429", []string{"k4", "k3", "k2"}},
+		{"status_max=204", []string{"k5", "k1"}},
+		{"status_min=429&status_max=429", []string{"k2"}},
+		{"status_min=200&status_max=599&tenant_key=tenant-a&allowed=true", []string{"k5", "k4", "k1"}},
+	} {
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+tc.query, nil))
+
+		var events []*eventsv1.UsageEvent
+		json.NewDecoder(w.Body).Decode(&events)
+		var got []string
+		for _, ev := range events {
+			got = append(got, ev.GetKey())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.query, tc.want, got)
+		}
+	}
+
+	for _, query := range []string{"status_min=500&status_max=499", "status_min=abc", "status_max=99999999999"} {
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListEvents_Query(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/api/v1/Billing", RequestId: "req-123", Allowed: true, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-b", Method: "GET", Path: "/api/v1/users", RequestId: "req-456", Allowed: false, Timestamp: "t2"},
+		{Key: "k3", TenantKey: "tenant-a", Method: "POST", Path: "/api/v1/users", RequestId: "REQ-789", Allowed: true, Timestamp: "t3"},
+	})
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"q=billing", []string{"k1"}},
+		{"q=req-789", []string{"k3"}},
+		{"q=tenant-b", []string{"k2"}},
+		{"q=REQ-&method=get", []string{"k2", "k1"}},
+		{"q=nothing", nil},
+	} {
+		req := httptest.NewRequest("GET", "/events?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, ev := range events {
+			keys = append(keys, ev.Key)
+		}
+		if !reflect.DeepEqual(keys, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.query, tc.want, keys)
+		}
+	}
+}
+
+func TestListEvents_TimeRange(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T20:59:59Z"},
+		{Key: "k2", Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"},
+		{Key: "k3", Method: "GET", Path: "/", Allowed: true, Timestamp: "not-a-timestamp"},
+		{Key: "k4", Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T21:30:00Z"},
+		{Key: "k5", Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T22:00:00Z"},
+		{Key: "k6", Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T22:00:01Z"},
+	})
+
+	req := httptest.NewRequest("GET", "/events?since=2026-02-16T21:00:00Z&until=2026-02-16T22:00:00Z", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var events []*eventsv1.UsageEvent
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"k5", "k4", "k2"}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, k := range want {
+		if events[i].GetKey() != k {
+			t.Errorf("events[%d]: expected %s, got %s", i, k, events[i].GetKey())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/events?since=2026-02-16T22:00:00Z", nil)
+	w = httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+
+	events = nil
+	if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("expected 2 events since 22:00, got %d", len(events))
+	}
+}
+
+func TestListEvents_InvalidTimeRange(t *testing.T) {
+	svc := testService()
+	for _, q := range []string{"since=yesterday", "until=2026-02-16"} {
+		req := httptest.NewRequest("GET", "/events?"+q, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+}
+
 func TestListEvents_WithLimit(t *testing.T) {
 	svc := testService()
 	batch := make([]*eventsv1.UsageEvent, 10)
 	for i := range batch {
 		batch[i] = &eventsv1.UsageEvent{Key: "k", TenantKey: "t", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}
 	}
-	svc.store(batch)
+	svc.storage.Append(context.Background(), batch)
 
 	req := httptest.NewRequest("GET", "/events?limit=3", nil)
 	w := httptest.NewRecorder()
@@ -196,43 +653,988 @@ func TestListEvents_WithLimit(t *testing.T) {
 	}
 }
 
-func TestStats(t *testing.T) {
+func TestListEvents_TotalCount(t *testing.T) {
 	svc := testService()
-	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{
-		Events: makeEvents(5, 3),
-	})
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(5, 3)})
 
-	req := httptest.NewRequest("GET", "/events/stats", nil)
+	for _, tc := range []struct {
+		query    string
+		total    string
+		returned int
+	}{
+		{"limit=2", "8", 2},
+		{"allowed=false&limit=1", "3", 1},
+		{"offset=6&limit=5", "8", 2},
+		{"sort=timestamp_asc&limit=2", "8", 2},
+		{"tenant_key=nobody", "0", 0},
+	} {
+		req := httptest.NewRequest("GET", "/events?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		if got := w.Header().Get("X-Total-Count"); got != tc.total {
+			t.Errorf("%s: expected X-Total-Count %s, got %q", tc.query, tc.total, got)
+		}
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != tc.returned {
+			t.Errorf("%s: expected %d events, got %d", tc.query, tc.returned, len(events))
+		}
+	}
+}
+
+func TestListEvents_LimitClamping(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithListLimits(3, 5))
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(10, 0)})
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", 3},
+		{"limit=4", 4},
+		{"limit=5", 5},
+		{"limit=10000000", 5},
+		{"limit=0", 3},
+		{"limit=-1", 3},
+		{"limit=abc", 3},
+		{"limit=100&sort=timestamp_asc", 5},
+	} {
+		req := httptest.NewRequest("GET", "/events?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		if got := w.Header().Get("X-Applied-Limit"); got != strconv.Itoa(tc.want) {
+			t.Errorf("%q: expected X-Applied-Limit %d, got %q", tc.query, tc.want, got)
+		}
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != tc.want {
+			t.Errorf("%q: expected %d events, got %d", tc.query, tc.want, len(events))
+		}
+	}
+
+	unlimited := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithListLimits(0, 0))
+	if got := eventcore.ListLimit("", unlimited.cfg.ListDefault, unlimited.cfg.ListMax); got != defaultListLimit {
+		t.Errorf("expected the built-in default limit, got %d", got)
+	}
+	if got := eventcore.ListLimit("10000000", unlimited.cfg.ListDefault, unlimited.cfg.ListMax); got != 10000000 {
+		t.Errorf("expected no ceiling with a max of 0, got %d", got)
+	}
+}
+
+func TestListEvents_Gzip(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(50, 0))
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
 	w := httptest.NewRecorder()
-	svc.HandleStats(w, req)
+	svc.HandleListEvents(w, req)
 
-	var stats EventStats
-	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("expected gzip response, got Content-Encoding %q", got)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
 		t.Fatal(err)
 	}
-	if stats.TotalReceived != 8 || stats.TotalAllowed != 5 || stats.TotalDenied != 3 || stats.StoredEvents != 8 {
-		t.Errorf("unexpected stats: %+v", stats)
+	var events []*eventsv1.UsageEvent
+	if err := json.NewDecoder(gz).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 50 {
+		t.Errorf("expected 50 events, got %d", len(events))
+	}
+
+	req = httptest.NewRequest("GET", "/events?limit=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected small response to be uncompressed, got %q", got)
 	}
 }
 
-func TestClearEvents(t *testing.T) {
+func TestListEvents_Offset(t *testing.T) {
 	svc := testService()
-	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{
-		Events: makeEvents(3, 0),
-	})
+	batch := make([]*eventsv1.UsageEvent, 10)
+	for i := range batch {
+		batch[i] = &eventsv1.UsageEvent{Key: strconv.Itoa(i), Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}
+	}
+	svc.storage.Append(context.Background(), batch)
 
-	req := httptest.NewRequest("DELETE", "/events", nil)
-	w := httptest.NewRecorder()
-	svc.HandleClearEvents(w, req)
+	var seen []string
+	for offset := 0; offset < 12; offset += 3 {
+		req := httptest.NewRequest("GET", "/events?limit=3&offset="+strconv.Itoa(offset), nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
 
-	if w.Code != http.StatusNoContent {
-		t.Fatalf("expected 204, got %d", w.Code)
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range events {
+			seen = append(seen, ev.GetKey())
+		}
 	}
-	if len(svc.StoredEvents()) != 0 {
-		t.Error("expected 0 events after clear")
+
+	want := []string{"9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d events across pages, got %d: %v", len(want), len(seen), seen)
 	}
-	if svc.totalReceived.Load() != 0 {
-		t.Error("expected totalReceived=0 after clear")
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("position %d: expected %s, got %s", i, want[i], seen[i])
+		}
+	}
+}
+
+func TestListEvents_InvalidOffset(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(3, 0))
+
+	for _, offset := range []string{"-2", "abc"} {
+		req := httptest.NewRequest("GET", "/events?offset="+offset, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 {
+			t.Errorf("offset=%s: expected 3 events, got %d", offset, len(events))
+		}
+	}
+}
+
+func TestListEvents_Cursor(t *testing.T) {
+	svc := testService()
+	batch := make([]*eventsv1.UsageEvent, 10)
+	for i := range batch {
+		batch[i] = &eventsv1.UsageEvent{Key: strconv.Itoa(i), Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}
+	}
+	svc.storage.Append(context.Background(), batch)
+
+	listPage := func(cursor string) eventsPage {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/events?limit=4&cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		var page eventsPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	var seen []string
+	page := listPage("")
+	for {
+		for _, ev := range page.Events {
+			seen = append(seen, ev.GetKey())
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		// Events arriving between pages must not shift the next page.
+		svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{&eventsv1.UsageEvent{Key: "new", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}})
+		page = listPage(page.NextCursor)
+	}
+
+	want := []string{"9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d events across pages, got %d: %v", len(want), len(seen), seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("position %d: expected %s, got %s", i, want[i], seen[i])
+		}
+	}
+}
+
+func TestListEvents_StaleCursorRestarts(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{&eventsv1.UsageEvent{Key: "a", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}, &eventsv1.UsageEvent{Key: "b", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}, &eventsv1.UsageEvent{Key: "c", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}})
+
+	req := httptest.NewRequest("GET", "/events?limit=1&cursor=", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+	var page eventsPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected next_cursor")
+	}
+
+	svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{&eventsv1.UsageEvent{Key: "x", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}, &eventsv1.UsageEvent{Key: "y", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"}})
+
+	req = httptest.NewRequest("GET", "/events?limit=1&cursor="+page.NextCursor, nil)
+	w = httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+	page = eventsPage{}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Events) != 1 || page.Events[0].GetKey() != "y" {
+		t.Errorf("expected stale cursor to restart at newest event y, got %+v", page.Events)
+	}
+}
+
+func TestListEvents_InvalidCursor(t *testing.T) {
+	svc := testService()
+	req := httptest.NewRequest("GET", "/events?cursor=!!!", nil)
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestListEvents_SortByTimestamp(t *testing.T) {
+	svc := testService()
+	for _, e := range []struct{ key, ts string }{
+		{"a", "2026-02-16T21:00:02Z"},
+		{"b", "2026-02-16T21:00:00Z"},
+		{"c", "not-a-timestamp"},
+		{"d", "2026-02-16T21:00:01Z"},
+		{"e", "2026-02-16T21:00:00Z"},
+	} {
+		key, ts := e.key, e.ts
+		svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{&eventsv1.UsageEvent{Key: key, Method: "GET", Path: "/", Timestamp: ts}})
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d", query, w.Code)
+		}
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		keys := make([]string, len(events))
+		for i, ev := range events {
+			keys[i] = ev.Key
+		}
+		return keys
+	}
+
+	for query, want := range map[string][]string{
+		"":                                    {"e", "d", "c", "b", "a"},
+		"sort=timestamp_desc":                 {"a", "d", "e", "b", "c"},
+		"sort=timestamp_asc":                  {"b", "e", "d", "a", "c"},
+		"sort=timestamp_desc&limit=2":         {"a", "d"},
+		"sort=timestamp_asc&offset=1&limit=2": {"e", "d"},
+	} {
+		if got := list(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expected %v, got %v", query, want, got)
+		}
+	}
+}
+
+func TestListEvents_InvalidSort(t *testing.T) {
+	svc := testService()
+	for _, query := range []string{"sort=key", "sort=timestamp_asc&cursor="} {
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListEvents_Order(t *testing.T) {
+	svc := testService()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{{Key: key, Method: "GET", Path: "/", Allowed: key != "c"}})
+	}
+
+	list := func(query string) ([]string, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d", query, w.Code)
+		}
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		keys := make([]string, len(events))
+		for i, ev := range events {
+			keys[i] = ev.Key
+		}
+		return keys, w.Header().Get("X-Total-Count")
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+		total string
+	}{
+		{"", []string{"e", "d", "c", "b", "a"}, "5"},
+		{"order=desc&limit=2", []string{"e", "d"}, "5"},
+		{"order=asc", []string{"a", "b", "c", "d", "e"}, "5"},
+		{"order=asc&limit=2", []string{"a", "b"}, "5"},
+		{"order=asc&offset=1&limit=2", []string{"b", "c"}, "5"},
+		{"order=asc&offset=3", []string{"d", "e"}, "5"},
+		{"order=asc&allowed=true&limit=3", []string{"a", "b", "d"}, "4"},
+	} {
+		got, total := list(tc.query)
+		if !reflect.DeepEqual(got, tc.want) || total != tc.total {
+			t.Errorf("%q: expected %v of %s, got %v of %s", tc.query, tc.want, tc.total, got, total)
+		}
+	}
+
+	for _, query := range []string{"order=oldest", "order=asc&cursor=", "order=asc&sort=timestamp_asc", "order=desc&sort=timestamp_desc"} {
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListEvents_Envelope(t *testing.T) {
+	type envelope struct {
+		Events []json.RawMessage `json:"events"`
+		Count  int               `json:"count"`
+		Total  int               `json:"total"`
+		Limit  int               `json:"limit"`
+		Offset int               `json:"offset"`
+	}
+	list := func(svc *EventService, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	svc := testService()
+	if body := strings.TrimSpace(list(svc, "").Body.String()); body != "[]" {
+		t.Errorf("expected a bare empty array by default, got %s", body)
+	}
+	var env envelope
+	body := list(svc, "envelope=true").Body.String()
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"events":[]`) || env.Count != 0 || env.Total != 0 {
+		t.Errorf("expected an envelope around no events, got %s", body)
+	}
+
+	svc.storage.Append(context.Background(), makeEvents(3, 2))
+	for _, query := range []string{"envelope=true&limit=2&offset=1", "envelope=1&limit=2&offset=1&sort=timestamp_asc"} {
+		env = envelope{}
+		if err := json.NewDecoder(list(svc, query).Body).Decode(&env); err != nil {
+			t.Fatal(err)
+		}
+		if len(env.Events) != 2 || env.Count != 2 || env.Total != 5 || env.Limit != 2 || env.Offset != 1 {
+			t.Errorf("%q: expected 2 of 5 events at limit 2 offset 1, got %d events and %+v", query, len(env.Events), env)
+		}
+	}
+
+	svc = NewEventService(slog.Default(), svc.storage, WithListEnvelope(true))
+	env = envelope{}
+	if err := json.NewDecoder(list(svc, "").Body).Decode(&env); err != nil || env.Total != 5 {
+		t.Errorf("expected WithListEnvelope to wrap responses by default, got %+v (%v)", env, err)
+	}
+	var events []json.RawMessage
+	if err := json.NewDecoder(list(svc, "envelope=false").Body).Decode(&events); err != nil || len(events) != 5 {
+		t.Errorf("expected envelope=false to return a bare array, got %d events (%v)", len(events), err)
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?envelope=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("envelope=maybe: expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "old", Method: "GET", Path: "/a", Allowed: true, Timestamp: "2026-02-16T21:00:00Z", RequestId: "req-1"},
+		{Key: "other", Method: "GET", Path: "/b", Allowed: true, Timestamp: "2026-02-16T21:00:01Z", RequestId: "req-2"},
+		{Key: "new", Method: "POST", Path: "/a", Allowed: false, Timestamp: "2026-02-16T21:00:02Z", RequestId: "req-1"},
+		{Key: "anonymous", Method: "GET", Path: "/c", Allowed: true, Timestamp: "2026-02-16T21:00:03Z"},
+	})
+	mux := newMux(svc)
+
+	for id, want := range map[string]string{"req-1": "new", "req-2": "other"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/events/"+id, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", id, w.Code, w.Body.String())
+		}
+		var ev eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.GetKey() != want {
+			t.Errorf("%s: expected event %q, got %q", id, want, ev.GetKey())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/events/req-missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown request ID, got %d", w.Code)
+	}
+
+	// Fixed routes under /events still take precedence over the lookup.
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/events/count", nil))
+	var count eventcore.CountResponse
+	if err := json.NewDecoder(w.Body).Decode(&count); err != nil || count.Count != 4 {
+		t.Errorf("expected /events/count to count 4 events, got %+v (err=%v)", count, err)
+	}
+}
+
+func TestCountEvents(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(5, 3))
+	svc.storage.Append(context.Background(), makeEvents(2, 0))
+
+	for _, query := range []string{
+		"",
+		"allowed=false",
+		"method=post",
+		"tenant_key=tenant-1&allowed=true",
+		"tenant_key=nobody",
+		"since=2026-02-16T21:00:01Z",
+	} {
+		w := httptest.NewRecorder()
+		svc.HandleCountEvents(w, httptest.NewRequest("GET", "/events/count?"+query, nil))
+		var got eventcore.CountResponse
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?limit=1000&"+query, nil))
+		var events []*eventsv1.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if got.Count != len(events) {
+			t.Errorf("%q: count=%d but list returned %d events", query, got.Count, len(events))
+		}
+	}
+}
+
+func TestCountEvents_InvalidFilter(t *testing.T) {
+	svc := testService()
+	w := httptest.NewRecorder()
+	svc.HandleCountEvents(w, httptest.NewRequest("GET", "/events/count?allowed=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestListTenants(t *testing.T) {
+	svc := testService()
+
+	w := httptest.NewRecorder()
+	svc.HandleListTenants(w, httptest.NewRequest("GET", "/events/tenants", nil))
+	if got := strings.TrimSpace(w.Body.String()); got != "[]" {
+		t.Errorf("expected empty array with no events, got %s", got)
+	}
+
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k", TenantKey: "tenant-b", Method: "GET", Path: "/", Timestamp: "ts"},
+		{Key: "k", TenantKey: "tenant-a", Method: "GET", Path: "/", Timestamp: "ts"},
+		{Key: "k", TenantKey: "tenant-b", Method: "GET", Path: "/", Timestamp: "ts"},
+		{Key: "k", Method: "GET", Path: "/", Timestamp: "ts"},
+		{Key: "k", TenantKey: "tenant-c", Method: "GET", Path: "/", Timestamp: "ts"},
+	})
+	w = httptest.NewRecorder()
+	svc.HandleListTenants(w, httptest.NewRequest("GET", "/events/tenants", nil))
+
+	var tenants []string
+	if err := json.NewDecoder(w.Body).Decode(&tenants); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tenant-a", "tenant-b", "tenant-c"}; !reflect.DeepEqual(tenants, want) {
+		t.Errorf("expected %v, got %v", want, tenants)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	svc := testService()
+	srv := httptest.NewServer(http.HandlerFunc(svc.HandleStreamEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events/stream?tenant_key=tenant-a", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	_, err = svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-b", Method: "GET", Path: "/", Allowed: true, Timestamp: "t1"},
+		{Key: "k2", TenantKey: "tenant-a", Method: "GET", Path: "/", Allowed: true, Timestamp: "t2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := strings.CutPrefix(line, "data: ")
+	if !ok {
+		t.Fatalf("expected data frame, got %q", line)
+	}
+	var ev eventsv1.UsageEvent
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.GetKey() != "k2" {
+		t.Errorf("expected only tenant-a event k2, got %+v", &ev)
+	}
+}
+
+func TestStreamEvents_Shutdown(t *testing.T) {
+	svc := testService()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(svc.HandleStreamEvents))
+	srv.Config.RegisterOnShutdown(svc.CloseStreams)
+	srv.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The open stream mustn't hold Shutdown up until its timeout.
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("expected shutdown to end the stream, got %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("expected the stream to end cleanly, got %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	svc := testService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{
+		Events: makeEvents(5, 3),
+	})
+
+	req := httptest.NewRequest("GET", "/events/stats", nil)
+	w := httptest.NewRecorder()
+	svc.HandleStats(w, req)
+
+	var stats EventStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalReceived != 8 || stats.TotalAllowed != 5 || stats.TotalDenied != 3 || stats.StoredEvents != 8 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStats_ETag(t *testing.T) {
+	svc := testService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	stats := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/events/stats", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, req)
+		return w
+	}
+
+	w := stats("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", w.Code, etag)
+	}
+	for _, header := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag, "*"} {
+		w = stats(header)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: expected an empty 304, got %d %s", header, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %s: expected the 304 to carry ETag %s, got %q", header, etag, got)
+		}
+	}
+
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	w = stats(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the counters changed, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got == etag {
+		t.Errorf("expected a new ETag once the counters changed, got %s again", got)
+	}
+
+	svc.HandleResetStats(httptest.NewRecorder(), httptest.NewRequest("POST", "/events/stats/reset", nil))
+	if w = stats(w.Header().Get("ETag")); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after a counter reset, got %d", w.Code)
+	}
+}
+
+func TestStats_ClearWhilePublishing(t *testing.T) {
+	svc := testService()
+	stats := func() EventStats {
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+	// With nothing sampled out or trimmed, every stored event has been
+	// counted and every counted event is still stored.
+	check := func(stats EventStats) {
+		t.Helper()
+		if stats.TotalReceived < 0 || stats.TotalAllowed < 0 || stats.TotalDenied < 0 ||
+			stats.TotalAllowed+stats.TotalDenied != stats.TotalReceived ||
+			stats.TotalReceived != int64(stats.StoredEvents) {
+			t.Errorf("inconsistent stats: %+v", stats)
+		}
+	}
+
+	var publishers sync.WaitGroup
+	for range 8 {
+		publishers.Add(1)
+		go func() {
+			defer publishers.Done()
+			for range 100 {
+				svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 2)})
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	cleared := make(chan struct{})
+	go func() {
+		defer close(cleared)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+			svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events?allowed=false", nil))
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		publishers.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			check(stats())
+		}
+	}
+	close(stop)
+	<-cleared
+
+	final := stats()
+	check(final)
+	tenants := svc.Core().TenantStats()
+	if got := tenants.Tenants["tenant-1"].TotalReceived + tenants.NoTenant.TotalReceived; got != final.TotalReceived {
+		t.Errorf("expected per-tenant counts to add up to %d, got %d", final.TotalReceived, got)
+	}
+}
+
+func TestStats_ApproxBytes(t *testing.T) {
+	svc := testService()
+	approxBytes := func() int64 {
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats.ApproxBytes
+	}
+
+	if got := approxBytes(); got != 0 {
+		t.Errorf("expected 0 bytes with nothing stored, got %d", got)
+	}
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(5, 3)})
+	first := approxBytes()
+	if first < 8*eventOverhead {
+		t.Errorf("expected at least the overhead of 8 events (%d), got %d", 8*eventOverhead, first)
+	}
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{{Key: "k", Method: "GET", Path: "/a/much/longer/path/than/usual", Allowed: true, Timestamp: "ts", RequestId: "req-1"}}})
+	if got := approxBytes(); got <= first+eventOverhead {
+		t.Errorf("expected another event and its strings to add more than %d bytes to %d, got %d", eventOverhead, first, got)
+	}
+
+	svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+	if got := approxBytes(); got != 0 {
+		t.Errorf("expected 0 bytes after clearing, got %d", got)
+	}
+}
+
+func TestStats_AllowDenyRate(t *testing.T) {
+	svc := testService()
+	stats := func() EventStats {
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	if got := stats(); got.AllowRate != 0 || got.DenyRate != 0 {
+		t.Errorf("expected both rates 0 with nothing received, got %v and %v", got.AllowRate, got.DenyRate)
+	}
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 1)})
+	if got := stats(); got.AllowRate != 0.75 || got.DenyRate != 0.25 {
+		t.Errorf("expected rates 0.75 and 0.25 for 3 allowed and 1 denied, got %v and %v", got.AllowRate, got.DenyRate)
+	}
+}
+
+func TestStats_TotalDropped(t *testing.T) {
+	split, err := openStore("memory", 3, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, st := range map[string]Store{
+		"memory": newMemoryStore(3),
+		// Three allowed events fit; one of the two denied ones doesn't.
+		"split": split,
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc := NewEventService(slog.Default(), st)
+			stats := func() EventStats {
+				w := httptest.NewRecorder()
+				svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+				var stats EventStats
+				if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+					t.Fatal(err)
+				}
+				return stats
+			}
+
+			svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 2)})
+			want := int64(2)
+			if name == "split" {
+				want = 1
+			}
+			got := stats()
+			if got.TotalDropped != want {
+				t.Errorf("expected %d events dropped, got %d", want, got.TotalDropped)
+			}
+			if int64(got.StoredEvents)+got.TotalDropped != got.TotalReceived {
+				t.Errorf("expected stored + dropped to equal received, got %+v", got)
+			}
+
+			svc.HandleResetStats(httptest.NewRecorder(), httptest.NewRequest("POST", "/events/stats/reset", nil))
+			if got := stats().TotalDropped; got != 0 {
+				t.Errorf("expected the reset to zero total_dropped, got %d", got)
+			}
+			svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 1)})
+			if got := stats().TotalDropped; got != 2 {
+				t.Errorf("expected 2 events dropped since the reset, got %d", got)
+			}
+		})
+	}
+}
+
+func TestStats_UptimeAndLastEvent(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithClock(clock))
+	clock.Advance(time.Minute)
+	getStats := func() EventStats {
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	if stats := getStats(); stats.LastEventAt != "" || stats.UptimeSeconds != 60 {
+		t.Errorf("unexpected stats before any publish: %+v", stats)
+	}
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 2)})
+	if stats := getStats(); stats.LastEventAt != "2026-02-16T21:00:01Z" {
+		t.Errorf("expected last_event_at of the batch's last event, got %q", stats.LastEventAt)
+	}
+}
+
+func TestTenantStats(t *testing.T) {
+	svc := testService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+		{Key: "k", TenantKey: "tenant-a", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"},
+		{Key: "k", TenantKey: "tenant-a", Method: "GET", Path: "/", Allowed: false, Timestamp: "ts"},
+		{Key: "k", TenantKey: "tenant-b", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"},
+		{Key: "k", Method: "GET", Path: "/", Allowed: false, Timestamp: "ts"},
+	}})
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+		{Key: "k", TenantKey: "tenant-a", Method: "GET", Path: "/", Allowed: true, Timestamp: "ts"},
+	}})
+
+	w := httptest.NewRecorder()
+	svc.HandleTenantStats(w, httptest.NewRequest("GET", "/events/stats/by-tenant", nil))
+
+	var got eventcore.TenantStatsResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := eventcore.TenantStatsResponse{
+		Tenants: map[string]eventcore.TenantStats{
+			"tenant-a": {TotalReceived: 3, TotalAllowed: 2, TotalDenied: 1},
+			"tenant-b": {TotalReceived: 1, TotalAllowed: 1},
+		},
+		NoTenant: eventcore.TenantStats{TotalReceived: 1, TotalDenied: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected tenant stats:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+	if got := svc.Core().TenantStats(); len(got.Tenants) != 0 || got.NoTenant != (eventcore.TenantStats{}) {
+		t.Errorf("expected tenant stats to reset on clear, got %+v", got)
+	}
+}
+
+func TestClearEvents(t *testing.T) {
+	svc := testService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{
+		Events: makeEvents(3, 0),
+	})
+
+	req := httptest.NewRequest("DELETE", "/events", nil)
+	w := httptest.NewRecorder()
+	svc.HandleClearEvents(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if len(svc.StoredEvents()) != 0 {
+		t.Error("expected 0 events after clear")
+	}
+	if svc.Core().Totals().Received != 0 {
+		t.Error("expected totalReceived=0 after clear")
+	}
+}
+
+func TestClearEvents_Filtered(t *testing.T) {
+	svc := testService()
+	events := makeEvents(2, 1)
+	for i := range events {
+		events[i].TenantKey = "tenant-a"
+	}
+	other := makeEvents(1, 1)
+	for i := range other {
+		other[i].TenantKey = "tenant-b"
+	}
+	if _, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: append(events, other...)}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleClearEvents(w, httptest.NewRequest("DELETE", "/events?tenant_key=tenant-a", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp eventcore.DeleteResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Deleted != 3 {
+		t.Errorf("expected 3 deleted, got %+v (err=%v)", resp, err)
+	}
+	for _, ev := range svc.StoredEvents() {
+		if ev.GetTenantKey() != "tenant-b" {
+			t.Errorf("expected only tenant-b events left, got %+v", ev)
+		}
+	}
+	if n := len(svc.StoredEvents()); n != 2 {
+		t.Errorf("expected tenant-b's 2 events left, got %d", n)
+	}
+	if got := [3]int64{svc.Core().Totals().Received, svc.Core().Totals().Allowed, svc.Core().Totals().Denied}; got != [3]int64{2, 1, 1} {
+		t.Errorf("expected counters of 2 received, 1 allowed, 1 denied, got %v", got)
+	}
+	byTenant := svc.Core().TenantStats()
+	if _, ok := byTenant.Tenants["tenant-a"]; ok || byTenant.Tenants["tenant-b"].TotalReceived != 2 {
+		t.Errorf("expected only tenant-b in the per-tenant stats, got %+v", byTenant)
+	}
+
+	for _, query := range []string{"tenant=tenant-b", "limit=1", "since=yesterday"} {
+		w := httptest.NewRecorder()
+		svc.HandleClearEvents(w, httptest.NewRequest("DELETE", "/events?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+	if n := len(svc.StoredEvents()); n != 2 {
+		t.Errorf("expected rejected deletes to leave the events, got %d", n)
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	svc := testService()
+	mux := newMux(svc)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 2)})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/events/stats/reset", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/events/stats", nil))
+	var stats EventStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalReceived != 0 || stats.TotalAllowed != 0 || stats.TotalDenied != 0 {
+		t.Errorf("expected zeroed counters, got %+v", stats)
+	}
+	if stats.StoredEvents != 5 {
+		t.Errorf("expected the 5 stored events to survive, got %d", stats.StoredEvents)
+	}
+	if tenants := svc.Core().TenantStats(); len(tenants.Tenants) != 0 || tenants.NoTenant != (eventcore.TenantStats{}) {
+		t.Errorf("expected zeroed per-tenant counters, got %+v", tenants)
+	}
+
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)})
+	if got := svc.Core().Totals().Received; got != 1 {
+		t.Errorf("expected counting to restart from zero, got %d received", got)
 	}
 }
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExportCSV(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), []*eventsv1.UsageEvent{
+		{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, Remaining: 9, Limit: 10, Timestamp: "2026-02-16T21:00:00Z", StatusCode: 200, RequestId: "req-1"},
+		{Key: "k2", Method: "POST", Path: "/b, \"quoted\"", Allowed: false, Limit: 10, Timestamp: "2026-02-16T21:00:01Z", StatusCode: 429},
+	})
+
+	w := httptest.NewRecorder()
+	svc.HandleExportCSV(w, httptest.NewRequest("GET", "/events/export.csv", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"key", "tenant_key", "method", "path", "allowed", "remaining", "limit", "timestamp", "status_code", "request_id"},
+		{"k2", "", "POST", "/b, \"quoted\"", "false", "0", "10", "2026-02-16T21:00:01Z", "429", ""},
+		{"k1", "tenant-a", "GET", "/a", "true", "9", "10", "2026-02-16T21:00:00Z", "200", "req-1"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("unexpected CSV:\ngot  %q\nwant %q", records, want)
+	}
+}
+
+func TestExportCSV_Filtered(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(3, 2))
+
+	w := httptest.NewRecorder()
+	svc.HandleExportCSV(w, httptest.NewRequest("GET", "/events/export.csv?allowed=false", nil))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d records", len(records))
+	}
+	for _, rec := range records[1:] {
+		if rec[4] != "false" {
+			t.Errorf("expected only denied events, got %q", rec)
+		}
+	}
+}
+
+func TestListEvents_NDJSON(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(3, 2))
+
+	for name, req := range map[string]*http.Request{
+		"format": httptest.NewRequest("GET", "/events?format=ndjson&limit=4", nil),
+		"accept": httptest.NewRequest("GET", "/events?limit=4", nil),
+		"sorted": httptest.NewRequest("GET", "/events?format=ndjson&limit=4&sort=timestamp_asc", nil),
+		"cursor": httptest.NewRequest("GET", "/events?format=ndjson&limit=4&cursor=", nil),
+	} {
+		if name == "accept" {
+			req.Header.Set("Accept", "application/x-ndjson")
+		}
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("%s: unexpected Content-Type %q", name, ct)
+		}
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("%s: expected 4 lines, got %d: %q", name, len(lines), w.Body.String())
+		}
+		for _, line := range lines {
+			var ev eventsv1.UsageEvent
+			if err := json.Unmarshal([]byte(line), &ev); err != nil {
+				t.Errorf("%s: line %q is not a UsageEvent: %v", name, line, err)
+			}
+		}
+		if name == "cursor" && w.Header().Get("X-Next-Cursor") == "" {
+			t.Errorf("expected X-Next-Cursor header on a paged NDJSON response")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?format=xml", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", w.Code)
+	}
+}
+
+func TestListEvents_Protobuf(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(3, 2))
+
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?limit=4", nil))
+	var want []*eventsv1.UsageEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &want); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, req := range map[string]*http.Request{
+		"format": httptest.NewRequest("GET", "/events?format=protobuf&limit=4", nil),
+		"accept": httptest.NewRequest("GET", "/events?limit=4", nil),
+		"cursor": httptest.NewRequest("GET", "/events?limit=4&cursor=", nil),
+	} {
+		if name != "format" {
+			req.Header.Set("Accept", "application/x-protobuf, application/json;q=0.5")
+		}
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("%s: unexpected Content-Type %q", name, ct)
+		}
+		var got eventsv1.PublishEventsRequest
+		if err := proto.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: body is not a PublishEventsRequest: %v", name, err)
+		}
+		if len(got.GetEvents()) != len(want) {
+			t.Fatalf("%s: expected %d events, got %d", name, len(want), len(got.GetEvents()))
+		}
+		for i := range want {
+			if !proto.Equal(got.GetEvents()[i], want[i]) {
+				t.Errorf("%s: event %d: expected %v, got %v", name, i, want[i], got.GetEvents()[i])
+			}
+		}
+		if name == "cursor" && w.Header().Get("X-Next-Cursor") == "" {
+			t.Errorf("expected X-Next-Cursor header on a paged protobuf response")
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Streaming extensions to the EdgeQuota events protocol provided by this
+// template. EdgeQuota itself only calls edgequota.events.v1.EventService;
+// these RPCs are for importers and consumers of the collected events.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: eventstream/v1/eventstream.proto
+
+package eventstreamv1
+
+import (
+	v1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SubscribeEventsRequest selects which events a subscriber receives.
+type SubscribeEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream events with this tenant key. Empty streams all events.
+	TenantKey     string `protobuf:"bytes,1,opt,name=tenant_key,json=tenantKey,proto3" json:"tenant_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeEventsRequest) Reset() {
+	*x = SubscribeEventsRequest{}
+	mi := &file_eventstream_v1_eventstream_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeEventsRequest) ProtoMessage() {}
+
+func (x *SubscribeEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventstream_v1_eventstream_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeEventsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeEventsRequest) Descriptor() ([]byte, []int) {
+	return file_eventstream_v1_eventstream_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeEventsRequest) GetTenantKey() string {
+	if x != nil {
+		return x.TenantKey
+	}
+	return ""
+}
+
+var File_eventstream_v1_eventstream_proto protoreflect.FileDescriptor
+
+const file_eventstream_v1_eventstream_proto_rawDesc = "" +
+	"\n" +
+	" eventstream/v1/eventstream.proto\x12\x0eeventstream.v1\x1a edgequota/events/v1/events.proto\"7\n" +
+	"\x16SubscribeEventsRequest\x12\x1d\n" +
+	"\n" +
+	"tenant_key\x18\x01 \x01(\tR\ttenantKey2\xe2\x01\n" +
+	"\x12EventStreamService\x12\\\n" +
+	"\x0fSubscribeEvents\x12&.eventstream.v1.SubscribeEventsRequest\x1a\x1f.edgequota.events.v1.UsageEvent0\x01\x12n\n" +
+	"\x13PublishEventsStream\x12).edgequota.events.v1.PublishEventsRequest\x1a*.edgequota.events.v1.PublishEventsResponse(\x01BUZSgithub.com/edgequota/external-events-template/grpc/gen/eventstream/v1;eventstreamv1b\x06proto3"
+
+var (
+	file_eventstream_v1_eventstream_proto_rawDescOnce sync.Once
+	file_eventstream_v1_eventstream_proto_rawDescData []byte
+)
+
+func file_eventstream_v1_eventstream_proto_rawDescGZIP() []byte {
+	file_eventstream_v1_eventstream_proto_rawDescOnce.Do(func() {
+		file_eventstream_v1_eventstream_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_eventstream_v1_eventstream_proto_rawDesc), len(file_eventstream_v1_eventstream_proto_rawDesc)))
+	})
+	return file_eventstream_v1_eventstream_proto_rawDescData
+}
+
+var file_eventstream_v1_eventstream_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_eventstream_v1_eventstream_proto_goTypes = []any{
+	(*SubscribeEventsRequest)(nil),   // 0: eventstream.v1.SubscribeEventsRequest
+	(*v1.PublishEventsRequest)(nil),  // 1: edgequota.events.v1.PublishEventsRequest
+	(*v1.UsageEvent)(nil),            // 2: edgequota.events.v1.UsageEvent
+	(*v1.PublishEventsResponse)(nil), // 3: edgequota.events.v1.PublishEventsResponse
+}
+var file_eventstream_v1_eventstream_proto_depIdxs = []int32{
+	0, // 0: eventstream.v1.EventStreamService.SubscribeEvents:input_type -> eventstream.v1.SubscribeEventsRequest
+	1, // 1: eventstream.v1.EventStreamService.PublishEventsStream:input_type -> edgequota.events.v1.PublishEventsRequest
+	2, // 2: eventstream.v1.EventStreamService.SubscribeEvents:output_type -> edgequota.events.v1.UsageEvent
+	3, // 3: eventstream.v1.EventStreamService.PublishEventsStream:output_type -> edgequota.events.v1.PublishEventsResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_eventstream_v1_eventstream_proto_init() }
+func file_eventstream_v1_eventstream_proto_init() {
+	if File_eventstream_v1_eventstream_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_eventstream_v1_eventstream_proto_rawDesc), len(file_eventstream_v1_eventstream_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_eventstream_v1_eventstream_proto_goTypes,
+		DependencyIndexes: file_eventstream_v1_eventstream_proto_depIdxs,
+		MessageInfos:      file_eventstream_v1_eventstream_proto_msgTypes,
+	}.Build()
+	File_eventstream_v1_eventstream_proto = out.File
+	file_eventstream_v1_eventstream_proto_goTypes = nil
+	file_eventstream_v1_eventstream_proto_depIdxs = nil
+}
@@ -0,0 +1,174 @@
+// Streaming extensions to the EdgeQuota events protocol provided by this
+// template. EdgeQuota itself only calls edgequota.events.v1.EventService;
+// these RPCs are for importers and consumers of the collected events.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: eventstream/v1/eventstream.proto
+
+package eventstreamv1
+
+import (
+	context "context"
+	v1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EventStreamService_SubscribeEvents_FullMethodName     = "/eventstream.v1.EventStreamService/SubscribeEvents"
+	EventStreamService_PublishEventsStream_FullMethodName = "/eventstream.v1.EventStreamService/PublishEventsStream"
+)
+
+// EventStreamServiceClient is the client API for EventStreamService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventStreamServiceClient interface {
+	// SubscribeEvents streams each newly received usage event as it arrives.
+	// Subscribers that fall behind miss events rather than slowing publishers.
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[v1.UsageEvent], error)
+	// PublishEventsStream accepts any number of batches over one call, for
+	// imports too large for a single PublishEvents request. Each batch is
+	// handled as PublishEvents would handle it, and the response totals the
+	// events accepted. A rejected batch ends the call with its error; the
+	// batches before it stay stored.
+	PublishEventsStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[v1.PublishEventsRequest, v1.PublishEventsResponse], error)
+}
+
+type eventStreamServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventStreamServiceClient(cc grpc.ClientConnInterface) EventStreamServiceClient {
+	return &eventStreamServiceClient{cc}
+}
+
+func (c *eventStreamServiceClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[v1.UsageEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventStreamService_ServiceDesc.Streams[0], EventStreamService_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, v1.UsageEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventStreamService_SubscribeEventsClient = grpc.ServerStreamingClient[v1.UsageEvent]
+
+func (c *eventStreamServiceClient) PublishEventsStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[v1.PublishEventsRequest, v1.PublishEventsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventStreamService_ServiceDesc.Streams[1], EventStreamService_PublishEventsStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[v1.PublishEventsRequest, v1.PublishEventsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventStreamService_PublishEventsStreamClient = grpc.ClientStreamingClient[v1.PublishEventsRequest, v1.PublishEventsResponse]
+
+// EventStreamServiceServer is the server API for EventStreamService service.
+// All implementations must embed UnimplementedEventStreamServiceServer
+// for forward compatibility.
+type EventStreamServiceServer interface {
+	// SubscribeEvents streams each newly received usage event as it arrives.
+	// Subscribers that fall behind miss events rather than slowing publishers.
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[v1.UsageEvent]) error
+	// PublishEventsStream accepts any number of batches over one call, for
+	// imports too large for a single PublishEvents request. Each batch is
+	// handled as PublishEvents would handle it, and the response totals the
+	// events accepted. A rejected batch ends the call with its error; the
+	// batches before it stay stored.
+	PublishEventsStream(grpc.ClientStreamingServer[v1.PublishEventsRequest, v1.PublishEventsResponse]) error
+	mustEmbedUnimplementedEventStreamServiceServer()
+}
+
+// UnimplementedEventStreamServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEventStreamServiceServer struct{}
+
+func (UnimplementedEventStreamServiceServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[v1.UsageEvent]) error {
+	return status.Error(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedEventStreamServiceServer) PublishEventsStream(grpc.ClientStreamingServer[v1.PublishEventsRequest, v1.PublishEventsResponse]) error {
+	return status.Error(codes.Unimplemented, "method PublishEventsStream not implemented")
+}
+func (UnimplementedEventStreamServiceServer) mustEmbedUnimplementedEventStreamServiceServer() {}
+func (UnimplementedEventStreamServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeEventStreamServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventStreamServiceServer will
+// result in compilation errors.
+type UnsafeEventStreamServiceServer interface {
+	mustEmbedUnimplementedEventStreamServiceServer()
+}
+
+func RegisterEventStreamServiceServer(s grpc.ServiceRegistrar, srv EventStreamServiceServer) {
+	// If the following call panics, it indicates UnimplementedEventStreamServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EventStreamService_ServiceDesc, srv)
+}
+
+func _EventStreamService_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventStreamServiceServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, v1.UsageEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventStreamService_SubscribeEventsServer = grpc.ServerStreamingServer[v1.UsageEvent]
+
+func _EventStreamService_PublishEventsStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventStreamServiceServer).PublishEventsStream(&grpc.GenericServerStream[v1.PublishEventsRequest, v1.PublishEventsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventStreamService_PublishEventsStreamServer = grpc.ClientStreamingServer[v1.PublishEventsRequest, v1.PublishEventsResponse]
+
+// EventStreamService_ServiceDesc is the grpc.ServiceDesc for EventStreamService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventStreamService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eventstream.v1.EventStreamService",
+	HandlerType: (*EventStreamServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _EventStreamService_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PublishEventsStream",
+			Handler:       _EventStreamService_PublishEventsStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "eventstream/v1/eventstream.proto",
+}
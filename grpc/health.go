@@ -0,0 +1,31 @@
+package main
+
+import (
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServices are the names EventService reports under in
+// grpc.health.v1.Health; "" is the server as a whole.
+var healthServices = []string{"", eventsv1.EventService_ServiceDesc.ServiceName}
+
+func newHealthServer() *health.Server {
+	hs := health.NewServer()
+	for _, name := range healthServices {
+		hs.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return hs
+}
+
+// SetServing reports the service as SERVING or NOT_SERVING to health
+// checks. It starts out NOT_SERVING.
+func (s *EventService) SetServing(serving bool) {
+	st := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		st = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, name := range healthServices {
+		s.health.SetServingStatus(name, st)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealth(t *testing.T) {
+	svc := testService()
+	client := healthpb.NewHealthClient(dialBufconn(t, svc))
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetStatus()
+	}
+
+	for _, service := range []string{"", "edgequota.events.v1.EventService"} {
+		if got := check(service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("%q: expected NOT_SERVING before startup, got %v", service, got)
+		}
+	}
+	svc.SetServing(true)
+	for _, service := range []string{"", "edgequota.events.v1.EventService"} {
+		if got := check(service); got != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("%q: expected SERVING, got %v", service, got)
+		}
+	}
+	svc.SetServing(false)
+	if got := check("edgequota.events.v1.EventService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING during shutdown, got %v", got)
+	}
+}
+
+func TestHealth_ExemptFromAPIKey(t *testing.T) {
+	svc := testService()
+	svc.SetServing(true)
+	conn := dialBufconn(t, svc,
+		grpc.UnaryInterceptor(apiKeyInterceptor("s3cret")),
+		grpc.StreamInterceptor(apiKeyStreamInterceptor("s3cret")),
+	)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("expected health check without an API key to succeed, got %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %v", resp.GetStatus())
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// kafkaBufferSize is the number of events the Kafka sink queues before
+	// it starts dropping them.
+	kafkaBufferSize = 10000
+	// kafkaMaxBatch caps the number of messages per WriteMessages call.
+	kafkaMaxBatch = 500
+)
+
+// messageWriter is the subset of *kafka.Writer used by kafkaSink.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink produces each accepted event to Kafka as a JSON message keyed
+// by tenant key. Events are queued and written by a background goroutine;
+// when the queue is full they are dropped rather than blocking publishers.
+type kafkaSink struct {
+	logger *slog.Logger
+	writer messageWriter
+	queue  chan *eventsv1.UsageEvent
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newKafkaWriter returns a writer for a comma-separated broker list.
+func newKafkaWriter(brokers, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 50 * time.Millisecond,
+	}
+}
+
+func newKafkaSink(logger *slog.Logger, w messageWriter, buffer int) *kafkaSink {
+	k := &kafkaSink{
+		logger: logger,
+		writer: w,
+		queue:  make(chan *eventsv1.UsageEvent, buffer),
+		done:   make(chan struct{}),
+	}
+	go k.run()
+	return k
+}
+
+// Publish queues batch for production without blocking.
+func (k *kafkaSink) Publish(batch []*eventsv1.UsageEvent) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.closed {
+		return
+	}
+	for i, ev := range batch {
+		select {
+		case k.queue <- ev:
+		default:
+			k.logger.Warn("kafka sink queue full, dropping events", "dropped", len(batch)-i)
+			return
+		}
+	}
+}
+
+func (k *kafkaSink) run() {
+	defer close(k.done)
+	msgs := make([]kafka.Message, 0, kafkaMaxBatch)
+	for ev := range k.queue {
+		msgs = append(msgs[:0], k.message(ev))
+		// Drain whatever else is already queued into the same write.
+	fill:
+		for len(msgs) < kafkaMaxBatch {
+			select {
+			case ev, ok := <-k.queue:
+				if !ok {
+					break fill
+				}
+				msgs = append(msgs, k.message(ev))
+			default:
+				break fill
+			}
+		}
+		if err := k.writer.WriteMessages(context.Background(), msgs...); err != nil {
+			k.logger.Error("failed to produce events to kafka", "count", len(msgs), "error", err)
+		}
+	}
+}
+
+func (k *kafkaSink) message(ev *eventsv1.UsageEvent) kafka.Message {
+	value, _ := json.Marshal(jsonEvent{ev})
+	return kafka.Message{Key: []byte(ev.GetTenantKey()), Value: value}
+}
+
+// Close stops accepting events, waits for queued ones to be written, and
+// closes the writer.
+func (k *kafkaSink) Close() error {
+	k.mu.Lock()
+	if !k.closed {
+		k.closed = true
+		close(k.queue)
+	}
+	k.mu.Unlock()
+	<-k.done
+	return k.writer.Close()
+}
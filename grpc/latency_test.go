@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+func TestStats_PublishLatency(t *testing.T) {
+	svc := testService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)})
+
+	w := httptest.NewRecorder()
+	svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+	var stats EventStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.PublishLatency.Count != 2 {
+		t.Errorf("expected 2 publishes in the latency histogram, got %d", stats.PublishLatency.Count)
+	}
+	if stats.PublishLatency.P99Ms <= 0 {
+		t.Errorf("expected a positive p99, got %v", stats.PublishLatency.P99Ms)
+	}
+}
@@ -2,12 +2,17 @@
 // EdgeQuota external events gRPC protocol (edgequota.events.v1.EventService).
 //
 // It exposes:
-//   - A gRPC server on :50053 implementing EventService/PublishEvents.
-//   - An HTTP server on :8083 with GET /events to query stored events.
+//   - A gRPC server on :50053 implementing EventService/PublishEvents,
+//     EventStreamService/SubscribeEvents and PublishEventsStream, and
+//     grpc.health.v1.Health.
+//   - An HTTP server on :8083 serving the query API, a live event stream
+//     and Prometheus metrics on /metrics. The routes are registered by
+//     newMux in routes.go; the README's "Query API" section describes
+//     each one.
 //
 // Usage:
 //
-//	go run . [-grpc-addr :50053] [-http-addr :8083]
+//	go run . [-grpc-addr :50053] [-http-addr :8083] [-store memory|sqlite:events.db] [-auth-token TOKEN] [-api-key KEY]
 package main
 
 import (
@@ -18,25 +23,259 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
+// defaultShutdownTimeout bounds each step of shutdown on its own: draining
+// the servers, flushing buffered events and flushing traces.
+const defaultShutdownTimeout = 5 * time.Second
+
 func main() {
 	grpcAddr := flag.String("grpc-addr", envOrDefault("GRPC_ADDR", ":50053"), "gRPC listen address")
 	httpAddr := flag.String("http-addr", envOrDefault("HTTP_ADDR", ":8083"), "HTTP listen address (query API)")
+	storeSpec := flag.String("store", envOrDefault("STORE", "memory"), "event store: memory, sqlite:<path>, redis://[:password@]host:port/db or rediss:// for TLS; registered schemes: "+strings.Join(storeSchemes(), ", "))
+	authToken := flag.String("auth-token", envOrDefault("AUTH_TOKEN", ""), `require "Authorization: Bearer <token>" on the HTTP query API (empty disables auth)`)
+	apiKey := flag.String("api-key", envOrDefault("API_KEY", ""), "require this x-api-key metadata value on gRPC calls (empty disables auth)")
+	maxBatch := flag.Int("max-batch", envOrDefaultInt("MAX_BATCH", defaultMaxBatch), "maximum events per publish (0 = unlimited)")
+	defaultLimit := flag.Int("default-limit", envOrDefaultInt("DEFAULT_LIMIT", defaultListLimit), "events returned by GET /events when no limit is given")
+	maxLimit := flag.Int("max-limit", envOrDefaultInt("MAX_LIMIT", defaultMaxListLimit), "largest limit GET /events honours; larger ones are lowered to it (0 = unlimited)")
+	listEnvelope := flag.Bool("list-envelope", envOrDefaultBool("LIST_ENVELOPE", false), `answer GET /events with {"events": [...], "count", "total", "limit", "offset"} instead of a bare array unless the request sets envelope=false`)
+	maxEvents := flag.Int("max-events", envOrDefaultInt("MAX_EVENTS", defaultMaxEvents), "maximum events kept by the memory store (0 = unlimited; memory grows with traffic)")
+	maxDeniedEvents := flag.Int("max-denied-events", envOrDefaultInt("MAX_DENIED_EVENTS", 0), "keep denied events in a memory ring of their own holding this many, so allowed traffic can't evict them; -max-events then caps allowed events only (0 = one shared ring)")
+	maxEventsPerTenant := flag.Int("max-events-per-tenant", envOrDefaultInt("MAX_EVENTS_PER_TENANT", 0), "keep each tenant's events in a memory ring of its own holding this many, so one tenant's traffic can't evict another's; -max-events then no longer applies (0 = one shared ring)")
+	initialCapacity := flag.Int("initial-capacity", envOrDefaultInt("INITIAL_CAPACITY", defaultInitialCapacity), "events the memory store preallocates room for at startup (capped at -max-events)")
+	retention := flag.Duration("retention", envOrDefaultDuration("RETENTION", 0), "prune events whose timestamp is older than this (0 = keep until trimmed or cleared)")
+	rateLimit := flag.Float64("rate-limit", envOrDefaultFloat("RATE_LIMIT", 0), "publishes per second allowed from each remote IP (0 = unlimited)")
+	rateLimitBurst := flag.Int("rate-limit-burst", envOrDefaultInt("RATE_LIMIT_BURST", eventcore.DefaultRateLimitBurst), "publishes a remote IP may make in a burst above -rate-limit")
+	schemaFile := flag.String("schema", envOrDefault("SCHEMA", ""), "JSON Schema file every published event must match; batches with a non-matching event are rejected (empty disables validation)")
+	maxFieldLength := flag.Int("max-field-length", envOrDefaultInt("MAX_FIELD_LENGTH", defaultMaxFieldLength), "longest key, method, path, tenant key or request ID accepted, in bytes; events with a longer one are rejected (0 = unlimited)")
+	truncateLongFields := flag.Bool("truncate-long-fields", envOrDefaultBool("TRUNCATE_LONG_FIELDS", false), "store events whose fields exceed -max-field-length with the fields truncated instead of rejecting them")
+	normalizeTimestamps := flag.Bool("normalize-timestamps", envOrDefaultBool("NORMALIZE_TIMESTAMPS", true), "rewrite event timestamps sent as Unix epoch seconds or milliseconds, or without a zone, to RFC 3339 before storing them")
+	softWatermark := flag.Float64("soft-watermark", envOrDefaultFloat("SOFT_WATERMARK", 0), "fraction of the memory store's capacity at which publishes succeed with a warning and a Retry-After hint (0 = off)")
+	hardWatermark := flag.Float64("hard-watermark", envOrDefaultFloat("HARD_WATERMARK", 0), "fraction of the memory store's capacity at which publishes are refused with 503 / UNAVAILABLE (0 = off)")
+	sampleRate := flag.Float64("sample-rate", envOrDefaultFloat("SAMPLE_RATE", 1), "fraction (0.0-1.0) of published events to store, chosen by a hash of the request ID; all events are still counted in the stats")
+	pathTemplates := flag.String("path-templates", envOrDefault("PATH_TEMPLATES", ""), `comma-separated route templates such as "/users/{id}"; a matching event path is replaced by its template before storing (empty keeps paths as sent)`)
+	asyncQueue := flag.Int("async-queue", envOrDefaultInt("ASYNC_QUEUE", 0), "queue up to this many published batches and store them from a background writer (0 = store synchronously)")
+	kafkaBrokers := flag.String("kafka-brokers", envOrDefault("KAFKA_BROKERS", ""), "comma-separated Kafka brokers to produce accepted events to (empty disables Kafka)")
+	kafkaTopic := flag.String("kafka-topic", envOrDefault("KAFKA_TOPIC", ""), "Kafka topic for accepted events")
+	s3Bucket := flag.String("s3-bucket", envOrDefault("S3_BUCKET", ""), "S3 bucket to archive accepted events to as gzipped NDJSON objects (empty disables the archive); credentials and region come from the usual AWS environment")
+	s3Prefix := flag.String("s3-prefix", envOrDefault("S3_PREFIX", ""), "key prefix for archived event objects")
+	s3FlushEvents := flag.Int("s3-flush-events", envOrDefaultInt("S3_FLUSH_EVENTS", defaultS3FlushEvents), "write an S3 object once this many events are buffered")
+	s3FlushInterval := flag.Duration("s3-flush-interval", envOrDefaultDuration("S3_FLUSH_INTERVAL", defaultS3FlushInterval), "write buffered events to S3 at least this often")
+	alertWebhook := flag.String("alert-webhook", envOrDefault("ALERT_WEBHOOK", ""), "URL to POST an alert to when the share of denied events over -alert-window rises above -alert-deny-rate (empty disables alerting)")
+	alertDenyRate := flag.Float64("alert-deny-rate", envOrDefaultFloat("ALERT_DENY_RATE", 0.5), "denial rate (0.0-1.0) above which -alert-webhook is called")
+	alertWindow := flag.Duration("alert-window", envOrDefaultDuration("ALERT_WINDOW", defaultAlertWindow), "how far back the denial rate for alerts is computed")
+	alertCooldown := flag.Duration("alert-cooldown", envOrDefaultDuration("ALERT_COOLDOWN", defaultAlertCooldown), "least time between two alerts")
+	shutdownTimeout := flag.Duration("shutdown-timeout", envOrDefaultDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout), "how long each shutdown step may take: in-flight requests finishing, buffered events being flushed and traces being flushed")
+	otlpEndpoint := flag.String("otlp-endpoint", envOrDefault("OTLP_ENDPOINT", ""), "OTLP/HTTP collector URL to export traces to, e.g. http://localhost:4318 (empty disables tracing)")
+	logLevel := flag.String("log-level", envOrDefault("LOG_LEVEL", "info"), "log level: debug, info, warn or error")
+	accessLogLevel := flag.String("access-log-level", envOrDefault("ACCESS_LOG_LEVEL", "info"), "level of the per-request access log: debug, info, warn or error")
+	corsOrigin := flag.String("cors-origin", envOrDefault("CORS_ORIGIN", ""), `comma-separated origins allowed to call the HTTP API from a browser, or "*" (empty disables CORS)`)
+	tlsCert := flag.String("tls-cert", envOrDefault("TLS_CERT", ""), "TLS certificate file for the HTTP query API (requires -tls-key)")
+	tlsKey := flag.String("tls-key", envOrDefault("TLS_KEY", ""), "TLS private key file for the HTTP query API (requires -tls-cert)")
+	grpcTLSCert := flag.String("grpc-tls-cert", envOrDefault("GRPC_TLS_CERT", ""), "TLS certificate file for the gRPC server (requires -grpc-tls-key)")
+	grpcTLSKey := flag.String("grpc-tls-key", envOrDefault("GRPC_TLS_KEY", ""), "TLS private key file for the gRPC server (requires -grpc-tls-cert)")
+	grpcClientCA := flag.String("grpc-client-ca", envOrDefault("GRPC_CLIENT_CA", ""), "CA bundle for verifying gRPC client certificates; when set, clients must present one (mTLS)")
+	maxConcurrentStreams := flag.Int("max-concurrent-streams", envOrDefaultInt("MAX_CONCURRENT_STREAMS", defaultMaxConcurrentStreams), "RPCs a single gRPC connection may have open at once; further ones wait (0 = unlimited)")
+	keepaliveMinTime := flag.Duration("keepalive-min-time", envOrDefaultDuration("KEEPALIVE_MIN_TIME", defaultKeepaliveMinTime), "shortest interval between client keepalive pings; clients pinging more often are disconnected")
+	keepalivePermitWithoutStream := flag.Bool("keepalive-permit-without-stream", envOrDefaultBool("KEEPALIVE_PERMIT_WITHOUT_STREAM", true), "allow client keepalive pings on connections with no open RPC")
+	maxRecvMsgBytes := flag.Int("max-recv-msg-bytes", envOrDefaultInt("MAX_RECV_MSG_BYTES", 0), "largest gRPC message accepted, in bytes; larger ones fail with RESOURCE_EXHAUSTED (0 = room for -max-batch events of 1 KiB each, and at least 4 MiB)")
+	maxSendMsgBytes := flag.Int("max-send-msg-bytes", envOrDefaultInt("MAX_SEND_MSG_BYTES", defaultMaxSendMsgBytes), "largest gRPC message sent, in bytes")
+	replayAllow := flag.String("replay-allow", envOrDefault("REPLAY_ALLOW", ""), `comma-separated hosts, as "host" or "host:port", that POST /events/replay may send events to (empty disables replay)`)
+	pprofAddr := flag.String("pprof-addr", envOrDefault("PPROF_ADDR", ""), `address to serve net/http/pprof on under /debug/pprof/, such as ":6060"; without a host it binds to localhost (empty disables profiling)`)
 	flag.Parse()
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	level, levelOK := eventcore.ParseLogLevel(*logLevel)
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	if !levelOK {
+		logger.Warn("invalid log level, using info", "level", *logLevel)
+	}
+	accessLevel, ok := eventcore.ParseLogLevel(*accessLogLevel)
+	if !ok {
+		logger.Warn("invalid access log level, using info", "level", *accessLogLevel)
+	}
+
+	tlsConfig, err := loadTLSConfig(*tlsCert, *tlsKey)
+	if err != nil {
+		logger.Error("invalid TLS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	grpcTLSConfig, err := loadGRPCTLSConfig(*grpcTLSCert, *grpcTLSKey, *grpcClientCA)
+	if err != nil {
+		logger.Error("invalid gRPC TLS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if *maxRecvMsgBytes < 0 || *maxSendMsgBytes < 0 {
+		logger.Error("gRPC message size limits must not be negative", "max_recv_msg_bytes", *maxRecvMsgBytes, "max_send_msg_bytes", *maxSendMsgBytes)
+		os.Exit(1)
+	}
+	if *maxRecvMsgBytes == 0 {
+		*maxRecvMsgBytes = recvMsgSizeFor(*maxBatch)
+	}
+	if *maxConcurrentStreams < 0 {
+		logger.Error("max concurrent streams must not be negative", "max_concurrent_streams", *maxConcurrentStreams)
+		os.Exit(1)
+	}
+
+	if *shutdownTimeout <= 0 {
+		logger.Error("shutdown timeout must be positive", "shutdown_timeout", *shutdownTimeout)
+		os.Exit(1)
+	}
+
+	if *sampleRate < 0 || *sampleRate > 1 {
+		logger.Error("sample rate must be between 0 and 1", "sample_rate", *sampleRate)
+		os.Exit(1)
+	}
+
+	storage, err := openStore(*storeSpec, *maxEvents, *maxDeniedEvents, *maxEventsPerTenant, *initialCapacity)
+	if err != nil {
+		logger.Error("failed to open store", "store", *storeSpec, "error", err)
+		os.Exit(1)
+	}
+	if *asyncQueue > 0 {
+		storage = eventcore.NewAsyncStore(logger, storage, *asyncQueue)
+	}
+	defer storage.Close()
+
+	opts := []Option{
+		WithMaxBatch(*maxBatch),
+		WithListLimits(*defaultLimit, *maxLimit),
+		WithListEnvelope(*listEnvelope),
+		WithRateLimit(*rateLimit, *rateLimitBurst),
+		WithMaxFieldLength(*maxFieldLength, *truncateLongFields),
+		WithTimestampNormalization(*normalizeTimestamps),
+		WithSampleRate(*sampleRate),
+		WithReplayAllow(strings.Split(*replayAllow, ",")),
+	}
+	if *softWatermark > 0 || *hardWatermark > 0 {
+		if *maxEventsPerTenant > 0 {
+			logger.Warn("watermarks don't apply to per-tenant rings, ignoring them", "max_events_per_tenant", *maxEventsPerTenant)
+		} else if *maxEvents > 0 {
+			// Watermarks are relative to the memory store's capacity,
+			// including a separate denied ring.
+			capacity := *maxEvents + max(*maxDeniedEvents, 0)
+			opts = append(opts, WithWatermarks(capacity, *softWatermark, *hardWatermark))
+		} else {
+			logger.Warn("watermarks need a bounded memory store, ignoring them", "max_events", *maxEvents)
+		}
+	}
+
+	if *pathTemplates != "" {
+		enricher, err := NewPathTemplateEnricher(strings.Split(*pathTemplates, ","))
+		if err != nil {
+			logger.Error("invalid path templates", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithEnricher(enricher))
+	}
+
+	if *schemaFile != "" {
+		schema, err := loadEventSchema(*schemaFile)
+		if err != nil {
+			logger.Error("failed to load event schema", "path", *schemaFile, "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithSchema(schema))
+		logger.Info("validating events against schema", "path", *schemaFile)
+	}
+
+	var kafkaOut *kafkaSink
+	if *kafkaBrokers != "" && *kafkaTopic != "" {
+		kafkaOut = newKafkaSink(logger, newKafkaWriter(*kafkaBrokers, *kafkaTopic), kafkaBufferSize)
+		opts = append(opts, WithSink(kafkaOut))
+		logger.Info("producing events to kafka", "brokers", *kafkaBrokers, "topic", *kafkaTopic)
+	}
+
+	var s3Out *s3Sink
+	if *s3Bucket != "" {
+		client, err := newS3Client(context.Background())
+		if err != nil {
+			logger.Error("failed to set up s3 client", "error", err)
+			os.Exit(1)
+		}
+		s3Out = newS3Sink(logger, client, *s3Bucket, *s3Prefix, *s3FlushEvents, *s3FlushInterval, eventcore.RealClock{})
+		opts = append(opts, WithSink(s3Out))
+		logger.Info("archiving events to s3", "bucket", *s3Bucket, "prefix", *s3Prefix)
+	}
+
+	var alertOut *alertSink
+	if *alertWebhook != "" {
+		if err := validAlertURL(*alertWebhook); err != nil {
+			logger.Error("invalid alert webhook", "error", err)
+			os.Exit(1)
+		}
+		if *alertDenyRate < 0 || *alertDenyRate >= 1 {
+			logger.Error("alert deny rate must be at least 0 and below 1", "alert_deny_rate", *alertDenyRate)
+			os.Exit(1)
+		}
+		alertOut = newAlertSink(logger, *alertWebhook, *alertDenyRate, *alertWindow, *alertCooldown, eventcore.RealClock{})
+		opts = append(opts, WithSink(alertOut))
+		logger.Info("alerting on denial rate", "url", *alertWebhook, "deny_rate", *alertDenyRate, "window", *alertWindow)
+	}
+
+	var tracerProvider *sdktrace.TracerProvider
+	if *otlpEndpoint != "" {
+		tracerProvider, err = newTracerProvider(context.Background(), *otlpEndpoint)
+		if err != nil {
+			logger.Error("failed to set up tracing", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithTracerProvider(tracerProvider))
+		logger.Info("exporting traces", "endpoint", *otlpEndpoint)
+	}
+
+	svc := NewEventService(logger, storage, opts...)
+	prometheus.MustRegister(svc)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	svc := NewEventService(logger)
+	var background sync.WaitGroup
+	if *retention > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			svc.RunRetention(ctx, *retention, eventcore.RetentionInterval(*retention))
+		}()
+	}
 
-	grpcServer := grpc.NewServer()
+	limits := grpcLimits{
+		MaxConcurrentStreams:         *maxConcurrentStreams,
+		KeepaliveMinTime:             *keepaliveMinTime,
+		KeepalivePermitWithoutStream: *keepalivePermitWithoutStream,
+		MaxRecvMsgSize:               *maxRecvMsgBytes,
+		MaxSendMsgSize:               *maxSendMsgBytes,
+	}
+	grpcOpts := append([]grpc.ServerOption{grpc.StatsHandler(svc.grpcStatsHandler())}, limits.serverOptions()...)
+	if grpcTLSConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(grpcTLSConfig)))
+	}
+	if *apiKey != "" {
+		grpcOpts = append(grpcOpts,
+			grpc.UnaryInterceptor(apiKeyInterceptor(*apiKey)),
+			grpc.StreamInterceptor(apiKeyStreamInterceptor(*apiKey)),
+		)
+	}
+	grpcServer := grpc.NewServer(grpcOpts...)
 	eventsv1.RegisterEventServiceServer(grpcServer, svc)
+	eventstreamv1.RegisterEventStreamServiceServer(grpcServer, svc)
+	healthpb.RegisterHealthServer(grpcServer, svc.health)
 	reflection.Register(grpcServer)
 
 	lis, err := net.Listen("tcp", *grpcAddr)
@@ -45,50 +284,170 @@ func main() {
 		os.Exit(1)
 	}
 
+	svc.SetServing(true)
 	go func() {
-		logger.Info("gRPC server listening", "addr", *grpcAddr)
+		logger.Info("gRPC server listening", "addr", *grpcAddr, "tls", grpcTLSConfig != nil, "mtls", *grpcClientCA != "")
 		if err := grpcServer.Serve(lis); err != nil {
 			logger.Error("gRPC server error", "error", err)
 		}
 	}()
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /events", svc.HandleListEvents)
-	mux.HandleFunc("GET /events/stats", svc.HandleStats)
-	mux.HandleFunc("DELETE /events", svc.HandleClearEvents)
+	var handler http.Handler = newMux(svc)
+	if *authToken != "" {
+		handler = requireBearerToken(*authToken, handler)
+	}
+	if *corsOrigin != "" {
+		handler = cors(strings.Split(*corsOrigin, ","), corsMethods, handler)
+	}
+	handler = withRequestID(accessLog(logger, accessLevel, handler))
 
 	httpServer := &http.Server{
 		Addr:         *httpAddr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 		IdleTimeout:  30 * time.Second,
+		TLSConfig:    tlsConfig,
 	}
+	// Event streams only end when their client goes away; end them when
+	// shutdown starts so that it needn't wait for them.
+	httpServer.RegisterOnShutdown(svc.CloseStreams)
 
 	go func() {
-		logger.Info("HTTP server listening", "addr", *httpAddr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("HTTP server listening", "addr", *httpAddr, "tls", tlsConfig != nil)
+		if err := listenAndServe(httpServer); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP server error", "error", err)
 		}
 	}()
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	var pprofServer *http.Server
+	if *pprofAddr != "" {
+		pprofServer = eventcore.NewPprofServer(*pprofAddr)
+		go func() {
+			logger.Info("pprof server listening", "addr", pprofServer.Addr)
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("pprof server error", "error", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
 	logger.Info("shutting down...")
-	grpcServer.GracefulStop()
+	svc.SetServing(false)
+	if !gracefulStop(grpcServer, *shutdownTimeout) {
+		logger.Warn("gRPC graceful stop timed out, closed open RPCs", "shutdown_timeout", *shutdownTimeout)
+	}
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
 	_ = httpServer.Shutdown(shutdownCtx)
+	cancel()
+	if pprofServer != nil {
+		// Profiles in progress aren't worth waiting for.
+		_ = pprofServer.Close()
+	}
+	if kafkaOut != nil {
+		if err := kafkaOut.Close(); err != nil {
+			logger.Error("failed to close kafka writer", "error", err)
+		}
+	}
+	if s3Out != nil {
+		s3Ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		if err := s3Out.Close(s3Ctx); err != nil {
+			logger.Error("failed to upload events to s3", "error", err)
+		}
+		cancel()
+	}
+	if alertOut != nil {
+		alertOut.Close()
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	if err := svc.Flush(flushCtx); err != nil {
+		logger.Error("failed to flush events", "error", err)
+	}
+	cancel()
+	background.Wait()
+	if tracerProvider != nil {
+		traceCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		if err := tracerProvider.Shutdown(traceCtx); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+		cancel()
+	}
 
 	logger.Info("stopped")
 }
 
+// gracefulStop stops server gracefully, waiting for open RPCs to finish,
+// but gives up after timeout and stops it forcibly, since GracefulStop
+// alone waits forever on a stream its client never closes. It reports
+// whether the graceful stop completed in time.
+func gracefulStop(server *grpc.Server, timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return true
+	case <-timer.C:
+		server.Stop()
+		<-stopped
+		return false
+	}
+}
+
+// listenAndServe serves HTTPS when the server has a TLS config and plain
+// HTTP otherwise.
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+func envOrDefaultInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func envOrDefaultBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func envOrDefaultFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func envOrDefaultDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
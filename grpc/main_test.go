@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEnvOrDefaultDuration(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultShutdownTimeout},
+		{"30s", 30 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"30", defaultShutdownTimeout},
+		{"soon", defaultShutdownTimeout},
+	} {
+		t.Setenv("SHUTDOWN_TIMEOUT", tc.value)
+		if got := envOrDefaultDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout); got != tc.want {
+			t.Errorf("SHUTDOWN_TIMEOUT=%q: expected %s, got %s", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
+	t.Run("idle", func(t *testing.T) {
+		srv, _ := serveBufconn(t, testService())
+		if !gracefulStop(srv, 5*time.Second) {
+			t.Error("expected an idle server to stop gracefully")
+		}
+	})
+
+	t.Run("stuck stream", func(t *testing.T) {
+		svc := testService()
+		srv, conn := serveBufconn(t, svc)
+		// A subscription lasts until its client goes away, which this one
+		// never does.
+		stream, err := eventstreamv1.NewEventStreamServiceClient(conn).SubscribeEvents(context.Background(), &eventstreamv1.SubscribeEventsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, func() bool { return svc.Subscribers() == 1 })
+
+		start := time.Now()
+		if gracefulStop(srv, 100*time.Millisecond) {
+			t.Error("expected the graceful stop to time out on the open stream")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the server forced down soon after the timeout, took %s", elapsed)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+			t.Errorf("expected the stream cut off with Unavailable, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requireBearerToken rejects requests whose Authorization header does not
+// carry "Bearer <token>" with 401 Unauthorized.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	want := sha256.Sum256([]byte(token))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, got, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		// Comparing digests keeps the comparison constant-time even when
+		// the lengths differ.
+		sum := sha256.Sum256([]byte(got))
+		if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="events"`)
+			eventcore.WriteJSON(w, http.StatusUnauthorized, eventcore.ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsMethods are the methods of the API's routes, which browsers may use
+// cross-origin once a preflight allows them.
+const corsMethods = "GET, POST, DELETE"
+
+// corsHeaders are the request headers browsers may send cross-origin.
+const corsHeaders = "Authorization, Content-Type, X-Request-ID"
+
+// corsExposedHeaders are the response headers cross-origin scripts may read.
+const corsExposedHeaders = "ETag, X-Applied-Limit, X-Next-Cursor, X-Request-ID, X-Total-Count"
+
+// cors adds CORS headers for requests from one of origins ("*" allows any
+// origin) and answers preflight OPTIONS requests itself with 204, before
+// they reach authentication. methods is the Access-Control-Allow-Methods
+// value.
+func cors(origins []string, methods string, next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(origins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(anyOrigin || slices.Contains(origins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", corsHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDHeader carries the identifier that correlates a request across
+// client and server logs.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the client-supplied request IDs that are kept.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID echoes the request's X-Request-ID header in the response,
+// generating a UUID when it is missing or not a short printable ASCII
+// string, and makes it available to later handlers via requestIDFrom.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDFrom returns the request ID withRequestID stored in ctx, or "".
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// accessLog logs one record per request at level, with the method, path,
+// status code, duration and response bytes, plus the request ID when
+// withRequestID runs first.
+func accessLog(logger *slog.Logger, level slog.Level, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int64("bytes", rec.bytes),
+		}
+		if id := requestIDFrom(r.Context()); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		logger.LogAttrs(r.Context(), level, "http request", attrs...)
+	})
+}
+
+// statusRecorder captures the status code and body size written through
+// it. Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush.
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func (r *statusRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+// apiKeyMetadata is the gRPC metadata key carrying the API key.
+const apiKeyMetadata = "x-api-key"
+
+// apiKeyExempt reports whether a method is reachable without an API key.
+// Health checks are, so that load balancers and meshes can probe the
+// server without credentials.
+func apiKeyExempt(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/")
+}
+
+// apiKeyInterceptor rejects unary calls whose x-api-key metadata does not
+// match key with codes.Unauthenticated.
+func apiKeyInterceptor(key string) grpc.UnaryServerInterceptor {
+	check := apiKeyChecker(key)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if apiKeyExempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if err := check(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// apiKeyStreamInterceptor is the streaming counterpart of apiKeyInterceptor.
+func apiKeyStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	check := apiKeyChecker(key)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if apiKeyExempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		if err := check(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func apiKeyChecker(key string) func(context.Context) error {
+	want := sha256.Sum256([]byte(key))
+	return func(ctx context.Context) error {
+		var got string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(apiKeyMetadata); len(v) > 0 {
+				got = v[0]
+			}
+		}
+		sum := sha256.Sum256([]byte(got))
+		if got == "" || subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+			return status.Error(codes.Unauthenticated, "missing or invalid API key")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequireBearerToken(t *testing.T) {
+	handler := requireBearerToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"token prefix", "Bearer s3cre", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"correct", "Bearer s3cret", http.StatusNoContent},
+		{"lowercase scheme", "bearer s3cret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/events", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, w.Code)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestAPIKeyInterceptor(t *testing.T) {
+	svc := testService()
+	conn := dialBufconn(t, svc,
+		grpc.UnaryInterceptor(apiKeyInterceptor("s3cret")),
+		grpc.StreamInterceptor(apiKeyStreamInterceptor("s3cret")),
+	)
+	client := eventsv1.NewEventServiceClient(conn)
+	req := &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)}
+
+	tests := []struct {
+		name string
+		key  string
+		want codes.Code
+	}{
+		{"missing", "", codes.Unauthenticated},
+		{"wrong", "nope", codes.Unauthenticated},
+		{"correct", "s3cret", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.key != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, tt.key)
+			}
+			_, err := client.PublishEvents(ctx, req)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("expected %v, got %v (%v)", tt.want, got, err)
+			}
+		})
+	}
+	if got := svc.Core().Totals().Received; got != 1 {
+		t.Errorf("expected only the authenticated publish to be stored, got totalReceived=%d", got)
+	}
+
+	stream, err := eventstreamv1.NewEventStreamServiceClient(conn).SubscribeEvents(context.Background(), &eventstreamv1.SubscribeEventsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated for unauthenticated subscribe, got %v", err)
+	}
+}
+
+func TestCORSMethodsCoverRoutes(t *testing.T) {
+	for _, ep := range apiDoc.Endpoints {
+		if !slices.Contains(strings.Split(corsMethods, ", "), ep.Method) {
+			t.Errorf("%s %s: method missing from corsMethods %q", ep.Method, ep.Path, corsMethods)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	var reached bool
+	handler := cors([]string{"https://dash.example.com"}, "GET, DELETE", requireBearerToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	t.Run("preflight", func(t *testing.T) {
+		reached = false
+		req := httptest.NewRequest("OPTIONS", "/events", nil)
+		req.Header.Set("Origin", "https://dash.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204 without credentials, got %d", w.Code)
+		}
+		if reached {
+			t.Error("expected preflight not to reach the handler")
+		}
+		h := w.Header()
+		if h.Get("Access-Control-Allow-Origin") != "https://dash.example.com" ||
+			h.Get("Access-Control-Allow-Methods") != "GET, DELETE" ||
+			!strings.Contains(h.Get("Access-Control-Allow-Headers"), "Authorization") {
+			t.Errorf("unexpected preflight headers: %v", h)
+		}
+	})
+
+	t.Run("simple GET", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/events", nil)
+		req.Header.Set("Origin", "https://dash.example.com")
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+			t.Errorf("expected Access-Control-Allow-Origin to echo the origin, got %q", got)
+		}
+		if got := w.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("expected Vary: Origin, got %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Total-Count") {
+			t.Errorf("expected X-Total-Count to be exposed, got %q", got)
+		}
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/events", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no CORS headers for an unlisted origin, got %q", got)
+		}
+	})
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	handler := cors([]string{"*"}, "GET", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+}
+
+// captureHandler is a slog.Handler that keeps every record it handles at
+// or above level, or every record when level is nil.
+type captureHandler struct {
+	level   slog.Leveler
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return h.level == nil || l >= h.level.Level()
+}
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler      { return h }
+
+func recordAttrs(r slog.Record) map[string]slog.Value {
+	attrs := make(map[string]slog.Value)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	return attrs
+}
+
+func TestAccessLog(t *testing.T) {
+	capture := &captureHandler{}
+	handler := accessLog(slog.New(capture), slog.LevelDebug, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events?x=1", nil))
+
+	if len(capture.records) != 1 {
+		t.Fatalf("expected 1 log record, got %d", len(capture.records))
+	}
+	rec := capture.records[0]
+	if rec.Level != slog.LevelDebug {
+		t.Errorf("expected level DEBUG, got %v", rec.Level)
+	}
+	attrs := recordAttrs(rec)
+	if attrs["method"].String() != "DELETE" || attrs["path"].String() != "/events" ||
+		attrs["status"].Int64() != http.StatusTeapot || attrs["bytes"].Int64() != 5 {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if _, ok := attrs["duration"]; !ok {
+		t.Error("expected a duration attribute")
+	}
+}
+
+func TestAccessLog_ImplicitOK(t *testing.T) {
+	capture := &captureHandler{}
+	handler := accessLog(slog.New(capture), slog.LevelInfo, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.NewResponseController(w).Flush()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got := recordAttrs(capture.records[0])["status"].Int64(); got != http.StatusOK {
+		t.Errorf("expected status 200, got %d", got)
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	capture := &captureHandler{}
+	handler := withRequestID(accessLog(slog.New(capture), slog.LevelInfo, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{"echoed", "client-req-42", false},
+		{"missing", "", true},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), true},
+		{"control characters", "abc\ndef", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture.records = nil
+			req := httptest.NewRequest("GET", "/events", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.generate {
+				if _, err := uuid.Parse(got); err != nil {
+					t.Errorf("expected a generated UUID, got %q", got)
+				}
+			} else if got != tt.header {
+				t.Errorf("expected %q echoed, got %q", tt.header, got)
+			}
+			if len(capture.records) != 1 {
+				t.Fatalf("expected 1 log record, got %d", len(capture.records))
+			}
+			if logged := recordAttrs(capture.records[0])["request_id"].String(); logged != got {
+				t.Errorf("expected request_id %q in the access log, got %q", got, logged)
+			}
+		})
+	}
+}
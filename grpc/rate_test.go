@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+func TestRateStats(t *testing.T) {
+	now := time.Date(2026, 2, 16, 21, 30, 0, 0, time.UTC)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithClock(newFakeClock(now)))
+
+	rates := func(query string) RateStats {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleRateStats(w, httptest.NewRequest("GET", "/events/stats/rate?"+query, nil))
+		var got RateStats
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if got := rates(""); got != (RateStats{}) {
+		t.Errorf("expected zero rates with no events, got %+v", got)
+	}
+
+	var events []*eventsv1.UsageEvent
+	add := func(age time.Duration, count int, tenant string) {
+		for range count {
+			events = append(events, &eventsv1.UsageEvent{Key: "k", TenantKey: tenant, Timestamp: now.Add(-age).Format(time.RFC3339)})
+		}
+	}
+	add(30*time.Second, 3, "tenant-a")
+	add(2*time.Minute, 6, "tenant-b")
+	add(10*time.Minute, 9, "tenant-a")
+	add(20*time.Minute, 5, "tenant-a")
+	add(-time.Minute, 2, "tenant-a")
+	events = append(events, &eventsv1.UsageEvent{Key: "k", Timestamp: "not a time"})
+	svc.storage.Append(context.Background(), events)
+
+	want := RateStats{OneMinute: 3.0 / 60, FiveMinutes: 9.0 / 300, FifteenMinutes: 18.0 / 900}
+	if got := rates(""); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	want = RateStats{OneMinute: 3.0 / 60, FiveMinutes: 3.0 / 300, FifteenMinutes: 12.0 / 900}
+	if got := rates("tenant_key=tenant-a"); got != want {
+		t.Errorf("tenant-a: expected %+v, got %+v", want, got)
+	}
+}
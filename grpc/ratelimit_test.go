@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestPublishEvents_RateLimit(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithRateLimit(1, 2))
+	now := time.Unix(0, 0)
+	svc.limiter.Now = func() time.Time { return now }
+
+	publishFrom := func(ip string, port int) codes.Code {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port}})
+		_, err := svc.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)})
+		return status.Code(err)
+	}
+
+	for i := range 2 {
+		if code := publishFrom("10.0.0.1", 1000); code != codes.OK {
+			t.Fatalf("publish %d: expected OK, got %v", i, code)
+		}
+	}
+	// Another port on the same host shares the bucket.
+	if code := publishFrom("10.0.0.1", 2000); code != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted once the burst is spent, got %v", code)
+	}
+	if code := publishFrom("10.0.0.2", 1000); code != codes.OK {
+		t.Errorf("expected another source to be allowed, got %v", code)
+	}
+
+	now = now.Add(time.Second)
+	if code := publishFrom("10.0.0.1", 1000); code != codes.OK {
+		t.Errorf("expected OK after the bucket refilled, got %v", code)
+	}
+	if got := svc.Core().Totals().Received; got != 4 {
+		t.Errorf("expected limited publishes not to be counted, got total_received %d", got)
+	}
+}
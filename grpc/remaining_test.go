@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+func TestRemainingStats(t *testing.T) {
+	svc := testService()
+	remaining := func(query string) RemainingStats {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleRemainingStats(w, httptest.NewRequest("GET", "/events/stats/remaining?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var got RemainingStats
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	counts := func(stats RemainingStats) []int {
+		out := make([]int, len(stats.Buckets))
+		for i, b := range stats.Buckets {
+			out[i] = b.Count
+		}
+		return out
+	}
+
+	empty := remaining("")
+	if got := counts(empty); !reflect.DeepEqual(got, []int{0, 0, 0, 0, 0}) || empty.Buckets[0].Range != "0-10%" || empty.Buckets[4].Range != "75-100%" {
+		t.Errorf("expected the five buckets empty, got %+v", empty)
+	}
+
+	var events []*eventsv1.UsageEvent
+	add := func(allowed bool, remaining, limit int64, tenant string) {
+		events = append(events, &eventsv1.UsageEvent{Key: "k", Method: "GET", Path: "/", Allowed: allowed, Remaining: remaining, Limit: limit, TenantKey: tenant})
+	}
+	// Each bucket includes its lower bound and excludes its upper one.
+	for _, r := range []int64{0, 9, 10, 24, 25, 49, 50, 74, 75, 100} {
+		add(true, r, 100, "tenant-a")
+	}
+	add(true, 1, 3, "tenant-b")
+	add(true, 5, 0, "tenant-b")
+	add(false, 50, 100, "tenant-b")
+	svc.storage.Append(context.Background(), events)
+
+	got := remaining("")
+	if want := []int{2, 2, 3, 2, 2}; !reflect.DeepEqual(counts(got), want) || got.Total != 11 || got.NoLimit != 1 {
+		t.Errorf("expected buckets %v over 11 events and 1 without a limit, got %+v", want, got)
+	}
+	got = remaining("tenant_key=tenant-b")
+	if want := []int{0, 0, 1, 0, 0}; !reflect.DeepEqual(counts(got), want) || got.Total != 1 {
+		t.Errorf("expected only tenant-b's allowed event with a limit, got %+v", got)
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleRemainingStats(w, httptest.NewRequest("GET", "/events/stats/remaining?allowed=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid filter, got %d", w.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	defaultReplayBatch = 100
+	maxReplayBatch     = 1000
+	// maxReplayBodyBytes caps the POST /events/replay body, which only
+	// holds the replay settings.
+	maxReplayBodyBytes = 64 << 10
+	// replayTimeout bounds each batch sent to a replay target.
+	replayTimeout = 10 * time.Second
+	// maxReplayRate caps the rate of a replay, in events per second.
+	maxReplayRate = 1e6
+	// maxReplayInterval caps the wait between the batches of a paced
+	// replay, however low its rate.
+	maxReplayInterval = time.Hour
+)
+
+// replayRequest is the body of POST /events/replay.
+type replayRequest struct {
+	// Target is the host:port of the gRPC EventService each batch is sent
+	// to with PublishEvents, over plaintext.
+	Target string `json:"target"`
+	// BatchSize is the number of events per request; zero means 100.
+	BatchSize int `json:"batch_size"`
+	// Rate caps the events sent per second; zero sends batches back to back.
+	Rate float64 `json:"rate"`
+}
+
+// replayResponse summarizes a replay. Error is set when the target failed
+// a batch, and Replayed then counts the events sent before it.
+type replayResponse struct {
+	Replayed int    `json:"replayed"`
+	Batches  int    `json:"batches"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HandleReplayEvents re-publishes the stored events matching the list
+// filters to a target, oldest first, in PublishEventsRequest batches, so
+// the service can feed recorded traffic to a downstream consumer under
+// test. The target's host must be in the -replay-allow list, so the
+// endpoint can't be used to reach arbitrary hosts. It answers once every
+// batch has been sent, with 502 and the
+// partial count if the target fails one.
+func (s *EventService) HandleReplayEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := eventcore.ParseFilter(r.URL.Query())
+	if err != nil {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: err.Error()})
+		return
+	}
+	var req replayRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReplayBodyBytes)).Decode(&req); err != nil {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	if host, port, err := net.SplitHostPort(req.Target); err != nil || host == "" || !isPort(port) {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "target must be host:port"})
+		return
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = defaultReplayBatch
+	}
+	if req.BatchSize < 0 || req.BatchSize > maxReplayBatch {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("batch_size must be between 1 and %d", maxReplayBatch)})
+		return
+	}
+	if !(req.Rate >= 0 && req.Rate <= maxReplayRate) {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("rate must be between 0 and %g", float64(maxReplayRate))})
+		return
+	}
+	if !replayAllowed(s.replayAllow, req.Target) {
+		eventcore.WriteJSON(w, http.StatusForbidden, eventcore.ErrorResponse{Error: "target is not in -replay-allow"})
+		return
+	}
+
+	events, err := s.Matching(r.Context(), filter)
+	if err != nil {
+		s.logger.Error("failed to read events to replay", "error", err)
+		eventcore.WriteJSON(w, http.StatusInternalServerError, eventcore.ErrorResponse{Error: "failed to read events"})
+		return
+	}
+
+	// A paced replay can outlast the server's WriteTimeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp, err := s.replay(r.Context(), req, events)
+	if err != nil {
+		s.logger.Warn("replay failed", "target", req.Target, "replayed", resp.Replayed, "error", err)
+		resp.Error = err.Error()
+		eventcore.WriteJSON(w, http.StatusBadGateway, resp)
+		return
+	}
+	s.logger.Info("events replayed", "target", req.Target, "count", resp.Replayed, "batches", resp.Batches)
+	eventcore.WriteJSON(w, http.StatusOK, resp)
+}
+
+// replay sends events to req.Target in batches, waiting between batches
+// as needed to keep to req.Rate. It stops at the first failed batch.
+// replayInterval returns the wait between batches of size events that
+// keeps to rate events per second, kept between a nanosecond and
+// maxReplayInterval so that the ticker can always be made.
+func replayInterval(size int, rate float64) time.Duration {
+	secs := float64(size) / rate
+	if secs >= maxReplayInterval.Seconds() {
+		return maxReplayInterval
+	}
+	return max(time.Duration(secs*float64(time.Second)), time.Nanosecond)
+}
+
+func (s *EventService) replay(ctx context.Context, req replayRequest, events []*eventsv1.UsageEvent) (replayResponse, error) {
+	var resp replayResponse
+	var pace eventcore.Ticker
+	if req.Rate > 0 {
+		pace = s.clock.NewTicker(replayInterval(req.BatchSize, req.Rate))
+		defer pace.Stop()
+	}
+	conn, err := grpc.NewClient(req.Target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	client := eventsv1.NewEventServiceClient(conn)
+	for batch := range slices.Chunk(events, req.BatchSize) {
+		if pace != nil && resp.Batches > 0 {
+			select {
+			case <-ctx.Done():
+				return resp, ctx.Err()
+			case <-pace.C():
+			}
+		}
+		callCtx, cancel := context.WithTimeout(ctx, replayTimeout)
+		_, err := client.PublishEvents(callCtx, &eventsv1.PublishEventsRequest{Events: batch})
+		cancel()
+		if err != nil {
+			return resp, err
+		}
+		resp.Replayed += len(batch)
+		resp.Batches++
+	}
+	return resp, nil
+}
+
+// isPort reports whether s is a decimal TCP port number.
+func isPort(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+	return err == nil
+}
+
+// replayAllowed reports whether a replay may be sent to hostport, which
+// must be one of the hosts in allow, given either as a bare host name or
+// IP, matching any port, or as host:port.
+func replayAllowed(allow []string, hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	for _, a := range allow {
+		if strings.EqualFold(a, hostport) || strings.EqualFold(a, host) {
+			return true
+		}
+	}
+	return false
+}
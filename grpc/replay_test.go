@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// serveReplayTarget serves srv over gRPC on a loopback port and returns
+// its address.
+func serveReplayTarget(t *testing.T, srv eventsv1.EventServiceServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	eventsv1.RegisterEventServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// batchRecorder is a replay target that sends the size of each batch it
+// receives on got, and fails every call after the first failAfter.
+type batchRecorder struct {
+	eventsv1.UnimplementedEventServiceServer
+	got       chan int
+	calls     atomic.Int32
+	failAfter int32
+}
+
+func (b *batchRecorder) PublishEvents(_ context.Context, req *eventsv1.PublishEventsRequest) (*eventsv1.PublishEventsResponse, error) {
+	if n := b.calls.Add(1); b.failAfter > 0 && n > b.failAfter {
+		return nil, status.Error(codes.Unavailable, "target down")
+	}
+	if b.got != nil {
+		b.got <- len(req.GetEvents())
+	}
+	return &eventsv1.PublishEventsResponse{Accepted: int64(len(req.GetEvents()))}, nil
+}
+
+func replayRequestTo(t *testing.T, svc *EventService, query, body string) (*httptest.ResponseRecorder, replayResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	svc.HandleReplayEvents(w, httptest.NewRequest("POST", "/events/replay?"+query, strings.NewReader(body)))
+	var resp replayResponse
+	if w.Code == http.StatusOK || w.Code == http.StatusBadGateway {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w, resp
+}
+
+// replayService returns a service allowed to replay to loopback targets.
+func replayService(opts ...Option) *EventService {
+	opts = append([]Option{WithReplayAllow([]string{"127.0.0.1"})}, opts...)
+	return NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), opts...)
+}
+
+func TestReplayEvents(t *testing.T) {
+	target := testService()
+	addr := serveReplayTarget(t, target)
+
+	svc := replayService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(5, 3)})
+
+	w, resp := replayRequestTo(t, svc, "allowed=true", `{"target": "`+addr+`", "batch_size": 2}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp != (replayResponse{Replayed: 5, Batches: 3}) {
+		t.Errorf("expected 5 events in 3 batches, got %+v", resp)
+	}
+	got, want := target.StoredEvents(), makeEvents(5, 0)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events replayed, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	w, resp = replayRequestTo(t, svc, "", `{"target": "`+addr+`"}`)
+	if w.Code != http.StatusOK || resp != (replayResponse{Replayed: 8, Batches: 1}) {
+		t.Errorf("expected all 8 events in one default-sized batch, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestReplayEvents_TargetFails(t *testing.T) {
+	target := &batchRecorder{failAfter: 1}
+	addr := serveReplayTarget(t, target)
+
+	svc := replayService()
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(5, 0)})
+	w, resp := replayRequestTo(t, svc, "", `{"target": "`+addr+`", "batch_size": 2}`)
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", w.Code)
+	}
+	if resp.Replayed != 2 || resp.Batches != 1 || !strings.Contains(resp.Error, "target down") {
+		t.Errorf("expected the first batch counted and the target's error reported, got %+v", resp)
+	}
+	if n := target.calls.Load(); n != 2 {
+		t.Errorf("expected the replay to stop at the failed batch, target called %d times", n)
+	}
+}
+
+func TestReplayEvents_Rate(t *testing.T) {
+	target := &batchRecorder{got: make(chan int, 10)}
+	addr := serveReplayTarget(t, target)
+
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	svc := replayService(WithClock(clock))
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(5, 0)})
+
+	done := make(chan replayResponse)
+	go func() {
+		_, resp := replayRequestTo(t, svc, "", `{"target": "`+addr+`", "batch_size": 2, "rate": 2}`)
+		done <- resp
+	}()
+
+	// Two events at two per second is a batch a second.
+	clock.waitForTickers(1)
+	<-target.got
+	select {
+	case <-target.got:
+		t.Fatal("second batch sent before the clock advanced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	<-target.got
+	clock.Advance(time.Second)
+	if n := <-target.got; n != 1 {
+		t.Errorf("expected the last batch to hold the remaining event, got %d", n)
+	}
+	if resp := <-done; resp != (replayResponse{Replayed: 5, Batches: 3}) {
+		t.Errorf("expected 5 events in 3 batches, got %+v", resp)
+	}
+}
+
+func TestReplayInterval(t *testing.T) {
+	for _, tc := range []struct {
+		size int
+		rate float64
+		want time.Duration
+	}{
+		{2, 2, time.Second},
+		{1, maxReplayRate, time.Microsecond},
+		{1, 1e300, time.Nanosecond},
+		{1000, 1e-20, maxReplayInterval},
+	} {
+		if got := replayInterval(tc.size, tc.rate); got != tc.want {
+			t.Errorf("%d events at %g/s: expected %v, got %v", tc.size, tc.rate, tc.want, got)
+		}
+	}
+}
+
+func TestReplayEvents_BadRequest(t *testing.T) {
+	svc := testService()
+	for _, tc := range []struct{ query, body string }{
+		{"", `not json`},
+		{"", `{}`},
+		{"", `{"target": "unix:///tmp/events.sock"}`},
+		{"", `{"target": "localhost:50053", "batch_size": -1}`},
+		{"", `{"target": "localhost:50053", "batch_size": 1001}`},
+		{"", `{"target": "localhost:50053", "rate": -1}`},
+		{"", `{"target": "localhost:50053", "rate": 1e300}`},
+		{"allowed=maybe", `{"target": "localhost:50053"}`},
+	} {
+		if w, _ := replayRequestTo(t, svc, tc.query, tc.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d", tc.query, tc.body, w.Code)
+		}
+	}
+}
+
+func TestReplayEvents_NotAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		allow []string
+		body  string
+	}{
+		{nil, `{"target": "127.0.0.1:9000"}`},
+		{[]string{"127.0.0.1"}, `{"target": "10.0.0.1:50053"}`},
+		{[]string{"127.0.0.1:8000"}, `{"target": "127.0.0.1:9000"}`},
+		{[]string{"internal.example:50054"}, `{"target": "internal.example:50053"}`},
+	} {
+		svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithReplayAllow(tc.allow))
+		if w, _ := replayRequestTo(t, svc, "", tc.body); w.Code != http.StatusForbidden {
+			t.Errorf("%v %s: expected 403, got %d", tc.allow, tc.body, w.Code)
+		}
+	}
+}
+
+func TestReplayAllowed(t *testing.T) {
+	allow := []string{"127.0.0.1", "Events.Example:8080"}
+	for hostport, want := range map[string]bool{
+		"127.0.0.1":           true,
+		"127.0.0.1:9000":      true,
+		"events.example:8080": true,
+		"events.example:8081": false,
+		"events.example":      false,
+		"10.0.0.1:8080":       false,
+	} {
+		if got := replayAllowed(allow, hostport); got != want {
+			t.Errorf("replayAllowed(%q) = %v, want %v", hostport, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMux_MethodNotAllowed(t *testing.T) {
+	mux := newMux(testService())
+
+	tests := []struct {
+		method, path string
+		allow        []string
+	}{
+		{"POST", "/events", []string{"GET", "HEAD", "DELETE"}},
+		{"OPTIONS", "/events", []string{"GET", "DELETE"}},
+		{"POST", "/events/count", []string{"GET", "HEAD"}},
+		{"POST", "/events/export.csv", []string{"GET", "HEAD"}},
+		{"POST", "/events/stats", []string{"GET", "HEAD"}},
+		{"DELETE", "/events/stats/by-tenant", []string{"GET", "HEAD"}},
+		{"GET", "/events/stats/reset", []string{"POST"}},
+		{"POST", "/events/tenants", []string{"GET", "HEAD"}},
+		{"POST", "/events/stream", []string{"GET", "HEAD"}},
+		{"POST", "/metrics", []string{"GET", "HEAD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("expected 405, got %d", w.Code)
+			}
+			allow := w.Header().Get("Allow")
+			for _, m := range tt.allow {
+				if !strings.Contains(allow, m) {
+					t.Errorf("expected Allow %q to contain %s", allow, m)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown path, got %d", w.Code)
+	}
+}
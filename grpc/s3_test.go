@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// uploadedObject is an object received by fakeUploader, with its body
+// decompressed and decoded.
+type uploadedObject struct {
+	bucket, key, contentEncoding string
+	events                       []*eventsv1.UsageEvent
+}
+
+// fakeUploader records uploaded objects.
+type fakeUploader struct {
+	mu      sync.Mutex
+	objects []uploadedObject
+}
+
+func (f *fakeUploader) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	obj := uploadedObject{bucket: *in.Bucket, key: *in.Key, contentEncoding: *in.ContentEncoding}
+	zr, err := gzip.NewReader(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		ev := &eventsv1.UsageEvent{}
+		if err := json.Unmarshal(sc.Bytes(), ev); err != nil {
+			return nil, err
+		}
+		obj.events = append(obj.events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects = append(f.objects, obj)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeUploader) uploaded() []uploadedObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]uploadedObject(nil), f.objects...)
+}
+
+func objectKeys(objects []uploadedObject) (keys []string, sizes []int) {
+	for _, obj := range objects {
+		keys = append(keys, obj.key)
+		sizes = append(sizes, len(obj.events))
+	}
+	return keys, sizes
+}
+
+// hangingUploader is an S3 endpoint that never answers.
+type hangingUploader struct{}
+
+func (hangingUploader) PutObject(ctx context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestS3Sink_CloseTimeout(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	sink := newS3Sink(slog.Default(), hangingUploader{}, "archive", "", defaultS3FlushEvents, time.Minute, clock)
+	sink.Publish(makeEvents(1, 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- sink.Close(ctx) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Close to give up on the upload at its deadline")
+	}
+}
+
+func TestS3Sink_FlushEvents(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	up := &fakeUploader{}
+	sink := newS3Sink(slog.Default(), up, "archive", "usage/events/", 2, time.Minute, clock)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithSink(sink))
+
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(3, 2)})
+	sink.Close(context.Background())
+	sink.Publish(makeEvents(1, 0)) // dropped, and mustn't panic
+
+	objects := up.uploaded()
+	keys, sizes := objectKeys(objects)
+	wantKeys := []string{
+		"usage/events/2026/02/16/20260216T210000.000Z-000001.ndjson.gz",
+		"usage/events/2026/02/16/20260216T210000.000Z-000002.ndjson.gz",
+		"usage/events/2026/02/16/20260216T210000.000Z-000003.ndjson.gz",
+	}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Fatalf("expected keys %v, got %v", wantKeys, keys)
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 2, 1}) {
+		t.Errorf("expected objects of 2, 2 and 1 events (the last flushed on close), got %v", sizes)
+	}
+	var got []*eventsv1.UsageEvent
+	for _, obj := range objects {
+		if obj.bucket != "archive" || obj.contentEncoding != "gzip" {
+			t.Errorf("%s: unexpected bucket %q or content encoding %q", obj.key, obj.bucket, obj.contentEncoding)
+		}
+		got = append(got, obj.events...)
+	}
+	want := makeEvents(3, 2)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events archived, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestS3Sink_FlushInterval(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	up := &fakeUploader{}
+	sink := newS3Sink(slog.Default(), up, "archive", "", defaultS3FlushEvents, time.Minute, clock)
+	defer sink.Close(context.Background())
+
+	sink.Publish(makeEvents(1, 1))
+	clock.Advance(30 * time.Second)
+	clock.Advance(30 * time.Second)
+	// The second tick is taken only once the first one's upload is done.
+	clock.Advance(time.Minute)
+
+	keys, sizes := objectKeys(up.uploaded())
+	if want := []string{"2026/02/16/20260216T210100.000Z-000001.ndjson.gz"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected one object %v from the first tick and none from an empty one, got %v", want, keys)
+	}
+	if sizes[0] != 2 {
+		t.Errorf("expected both events in the object, got %d", sizes[0])
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+func TestPublishEvents_SampleRate(t *testing.T) {
+	const total, rate = 4000, 0.25
+	svc := NewEventService(slog.Default(), newMemoryStore(0), WithSampleRate(rate))
+	batch := make([]*eventsv1.UsageEvent, total)
+	for i := range batch {
+		batch[i] = &eventsv1.UsageEvent{Key: "k", Method: "GET", Path: "/", Allowed: i%2 == 0, RequestId: fmt.Sprintf("req-%d", i)}
+	}
+	resp, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != total {
+		t.Errorf("expected all %d events accepted, got %d", total, resp.GetAccepted())
+	}
+
+	if got := svc.Core().Totals().Received; got != total {
+		t.Errorf("expected all %d events counted, got %d", total, got)
+	}
+	stored := svc.StoredEvents()
+	if frac := float64(len(stored)) / total; math.Abs(frac-rate) > 0.05 {
+		t.Errorf("expected about %.0f%% of events stored, got %d of %d", rate*100, len(stored), total)
+	}
+	for _, ev := range stored {
+		if !sampled(sampleID(ev), rate) {
+			t.Fatalf("stored event %s is outside the sample", ev.GetRequestId())
+		}
+	}
+}
+
+func TestSampled_Bounds(t *testing.T) {
+	for _, id := range []string{"", "a", "req-1"} {
+		if sampled(id, 0) {
+			t.Errorf("%q sampled at rate 0", id)
+		}
+		if !sampled(id, 1) {
+			t.Errorf("%q not sampled at rate 1", id)
+		}
+	}
+}
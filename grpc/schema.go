@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// maxSchemaErrors caps how many violations a rejected publish reports.
+const maxSchemaErrors = 100
+
+// eventSchema validates published events against a JSON Schema document.
+// Each event is checked in the JSON form the query API uses, with empty
+// optional fields left out.
+type eventSchema struct {
+	schema *jsonschema.Schema
+}
+
+// loadEventSchema compiles the JSON Schema document at path.
+func loadEventSchema(path string) (*eventSchema, error) {
+	schema, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("compile event schema: %w", err)
+	}
+	return &eventSchema{schema: schema}, nil
+}
+
+// validate returns a description of each schema violation in batch, at
+// most maxSchemaErrors of them, or nil if every event is valid.
+func (s *eventSchema) validate(batch []*eventsv1.UsageEvent) []string {
+	var problems []string
+	for i := range batch {
+		err := s.schema.Validate(eventDocument(batch[i]))
+		var verr *jsonschema.ValidationError
+		if !errors.As(err, &verr) {
+			continue
+		}
+		out := verr.BasicOutput()
+		units := out.Errors
+		if len(units) == 0 {
+			units = []jsonschema.OutputUnit{*out}
+		}
+		for _, u := range units {
+			if u.Error == nil {
+				continue
+			}
+			if len(problems) == maxSchemaErrors {
+				return problems
+			}
+			problems = append(problems, fmt.Sprintf("event %d%s: %s", i, u.InstanceLocation, u.Error))
+		}
+	}
+	return problems
+}
+
+// eventDocument returns ev as the generic JSON value the validator takes.
+// It has the field names of the HTTP variant, so one schema serves both.
+func eventDocument(ev *eventsv1.UsageEvent) map[string]any {
+	doc := map[string]any{
+		"key":         ev.GetKey(),
+		"method":      ev.GetMethod(),
+		"path":        ev.GetPath(),
+		"allowed":     ev.GetAllowed(),
+		"remaining":   ev.GetRemaining(),
+		"limit":       ev.GetLimit(),
+		"timestamp":   ev.GetTimestamp(),
+		"status_code": ev.GetStatusCode(),
+	}
+	if tk := ev.GetTenantKey(); tk != "" {
+		doc["tenant_key"] = tk
+	}
+	if id := ev.GetRequestId(); id != "" {
+		doc["request_id"] = id
+	}
+	return doc
+}
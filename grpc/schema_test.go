@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sampleSchema requires a tenant key and an upper-case method, and limits
+// paths to /api.
+const sampleSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["key", "tenant_key", "method", "path", "timestamp"],
+	"properties": {
+		"tenant_key": {"type": "string", "minLength": 1},
+		"method": {"enum": ["GET", "POST", "PUT", "PATCH", "DELETE"]},
+		"path": {"type": "string", "pattern": "^/api/"},
+		"status_code": {"type": "integer", "minimum": 100, "maximum": 599}
+	}
+}`
+
+func writeSchema(t *testing.T) *eventSchema {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "event.schema.json")
+	if err := os.WriteFile(path, []byte(sampleSchema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := loadEventSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestPublishEvents_Schema(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithSchema(writeSchema(t)))
+	valid := &eventsv1.UsageEvent{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/api/v1", Allowed: true, Timestamp: "2026-02-16T21:00:00Z", StatusCode: 200}
+	if _, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{valid}}); err != nil {
+		t.Fatalf("expected a valid event accepted, got %v", err)
+	}
+
+	invalid := &eventsv1.UsageEvent{Key: "k2", Method: "get", Path: "/health", Timestamp: "2026-02-16T21:00:01Z", StatusCode: 200}
+	_, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{valid, invalid}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	msg := status.Convert(err).Message()
+	for _, want := range []string{"event 1: missing property 'tenant_key'", "event 1/method", "event 1/path"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %q", want, msg)
+		}
+	}
+	if strings.Contains(msg, "event 0") {
+		t.Errorf("expected only the second event reported, got %q", msg)
+	}
+	if n := len(svc.StoredEvents()); n != 1 {
+		t.Errorf("expected the rejected batch left unstored, got %d events", n)
+	}
+}
+
+func TestLoadEventSchema_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`{"type": 12}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadEventSchema(path); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+	if _, err := loadEventSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	defaultMaxConcurrentStreams = 100
+	defaultKeepaliveMinTime     = 30 * time.Second
+
+	// grpcDefaultMsgBytes is grpc-go's own limit on received messages.
+	grpcDefaultMsgBytes = 4 << 20
+	// eventWireBudget is the encoded size allowed per event when sizing the
+	// receive limit from the batch limit. Typical events take a few hundred
+	// bytes.
+	eventWireBudget = 1 << 10
+	// defaultMaxSendMsgBytes bounds the messages the server sends, which
+	// are single events or small responses.
+	defaultMaxSendMsgBytes = 4 << 20
+)
+
+// grpcLimits bounds the resources a single client connection can hold on
+// the gRPC server.
+type grpcLimits struct {
+	// MaxConcurrentStreams caps the RPCs open at once on one connection;
+	// further ones wait for a slot. Zero leaves it unlimited.
+	MaxConcurrentStreams int
+	// KeepaliveMinTime is the shortest interval between client keepalive
+	// pings; a client pinging more often is disconnected.
+	KeepaliveMinTime time.Duration
+	// KeepalivePermitWithoutStream allows keepalive pings on connections
+	// with no open RPC, which idle publishers commonly send.
+	KeepalivePermitWithoutStream bool
+	// MaxRecvMsgSize and MaxSendMsgSize cap the encoded size of a message
+	// in bytes. Zero keeps grpc-go's defaults.
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+}
+
+// recvMsgSizeFor returns a receive limit that fits a full batch of maxBatch
+// events at eventWireBudget bytes each, and never goes below grpc-go's
+// default. With no batch limit it keeps that default.
+func recvMsgSizeFor(maxBatch int) int {
+	return max(maxBatch*eventWireBudget, grpcDefaultMsgBytes)
+}
+
+// serverOptions returns the grpc.NewServer options enforcing l.
+func (l grpcLimits) serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             l.KeepaliveMinTime,
+			PermitWithoutStream: l.KeepalivePermitWithoutStream,
+		}),
+	}
+	if l.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(l.MaxConcurrentStreams)))
+	}
+	if l.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(l.MaxRecvMsgSize))
+	}
+	if l.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(l.MaxSendMsgSize))
+	}
+	return opts
+}
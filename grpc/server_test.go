@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventstreamv1 "github.com/edgequota/external-events-template/grpc/gen/eventstream/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCLimits_ServerOptions(t *testing.T) {
+	limits := grpcLimits{
+		MaxConcurrentStreams: defaultMaxConcurrentStreams,
+		KeepaliveMinTime:     defaultKeepaliveMinTime,
+	}
+	if n := len(limits.serverOptions()); n != 2 {
+		t.Errorf("expected keepalive enforcement and a stream cap, got %d options", n)
+	}
+	limits.MaxConcurrentStreams = 0
+	if n := len(limits.serverOptions()); n != 1 {
+		t.Errorf("expected no stream cap with MaxConcurrentStreams 0, got %d options", n)
+	}
+}
+
+func TestGRPCLimits_MaxConcurrentStreams(t *testing.T) {
+	svc := testService()
+	limits := grpcLimits{MaxConcurrentStreams: 1, KeepaliveMinTime: defaultKeepaliveMinTime}
+	conn := dialBufconn(t, svc, limits.serverOptions()...)
+	client := eventstreamv1.NewEventStreamServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := client.SubscribeEvents(ctx, &eventstreamv1.SubscribeEventsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return svc.Subscribers() == 1 })
+
+	// The connection's only stream is taken, so a second one waits.
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer waitCancel()
+	stream, err := client.SubscribeEvents(waitCtx, &eventstreamv1.SubscribeEventsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected the second stream to wait for a slot until its deadline, got %v", err)
+	}
+	if n := svc.Subscribers(); n != 1 {
+		t.Errorf("expected only the first stream served, got %d subscribers", n)
+	}
+
+	cancel()
+	waitFor(t, func() bool { return svc.Subscribers() == 0 })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.SubscribeEvents(ctx, &eventstreamv1.SubscribeEventsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return svc.Subscribers() == 1 })
+}
+
+func TestRecvMsgSizeFor(t *testing.T) {
+	for _, tc := range []struct{ maxBatch, want int }{
+		{defaultMaxBatch, defaultMaxBatch * eventWireBudget},
+		{100, grpcDefaultMsgBytes},
+		{0, grpcDefaultMsgBytes},
+	} {
+		if got := recvMsgSizeFor(tc.maxBatch); got != tc.want {
+			t.Errorf("max batch %d: expected %d bytes, got %d", tc.maxBatch, tc.want, got)
+		}
+	}
+}
+
+func TestGRPCLimits_MaxRecvMsgSize(t *testing.T) {
+	svc := testService()
+	limits := grpcLimits{KeepaliveMinTime: defaultKeepaliveMinTime, MaxRecvMsgSize: 2 << 10}
+	client := eventsv1.NewEventServiceClient(dialBufconn(t, svc, limits.serverOptions()...))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)}); err != nil {
+		t.Fatalf("expected a small batch accepted, got %v", err)
+	}
+	_, err := client.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: makeEvents(50, 50)})
+	if status.Code(err) != codes.ResourceExhausted || !strings.Contains(status.Convert(err).Message(), "larger than max") {
+		t.Fatalf("expected ResourceExhausted naming the size limit, got %v", err)
+	}
+	if n := len(svc.StoredEvents()); n != 3 {
+		t.Errorf("expected only the small batch stored, got %d events", n)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"unsafe"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+// eventOverhead is the fixed size of a stored event, not counting the
+// string data it refers to: the message itself and the pointer the store
+// holds it by.
+var eventOverhead = int64(unsafe.Sizeof(eventsv1.UsageEvent{}) + unsafe.Sizeof(&eventsv1.UsageEvent{}))
+
+// approxEventBytes estimates the memory ev occupies: the message plus the
+// contents of its strings. Allocator rounding, protobuf internals allocated
+// on demand and any sharing of string data between events are ignored.
+func approxEventBytes(ev *eventsv1.UsageEvent) int64 {
+	return eventOverhead + int64(len(ev.GetKey())+len(ev.GetTenantKey())+len(ev.GetMethod())+
+		len(ev.GetPath())+len(ev.GetTimestamp())+len(ev.GetRequestId()))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+const (
+	// defaultMaxEvents is the default capacity of the in-memory store.
+	defaultMaxEvents = 10000
+	// defaultInitialCapacity is how many events the in-memory store makes
+	// room for up front.
+	defaultInitialCapacity = 1024
+)
+
+// Store persists usage events for the query API; see eventcore.Store.
+type Store = eventcore.Store[*eventsv1.UsageEvent]
+
+// StoreOptions and StoreFactory describe a backend for RegisterStore.
+type (
+	StoreOptions = eventcore.StoreOptions
+	StoreFactory = eventcore.StoreFactory[*eventsv1.UsageEvent]
+)
+
+// stores holds the -store backends: the built-in memory, sqlite and redis
+// ones, and any added with RegisterStore.
+var stores = eventcore.NewRegistry(eventFields)
+
+// RegisterStore makes a backend available as -store=<scheme>:... (or
+// just -store=<scheme>). A backend in a file of its own registers itself
+// from an init function, so adding the file is all it takes to wire it
+// in. Like database/sql.Register, it panics if factory is nil or the
+// scheme is already taken.
+func RegisterStore(scheme string, factory StoreFactory) {
+	stores.Register(scheme, factory)
+}
+
+// storeSchemes returns the registered schemes, sorted.
+func storeSchemes() []string { return stores.Schemes() }
+
+// openStore opens the backend registered for spec's scheme; see
+// eventcore.Registry.Open.
+func openStore(spec string, maxEvents, maxDenied, maxPerTenant, initialCapacity int) (Store, error) {
+	return stores.Open(spec, StoreOptions{
+		MaxEvents:       maxEvents,
+		MaxDenied:       maxDenied,
+		MaxPerTenant:    maxPerTenant,
+		InitialCapacity: initialCapacity,
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gatedStore blocks in Append until gate is closed, signalling entered
+// first, so tests can hold the async store's writer mid-write.
+type gatedStore struct {
+	*eventcore.MemoryStore[*eventsv1.UsageEvent]
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedStore) Append(ctx context.Context, batch []*eventsv1.UsageEvent) error {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.gate
+	return g.MemoryStore.Append(ctx, batch)
+}
+
+func TestAsyncStore_QueueFull(t *testing.T) {
+	inner := &gatedStore{MemoryStore: newMemoryStore(defaultMaxEvents), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	st := eventcore.NewAsyncStore(slog.Default(), inner, 1)
+	defer st.Close()
+	svc := NewEventService(slog.Default(), st)
+	ctx := context.Background()
+
+	// The writer takes the first batch and blocks; the second fills the
+	// queue.
+	if _, err := svc.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: keyedEvents("a")}); err != nil {
+		t.Fatal(err)
+	}
+	<-inner.entered
+	if _, err := svc.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: keyedEvents("b")}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := svc.PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: keyedEvents("c")})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable while the queue is full, got %v", err)
+	}
+
+	close(inner.gate)
+	if err := svc.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(svc.StoredEvents()); n != 2 {
+		t.Errorf("expected the 2 queued events to be stored, got %d", n)
+	}
+}
+
+// BenchmarkPublishEvents compares publishing straight to each store with
+// publishing through an async store in front of it. The queue pays off when
+// Append is expensive, as with SQLite's per-batch transaction.
+func BenchmarkPublishEvents(b *testing.B) {
+	backends := []struct {
+		name string
+		open func(b *testing.B) Store
+	}{
+		{"memory", func(*testing.B) Store { return newMemoryStore(defaultMaxEvents) }},
+		{"sqlite", func(b *testing.B) Store {
+			st, err := openStore("sqlite:"+filepath.Join(b.TempDir(), "events.db"), 0, 0, 0, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return st
+		}},
+	}
+	for _, backend := range backends {
+		for _, mode := range []string{"sync", "async"} {
+			b.Run(backend.name+"/"+mode, func(b *testing.B) {
+				st := backend.open(b)
+				if mode == "async" {
+					st = eventcore.NewAsyncStore(slog.New(slog.DiscardHandler), st, 1024)
+				}
+				defer st.Close()
+				svc := NewEventService(slog.New(slog.DiscardHandler), st)
+				batch := makeEvents(8, 2)
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						// Retry when the queue is full, as a client would.
+						for {
+							_, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch})
+							if status.Code(err) != codes.Unavailable {
+								break
+							}
+							runtime.Gosched()
+						}
+					}
+				})
+				if err := svc.Flush(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			})
+		}
+	}
+}
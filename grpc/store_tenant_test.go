@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+// tenantEvents returns events keyed by keys, each with the tenant key
+// "tenant-" followed by the key's first letter.
+func tenantEvents(keys ...string) []*eventsv1.UsageEvent {
+	events := keyedEvents(keys...)
+	for _, ev := range events {
+		ev.TenantKey = "tenant-" + ev.Key[:1]
+	}
+	return events
+}
+
+func TestListEvents_TenantStore(t *testing.T) {
+	st, err := openStore("memory", 0, 0, 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewEventService(slog.Default(), st)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: tenantEvents("b1", "b2")})
+	for range 20 {
+		svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: tenantEvents("a")})
+	}
+
+	list := func(query string) eventsPage {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		var page eventsPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	if got := pageKeys(list("tenant_key=tenant-b&cursor=")); !reflect.DeepEqual(got, []string{"b2", "b1"}) {
+		t.Errorf("expected tenant-b's events to survive the flood, got %v", got)
+	}
+	page := list("tenant_key=tenant-a&limit=3&cursor=")
+	if got := pageKeys(page); !reflect.DeepEqual(got, []string{"a", "a", "a"}) || page.NextCursor == "" {
+		t.Fatalf("expected a first page of tenant-a's events with a cursor, got %v %q", got, page.NextCursor)
+	}
+	if got := pageKeys(list("tenant_key=tenant-a&limit=3&cursor=" + page.NextCursor)); len(got) != 2 {
+		t.Errorf("expected the rest of tenant-a's 5 events, got %v", got)
+	}
+	if got := pageKeys(list("tenant_key=tenant-c&cursor=")); len(got) != 0 {
+		t.Errorf("expected nothing for an unknown tenant, got %v", got)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/protobuf/proto"
+)
+
+// newMemoryStore returns an in-memory store keeping at most capacity
+// events.
+func newMemoryStore(capacity int) *eventcore.MemoryStore[*eventsv1.UsageEvent] {
+	return eventcore.NewMemoryStore(eventFields, capacity, 0)
+}
+
+// storeBackends opens each kind of store -store can select; the service
+// must work the same over all of them.
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store { return openTestStore(t, "memory", 0, 0) },
+	"split":  func(t *testing.T) Store { return openTestStore(t, "memory", defaultMaxEvents, 0) },
+	"tenant": func(t *testing.T) Store { return openTestStore(t, "memory", 0, defaultMaxEvents) },
+	"redis":  func(t *testing.T) Store { return openTestStore(t, "redis://"+miniredis.RunT(t).Addr(), 0, 0) },
+	"sqlite": func(t *testing.T) Store {
+		return openTestStore(t, "sqlite:"+filepath.Join(t.TempDir(), "events.db"), 0, 0)
+	},
+}
+
+func openTestStore(t *testing.T, spec string, maxDenied, maxPerTenant int) Store {
+	t.Helper()
+	st, err := openStore(spec, defaultMaxEvents, maxDenied, maxPerTenant, defaultInitialCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func forEachStore(t *testing.T, fn func(t *testing.T, st Store)) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			t.Cleanup(func() { st.Close() })
+			fn(t, st)
+		})
+	}
+}
+
+func keyedEvents(keys ...string) []*eventsv1.UsageEvent {
+	out := make([]*eventsv1.UsageEvent, len(keys))
+	for i, k := range keys {
+		out[i] = &eventsv1.UsageEvent{Key: k, Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"}
+	}
+	return out
+}
+
+func scanKeys(t *testing.T, st Store, before int64) ([]string, []int64) {
+	t.Helper()
+	var keys []string
+	var seqs []int64
+	err := st.Scan(context.Background(), before, func(seq int64, ev *eventsv1.UsageEvent) bool {
+		keys = append(keys, ev.GetKey())
+		seqs = append(seqs, seq)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, seqs
+}
+
+func TestStore_FieldsPreserved(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store) {
+		want := []*eventsv1.UsageEvent{
+			{
+				Key:        "key-1",
+				TenantKey:  "tenant-42",
+				Method:     "DELETE",
+				Path:       "/api/v1/resource/123",
+				Allowed:    false,
+				Remaining:  0,
+				Limit:      100,
+				Timestamp:  "2026-02-16T21:30:00Z",
+				StatusCode: 429,
+				RequestId:  "req-xyz",
+			},
+			{Key: "key-2", Method: "GET", Path: "/", Allowed: true, Remaining: 9, Limit: 10, Timestamp: "ts", StatusCode: 200},
+		}
+		if err := st.Append(context.Background(), want); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []*eventsv1.UsageEvent
+		st.Scan(context.Background(), 0, func(_ int64, ev *eventsv1.UsageEvent) bool {
+			got = append([]*eventsv1.UsageEvent{ev}, got...)
+			return true
+		})
+		if len(got) != len(want) {
+			t.Fatalf("expected %d events, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if !proto.Equal(got[i], want[i]) {
+				t.Errorf("fields not preserved:\ngot  %v\nwant %v", got[i], want[i])
+			}
+		}
+	})
+}
+
+func TestStore_ListThroughService(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store) {
+		svc := NewEventService(slog.Default(), st)
+		_, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{
+			{Key: "k1", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"},
+			{Key: "k2", TenantKey: "tenant-b", Method: "POST", Path: "/b", Allowed: false, Timestamp: "2026-02-16T21:00:01Z"},
+			{Key: "k3", TenantKey: "tenant-a", Method: "POST", Path: "/a/x", Allowed: false, Timestamp: "2026-02-16T21:00:02Z"},
+			{Key: "k4", TenantKey: "tenant-a", Method: "GET", Path: "/a", Allowed: true, Timestamp: "2026-02-16T21:00:03Z"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list := func(query string) eventsPage {
+			t.Helper()
+			w := httptest.NewRecorder()
+			svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+			var page eventsPage
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			return page
+		}
+
+		page := list("cursor=&tenant_key=tenant-a&path_prefix=/a")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k4", "k3", "k1"}) {
+			t.Errorf("unexpected filtered list: %v", got)
+		}
+
+		page = list("cursor=&limit=2")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k4", "k3"}) {
+			t.Fatalf("unexpected first page: %v", got)
+		}
+		page = list("limit=2&cursor=" + page.NextCursor)
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k2", "k1"}) {
+			t.Errorf("unexpected second page: %v", got)
+		}
+
+		page = list("cursor=&allowed=false&offset=1")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k2"}) {
+			t.Errorf("unexpected offset page: %v", got)
+		}
+	})
+}
+
+// bufferedStore holds appended events until Flush, like a store that
+// batches writes to a remote backend.
+type bufferedStore struct {
+	*eventcore.MemoryStore[*eventsv1.UsageEvent]
+	pending []*eventsv1.UsageEvent
+}
+
+func (b *bufferedStore) Append(_ context.Context, batch []*eventsv1.UsageEvent) error {
+	b.pending = append(b.pending, batch...)
+	return nil
+}
+
+func (b *bufferedStore) Flush(ctx context.Context) error {
+	if err := b.MemoryStore.Append(ctx, b.pending); err != nil {
+		return err
+	}
+	b.pending = nil
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	st := &bufferedStore{MemoryStore: newMemoryStore(defaultMaxEvents)}
+	svc := NewEventService(slog.Default(), st)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Fatalf("expected events to be buffered before Flush, got %d stored", n)
+	}
+	if err := svc.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(svc.StoredEvents()); n != 3 {
+		t.Errorf("expected 3 events after Flush, got %d", n)
+	}
+}
+
+func TestFlush_SQLiteDurable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	st, err := openStore("sqlite:"+path, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewEventService(slog.Default(), st)
+	svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	if err := svc.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	reopened, err := openStore("sqlite:"+path, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if n, err := reopened.Len(context.Background()); err != nil || n != 3 {
+		t.Errorf("expected 3 events after reopening, got %d (err=%v)", n, err)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	for _, spec := range []string{"", "memory", "sqlite:" + filepath.Join(t.TempDir(), "events.db")} {
+		st, err := openStore(spec, defaultMaxEvents, 0, 0, defaultInitialCapacity)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", spec, err)
+			continue
+		}
+		st.Close()
+	}
+	if _, err := openStore("postgres://localhost", defaultMaxEvents, 0, 0, defaultInitialCapacity); err == nil {
+		t.Error("expected error for unknown store")
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 100, 0, defaultInitialCapacity); err != nil {
+		t.Errorf("memory with a denied cap: unexpected error: %v", err)
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "split.db"), defaultMaxEvents, 100, 0, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a denied cap on sqlite")
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 0, 100, defaultInitialCapacity); err != nil {
+		t.Errorf("memory with a per-tenant cap: unexpected error: %v", err)
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 100, 100, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap combined with a denied cap")
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "tenant.db"), defaultMaxEvents, 0, 100, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap on sqlite")
+	}
+}
+
+func pageKeys(page eventsPage) []string {
+	keys := make([]string, len(page.Events))
+	for i, ev := range page.Events {
+		keys[i] = ev.GetKey()
+	}
+	return keys
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+)
+
+// timestampLayouts are the layouts besides RFC 3339 that published
+// timestamps may use. They carry no zone, so they are read as UTC.
+var timestampLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// normalizeTimestamp rewrites ts to RFC 3339 so that time-range queries
+// can compare it. RFC 3339 input is returned unchanged; a zone-less date
+// and time, or Unix epoch seconds or milliseconds, is converted to UTC.
+// Anything else is returned as is, with ok false.
+func normalizeTimestamp(ts string) (_ string, ok bool) {
+	if _, err := time.Parse(time.RFC3339, ts); err == nil {
+		return ts, true
+	}
+	if t, ok := parseEpoch(ts); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+	for _, layout := range timestampLayouts {
+		// Parse accepts fractional seconds the layout doesn't mention.
+		if t, err := time.Parse(layout, ts); err == nil {
+			return t.Format(time.RFC3339Nano), true
+		}
+	}
+	return ts, false
+}
+
+// parseEpoch reads a Unix timestamp of up to 10 digits as seconds and a
+// longer one as milliseconds.
+func parseEpoch(ts string) (time.Time, bool) {
+	if ts == "" || ts[0] < '0' || ts[0] > '9' {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(ts) <= 10 {
+		return time.Unix(n, 0), true
+	}
+	return time.UnixMilli(n), true
+}
+
+// normalizeTimestamps normalizes the timestamps of batch in place unless
+// disabled. Those it can't parse are kept as sent and logged.
+func (s *EventService) normalizeTimestamps(batch []*eventsv1.UsageEvent) {
+	if s.rawTimestamps {
+		return
+	}
+	var failed int
+	var example string
+	for _, ev := range batch {
+		ts, ok := normalizeTimestamp(ev.GetTimestamp())
+		if !ok {
+			failed++
+			example = ts
+			continue
+		}
+		ev.Timestamp = ts
+	}
+	if failed > 0 {
+		s.logger.Warn("kept unparseable event timestamps as sent", "count", failed, "example", example)
+	}
+}
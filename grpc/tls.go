@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// loadTLSConfig returns a server TLS config serving the given certificate
+// and key files, or nil when neither is set.
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS certificate and key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS key pair: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// loadGRPCTLSConfig is loadTLSConfig for the gRPC server. With a client CA
+// file it also requires every client to present a certificate signed by
+// one of its CAs.
+func loadGRPCTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cfg, err := loadTLSConfig(certFile, keyFile)
+	if err != nil || clientCAFile == "" {
+		return cfg, err
+	}
+	if cfg == nil {
+		return nil, errors.New("a client CA requires a TLS certificate and key")
+	}
+	data, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA %q", clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
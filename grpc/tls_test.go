@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1, usable
+// by servers and clients, and its key to dir and returns their paths along
+// with a pool trusting it.
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certFile, keyFile, pool := writeSelfSignedCert(t, t.TempDir())
+
+	cfg, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("HTTPS request with the self-signed cert failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestLoadTLSConfig_Invalid(t *testing.T) {
+	if cfg, err := loadTLSConfig("", ""); cfg != nil || err != nil {
+		t.Errorf("expected no TLS config when unset, got %v, %v", cfg, err)
+	}
+	certFile, keyFile, _ := writeSelfSignedCert(t, t.TempDir())
+	for name, files := range map[string][2]string{
+		"cert only": {certFile, ""},
+		"key only":  {"", keyFile},
+		"missing":   {filepath.Join(t.TempDir(), "nope.pem"), keyFile},
+		"swapped":   {keyFile, certFile},
+	} {
+		if _, err := loadTLSConfig(files[0], files[1]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// checkHealthOverTLS serves the health service with serverCfg over bufconn
+// and returns the result of a Check made with clientCfg.
+func checkHealthOverTLS(t *testing.T, serverCfg, clientCfg *tls.Config) error {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverCfg)))
+	svc := testService()
+	svc.SetServing(true)
+	healthpb.RegisterHealthServer(srv, svc.health)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///127.0.0.1",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientCfg)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestLoadGRPCTLSConfig_MutualTLS(t *testing.T) {
+	serverCert, serverKey, serverPool := writeSelfSignedCert(t, t.TempDir())
+	clientCertFile, clientKeyFile, _ := writeSelfSignedCert(t, t.TempDir())
+	strangerCertFile, strangerKeyFile, _ := writeSelfSignedCert(t, t.TempDir())
+
+	cfg, err := loadGRPCTLSConfig(serverCert, serverKey, clientCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("expected client certificates to be required, got %v", cfg.ClientAuth)
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strangerCert, err := tls.LoadX509KeyPair(strangerCertFile, strangerKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkHealthOverTLS(t, cfg, &tls.Config{RootCAs: serverPool, Certificates: []tls.Certificate{clientCert}}); err != nil {
+		t.Errorf("call with a trusted client certificate failed: %v", err)
+	}
+	if err := checkHealthOverTLS(t, cfg, &tls.Config{RootCAs: serverPool}); err == nil {
+		t.Error("expected a call without a client certificate to fail")
+	}
+	if err := checkHealthOverTLS(t, cfg, &tls.Config{RootCAs: serverPool, Certificates: []tls.Certificate{strangerCert}}); err == nil {
+		t.Error("expected a call with an untrusted client certificate to fail")
+	}
+}
+
+func TestLoadGRPCTLSConfig_Invalid(t *testing.T) {
+	if cfg, err := loadGRPCTLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Errorf("expected no TLS config when unset, got %v, %v", cfg, err)
+	}
+	certFile, keyFile, _ := writeSelfSignedCert(t, t.TempDir())
+	cfg, err := loadGRPCTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("expected no client auth without a client CA, got %v", cfg.ClientAuth)
+	}
+	for name, files := range map[string][3]string{
+		"ca without cert": {"", "", certFile},
+		"missing ca":      {certFile, keyFile, filepath.Join(t.TempDir(), "nope.pem")},
+		"ca not pem":      {certFile, keyFile, keyFile},
+	} {
+		if _, err := loadGRPCTLSConfig(files[0], files[1], files[2]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	// tracerName identifies the spans this service creates itself.
+	tracerName = "github.com/edgequota/external-events-template/grpc"
+	// serviceName is reported as service.name unless OTEL_SERVICE_NAME
+	// overrides it.
+	serviceName = "external-events-grpc"
+)
+
+// propagator continues traces from W3C traceparent and baggage headers, or
+// the gRPC metadata entries of the same names.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// newTracerProvider returns a tracer provider that batches spans to the
+// OTLP/HTTP collector at endpoint, e.g. http://localhost:4318.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(attribute.String("service.name", serviceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build trace resource: %w", err)
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// grpcStatsHandler creates a server span for every RPC, continuing any
+// trace the incoming metadata carries.
+func (s *EventService) grpcStatsHandler() stats.Handler {
+	return otelgrpc.NewServerHandler(
+		otelgrpc.WithTracerProvider(s.tracerProvider),
+		otelgrpc.WithPropagators(propagator),
+	)
+}
+
+// traced wraps h in a server span named after its route, continuing any
+// trace the request carries.
+func (s *EventService) traced(route string, h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, route,
+		otelhttp.WithTracerProvider(s.tracerProvider),
+		otelhttp.WithPropagators(propagator),
+	)
+}
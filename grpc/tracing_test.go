@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceParent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func spanAttr(span *tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_Publish(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithTracerProvider(tp))
+	conn := dialBufconn(t, svc, grpc.StatsHandler(svc.grpcStatsHandler()))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", testTraceParent)
+	_, err := eventsv1.NewEventServiceClient(conn).PublishEvents(ctx, &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rpc = "edgequota.events.v1.EventService/PublishEvents"
+	// The server span ends after the response has been sent.
+	waitFor(t, func() bool { return findSpan(exporter.GetSpans(), rpc) != nil })
+	spans := exporter.GetSpans()
+	server := findSpan(spans, rpc)
+	if got := server.SpanContext.TraceID().String(); got != testTraceID {
+		t.Errorf("expected the span to continue trace %s, got %s", testTraceID, got)
+	}
+	if got := spanAttr(server, "events.denied").AsInt64(); got != 1 {
+		t.Errorf("expected events.denied 1, got %d", got)
+	}
+
+	store := findSpan(spans, "Store.Append")
+	if store == nil {
+		t.Fatal("expected a Store.Append span")
+	}
+	if store.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("expected Store.Append to be a child of the RPC span")
+	}
+	if got := spanAttr(store, "events.count").AsInt64(); got != 3 {
+		t.Errorf("expected events.count 3, got %d", got)
+	}
+}
+
+func TestTracing_Query(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	mux := newMux(NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithTracerProvider(tp)))
+
+	for _, path := range []string{"/events", "/events/stats"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if findSpan(exporter.GetSpans(), "GET "+path) == nil {
+			t.Errorf("expected a span for GET %s", path)
+		}
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	if findSpan(exporter.GetSpans(), "GET /metrics") != nil {
+		t.Error("expected /metrics not to be traced")
+	}
+}
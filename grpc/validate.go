@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// strictMetadata is the request metadata key that asks for an
+	// all-or-nothing publish.
+	strictMetadata = "x-strict"
+	// dryRunMetadata is the request metadata key that asks for a publish
+	// to be validated and counted but not stored. A dry run's response
+	// header carries it back.
+	dryRunMetadata = "x-dry-run"
+	// rejectedMetadata is the trailer key listing the events left out of a
+	// partially accepted batch, one "index: reason" value per event.
+	rejectedMetadata = "x-rejected"
+)
+
+// defaultMaxFieldLength is the default cap, in bytes, on each string field
+// of a published event: room for long URL paths, far below what would
+// strain memory.
+const defaultMaxFieldLength = 2048
+
+// rejectedEvent reports an event left out of a partially accepted batch.
+type rejectedEvent struct {
+	// Index is the event's position in the published batch.
+	Index  int
+	Reason string
+}
+
+func (r rejectedEvent) String() string {
+	return fmt.Sprintf("%d: %s", r.Index, r.Reason)
+}
+
+// validateEvent returns why ev can't be stored, or "" if it can. Only the
+// key is required; the numeric fields must be in range when set, and with
+// a positive maxFieldLen no string field may be longer, in bytes.
+func validateEvent(ev *eventsv1.UsageEvent, maxFieldLen int) string {
+	switch {
+	case ev.GetKey() == "":
+		return "key is required"
+	case ev.GetLimit() < 0:
+		return fmt.Sprintf("limit %d is negative", ev.GetLimit())
+	case ev.GetRemaining() < 0:
+		return fmt.Sprintf("remaining %d is negative", ev.GetRemaining())
+	case ev.GetStatusCode() != 0 && (ev.GetStatusCode() < 100 || ev.GetStatusCode() > 599):
+		return fmt.Sprintf("status_code %d is not an HTTP status", ev.GetStatusCode())
+	}
+	if maxFieldLen > 0 {
+		for _, f := range stringFields(ev) {
+			if len(*f.value) > maxFieldLen {
+				return fmt.Sprintf("%s is %d bytes, over the maximum of %d", f.name, len(*f.value), maxFieldLen)
+			}
+		}
+	}
+	return ""
+}
+
+// eventString is one of an event's string fields.
+type eventString struct {
+	name  string
+	value *string
+}
+
+// stringFields returns the fields of ev the field length limit applies to:
+// key, method, path, tenant_key and request_id.
+func stringFields(ev *eventsv1.UsageEvent) []eventString {
+	return []eventString{
+		{"key", &ev.Key}, {"method", &ev.Method}, {"path", &ev.Path},
+		{"tenant_key", &ev.TenantKey}, {"request_id", &ev.RequestId},
+	}
+}
+
+// truncateString shortens v to at most n bytes without splitting a UTF-8
+// sequence.
+func truncateString(v string, n int) string {
+	if len(v) <= n {
+		return v
+	}
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n]
+}
+
+// truncateLongFields shortens the string fields of batch's events to
+// s.maxFieldLen in place when the service truncates rather than rejects
+// them.
+func (s *EventService) truncateLongFields(batch []*eventsv1.UsageEvent) {
+	if !s.truncateFields || s.maxFieldLen <= 0 {
+		return
+	}
+	truncated := 0
+	for i := range batch {
+		if batch[i] == nil {
+			continue
+		}
+		for _, f := range stringFields(batch[i]) {
+			if len(*f.value) > s.maxFieldLen {
+				*f.value = truncateString(*f.value, s.maxFieldLen)
+				truncated++
+			}
+		}
+	}
+	if truncated > 0 {
+		s.logger.Debug("truncated long event fields", "count", truncated, "max_field_length", s.maxFieldLen)
+	}
+}
+
+// partitionValid splits batch into the events that pass validateEvent with
+// maxFieldLen, in their original order, and the rejections for the rest.
+// Nil entries, which the protobuf decoder never produces but a Go caller
+// can pass, are not events: they are left out of both, so they are neither
+// counted nor reported, and the rejections keep their indexes in batch.
+// When nothing is rejected or left out valid is batch itself.
+func partitionValid(batch []*eventsv1.UsageEvent, maxFieldLen int) (valid []*eventsv1.UsageEvent, rejected []rejectedEvent) {
+	nils := 0
+	for i, ev := range batch {
+		if ev == nil {
+			nils++
+			continue
+		}
+		if reason := validateEvent(ev, maxFieldLen); reason != "" {
+			rejected = append(rejected, rejectedEvent{Index: i, Reason: reason})
+		}
+	}
+	if len(rejected) == 0 && nils == 0 {
+		return batch, nil
+	}
+	valid = make([]*eventsv1.UsageEvent, 0, len(batch)-len(rejected)-nils)
+	next := 0
+	for i, ev := range batch {
+		if next < len(rejected) && rejected[next].Index == i {
+			next++
+			continue
+		}
+		if ev != nil {
+			valid = append(valid, ev)
+		}
+	}
+	return valid, rejected
+}
+
+// strictRequested reports whether the caller's x-strict metadata asks for
+// an all-or-nothing publish.
+func strictRequested(ctx context.Context) (bool, error) {
+	return metadataBool(ctx, strictMetadata)
+}
+
+// metadataBool parses the first value of the caller's key metadata as a
+// bool, defaulting to false when it is absent.
+func metadataBool(ctx context.Context, key string) (bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get(key)
+	if len(v) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v[0])
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", key, v[0])
+	}
+	return b, nil
+}
+
+// joinRejected formats rejected for an error message.
+func joinRejected(rejected []rejectedEvent) string {
+	parts := make([]string, len(rejected))
+	for i, r := range rejected {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, "; ")
+}
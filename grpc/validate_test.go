@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// mixedBatch returns five events of which the second and fourth are
+// invalid.
+func mixedBatch() []*eventsv1.UsageEvent {
+	return []*eventsv1.UsageEvent{
+		{Key: "a", Method: "GET", Path: "/", Allowed: true, StatusCode: 200},
+		{Method: "GET", Path: "/", Allowed: true},
+		{Key: "b", Method: "GET", Path: "/", Allowed: false, StatusCode: 429},
+		{Key: "c", Method: "GET", Path: "/", Limit: -1},
+		{Key: "d", Method: "GET", Path: "/", Allowed: true},
+	}
+}
+
+func TestPublishEvents_PartialAcceptance(t *testing.T) {
+	svc := testService()
+	client := eventsv1.NewEventServiceClient(dialBufconn(t, svc))
+
+	var trailer metadata.MD
+	resp, err := client.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: mixedBatch()}, grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != 3 {
+		t.Errorf("expected 3 accepted, got %d", resp.GetAccepted())
+	}
+	want := []string{"1: key is required", "3: limit -1 is negative"}
+	if got := trailer.Get(rejectedMetadata); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected rejections %q in the trailer, got %q", want, got)
+	}
+
+	var keys []string
+	for _, ev := range svc.StoredEvents() {
+		keys = append(keys, ev.GetKey())
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "d"}) {
+		t.Errorf("expected only the valid events stored, got %v", keys)
+	}
+	if got := svc.Core().Totals().Received; got != 3 {
+		t.Errorf("expected rejected events left out of the stats, got %d received", got)
+	}
+}
+
+func TestPublishEvents_NilEvents(t *testing.T) {
+	batch := func() []*eventsv1.UsageEvent {
+		events := mixedBatch()
+		return []*eventsv1.UsageEvent{nil, events[0], events[1], nil, events[2], nil}
+	}
+
+	svc := testService()
+	resp, err := svc.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != 2 {
+		t.Errorf("expected 2 accepted, got %d", resp.GetAccepted())
+	}
+	if got := svc.Core().Totals(); got != (eventcore.Totals{Received: 2, Allowed: 1, Denied: 1}) {
+		t.Errorf("expected nil entries left out of the stats, got %+v", got)
+	}
+	if n := len(svc.StoredEvents()); n != 2 {
+		t.Errorf("expected 2 stored events, got %d", n)
+	}
+
+	valid, rejected := partitionValid(batch(), 0)
+	if want := []rejectedEvent{{Index: 2, Reason: "key is required"}}; len(valid) != 2 || !reflect.DeepEqual(rejected, want) {
+		t.Errorf("expected 2 valid events and rejections %v, got %d and %v", want, len(valid), rejected)
+	}
+
+	// Nil entries don't fail a strict batch, and aren't truncated.
+	strictCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(strictMetadata, "true"))
+	if _, err := svc.PublishEvents(strictCtx, &eventsv1.PublishEventsRequest{Events: []*eventsv1.UsageEvent{nil, mixedBatch()[0]}}); err != nil {
+		t.Errorf("expected a strict batch with a nil entry to be accepted, got %v", err)
+	}
+	truncate := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxFieldLength(8, true))
+	if resp, err := truncate.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch()}); err != nil || resp.GetAccepted() != 2 {
+		t.Errorf("expected 2 accepted with truncation, got %v, %v", resp, err)
+	}
+}
+
+func TestPublishEvents_MaxFieldLength(t *testing.T) {
+	long := func(n int) string { return strings.Repeat("x", n) }
+	batch := func() []*eventsv1.UsageEvent {
+		return []*eventsv1.UsageEvent{
+			{Key: long(8), Method: "GET", Path: "/" + long(7), Allowed: true, TenantKey: long(8)},
+			{Key: "k", Method: "GET", Path: "/" + long(8), Allowed: true},
+			{Key: "k", Method: "GET", Path: "/", Allowed: false, RequestId: long(9)},
+		}
+	}
+
+	reject := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxFieldLength(8, false))
+	var trailer metadata.MD
+	resp, err := eventsv1.NewEventServiceClient(dialBufconn(t, reject)).PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch()}, grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1: path is 9 bytes, over the maximum of 8", "2: request_id is 9 bytes, over the maximum of 8"}
+	if got := trailer.Get(rejectedMetadata); resp.GetAccepted() != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected fields at the limit accepted and %q rejected, got %d accepted and %q", want, resp.GetAccepted(), got)
+	}
+
+	truncate := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxFieldLength(8, true))
+	resp, err = eventsv1.NewEventServiceClient(dialBufconn(t, truncate)).PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: batch()})
+	if err != nil || resp.GetAccepted() != 3 {
+		t.Fatalf("expected every event accepted when truncating, got %v (err=%v)", resp, err)
+	}
+	stored := truncate.StoredEvents()
+	if got := stored[0].GetKey() + " " + stored[1].GetPath() + " " + stored[2].GetRequestId(); got != long(8)+" /"+long(7)+" "+long(8) {
+		t.Errorf("expected long fields cut to 8 bytes, got %q", got)
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"aé", 2, "a"},
+		{"aé", 3, "aé"},
+		{"日本", 4, "日"},
+	} {
+		if got := truncateString(tc.in, tc.n); got != tc.want {
+			t.Errorf("truncateString(%q, %d): expected %q, got %q", tc.in, tc.n, tc.want, got)
+		}
+	}
+}
+
+func TestPublishEvents_Strict(t *testing.T) {
+	svc := testService()
+	strict := func(v string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), strictMetadata, v)
+	}
+	client := eventsv1.NewEventServiceClient(dialBufconn(t, svc))
+
+	_, err := client.PublishEvents(strict("true"), &eventsv1.PublishEventsRequest{Events: mixedBatch()})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Errorf("expected nothing stored from a strict batch with invalid events, got %d", n)
+	}
+
+	if _, err := client.PublishEvents(strict("true"), &eventsv1.PublishEventsRequest{Events: makeEvents(2, 1)}); err != nil {
+		t.Errorf("expected a strict batch of valid events to succeed, got %v", err)
+	}
+	_, err = client.PublishEvents(strict("maybe"), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid x-strict value, got %v", err)
+	}
+}
+
+func TestPublishEvents_DryRun(t *testing.T) {
+	svc := testService()
+	dryRun := func(v string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), dryRunMetadata, v)
+	}
+	client := eventsv1.NewEventServiceClient(dialBufconn(t, svc))
+
+	var header, trailer metadata.MD
+	resp, err := client.PublishEvents(dryRun("true"), &eventsv1.PublishEventsRequest{Events: mixedBatch()}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAccepted() != 3 {
+		t.Errorf("expected 3 events reported as acceptable, got %d", resp.GetAccepted())
+	}
+	if got := header.Get(dryRunMetadata); !reflect.DeepEqual(got, []string{"true"}) {
+		t.Errorf("expected the dry run confirmed in the header, got %q", got)
+	}
+	if got := trailer.Get(rejectedMetadata); len(got) != 2 {
+		t.Errorf("expected the 2 invalid events reported in the trailer, got %q", got)
+	}
+
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Errorf("expected nothing stored, got %d events", n)
+	}
+	if received, lifetime := svc.Core().Totals().Received, svc.Core().Lifetime().Received; received != 0 || lifetime != 0 {
+		t.Errorf("expected no events counted, got %d received and %d lifetime", received, lifetime)
+	}
+	if tenants := svc.Core().TenantStats(); len(tenants.Tenants) != 0 || tenants.NoTenant != (eventcore.TenantStats{}) {
+		t.Errorf("expected no tenant counters, got %+v", tenants)
+	}
+	stats := httptest.NewRecorder()
+	svc.HandleStats(stats, httptest.NewRequest("GET", "/events/stats", nil))
+	var got EventStats
+	if err := json.NewDecoder(stats.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.LastEventAt != "" || got.PublishLatency.Count != 0 {
+		t.Error("expected a dry run to leave the last event time and publish latency alone")
+	}
+
+	_, err = client.PublishEvents(metadata.AppendToOutgoingContext(dryRun("true"), strictMetadata, "true"), &eventsv1.PublishEventsRequest{Events: mixedBatch()})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a strict dry run with invalid events refused, got %v", err)
+	}
+	_, err = client.PublishEvents(dryRun("maybe"), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid x-dry-run value, got %v", err)
+	}
+	if _, err := client.PublishEvents(dryRun("false"), &eventsv1.PublishEventsRequest{Events: makeEvents(1, 0)}); err != nil || len(svc.StoredEvents()) != 1 {
+		t.Errorf("expected x-dry-run: false to store the batch, got %v and %d events", err, len(svc.StoredEvents()))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestPublishEvents_Watermarks(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(10), WithWatermarks(10, 0.5, 0.8))
+	client := eventsv1.NewEventServiceClient(dialBufconn(t, svc))
+	publish := func(n int) (metadata.MD, error) {
+		t.Helper()
+		var header metadata.MD
+		_, err := client.PublishEvents(context.Background(), &eventsv1.PublishEventsRequest{Events: makeEvents(n, 0)}, grpc.Header(&header))
+		return header, err
+	}
+	fillLevel := func() float64 {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil || stats.FillLevel == nil {
+			t.Fatalf("expected a fill level in the stats, got %s (err=%v)", w.Body.String(), err)
+		}
+		return *stats.FillLevel
+	}
+
+	header, err := publish(5)
+	if err != nil || len(header.Get("warning")) != 0 || len(header.Get("retry-after")) != 0 {
+		t.Fatalf("expected a plain success below the soft watermark, got %v, %v", header, err)
+	}
+	if got := fillLevel(); got != 0.5 {
+		t.Errorf("expected fill level 0.5, got %v", got)
+	}
+
+	// At the soft watermark publishes still succeed, with a warning.
+	header, err = publish(3)
+	if err != nil {
+		t.Fatalf("expected success above the soft watermark, got %v", err)
+	}
+	if len(header.Get("warning")) != 1 || !equalMD(header, "retry-after", eventcore.WatermarkRetryAfter) {
+		t.Errorf("expected warning and retry-after headers above the soft watermark, got %v", header)
+	}
+
+	// At the hard watermark they are refused and nothing is stored.
+	header, err = publish(1)
+	if status.Code(err) != codes.Unavailable || !equalMD(header, "retry-after", eventcore.WatermarkRetryAfter) {
+		t.Fatalf("expected Unavailable with retry-after at the hard watermark, got %v, %v", header, err)
+	}
+	if got := fillLevel(); got != 0.8 {
+		t.Errorf("expected the refused batch left unstored, got fill level %v", got)
+	}
+}
+
+func equalMD(md metadata.MD, key, want string) bool {
+	v := md.Get(key)
+	return len(v) == 1 && v[0] == want
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+const (
+	// defaultAlertWindow is how far back the alert sink looks when
+	// computing the denial rate.
+	defaultAlertWindow = time.Minute
+	// defaultAlertCooldown is the least time between two alerts.
+	defaultAlertCooldown = 5 * time.Minute
+	// alertMinEvents is the number of events the window must hold before
+	// its denial rate is trusted, so a single early denial doesn't alert.
+	alertMinEvents = 20
+	// alertTimeout bounds each webhook call.
+	alertTimeout = 10 * time.Second
+)
+
+// alertPayload is the JSON body POSTed to the alert webhook.
+type alertPayload struct {
+	Alert         string  `json:"alert"`
+	DenyRate      float64 `json:"deny_rate"`
+	Threshold     float64 `json:"threshold"`
+	WindowSeconds int64   `json:"window_seconds"`
+	Allowed       int64   `json:"allowed"`
+	Denied        int64   `json:"denied"`
+	At            string  `json:"at"`
+}
+
+// alertBucket counts the events accepted within one second.
+type alertBucket struct {
+	second          time.Time
+	allowed, denied int64
+}
+
+// alertSink POSTs an alertPayload to a webhook when the share of denied
+// events accepted over the last window rises above a threshold. It alerts
+// once per crossing: the rate has to fall back to the threshold before it
+// can alert again, and never sooner than cooldown after the last alert.
+// Webhook calls are made in the background, so publishers never wait on
+// them; a failed call is logged and not retried.
+type alertSink struct {
+	logger    *slog.Logger
+	client    *http.Client
+	url       string
+	threshold float64
+	window    time.Duration
+	cooldown  time.Duration
+	clock     eventcore.Clock
+
+	mu sync.Mutex
+	// buckets holds the counts per second within the window, oldest
+	// first.
+	buckets  []alertBucket
+	firing   bool
+	lastSent time.Time
+	closed   bool
+	sending  sync.WaitGroup
+}
+
+// validAlertURL checks that target is an absolute http or https URL.
+func validAlertURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("alert webhook %q must be an http or https URL", target)
+	}
+	return nil
+}
+
+func newAlertSink(logger *slog.Logger, target string, threshold float64, window, cooldown time.Duration, clock eventcore.Clock) *alertSink {
+	a := &alertSink{
+		logger:    logger,
+		client:    &http.Client{Timeout: alertTimeout},
+		url:       target,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		clock:     clock,
+	}
+	if a.window <= 0 {
+		a.window = defaultAlertWindow
+	}
+	return a
+}
+
+// Publish counts batch into the window and starts a webhook call if the
+// denial rate has just crossed the threshold.
+func (a *alertSink) Publish(batch []eventsv1http.UsageEvent) {
+	now := a.clock.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.add(now, batch)
+	var allowed, denied int64
+	for _, b := range a.buckets {
+		allowed += b.allowed
+		denied += b.denied
+	}
+	if allowed+denied < alertMinEvents {
+		return
+	}
+	rate := eventcore.Ratio(denied, allowed+denied)
+	if rate <= a.threshold {
+		a.firing = false
+		return
+	}
+	if a.firing || (!a.lastSent.IsZero() && now.Sub(a.lastSent) < a.cooldown) {
+		return
+	}
+	a.firing = true
+	a.lastSent = now
+	payload := alertPayload{
+		Alert:         "deny_rate",
+		DenyRate:      rate,
+		Threshold:     a.threshold,
+		WindowSeconds: int64(a.window.Seconds()),
+		Allowed:       allowed,
+		Denied:        denied,
+		At:            now.UTC().Format(time.RFC3339),
+	}
+	a.logger.Warn("denial rate above threshold, alerting", "deny_rate", rate, "threshold", a.threshold)
+	a.sending.Add(1)
+	go func() {
+		defer a.sending.Done()
+		if err := a.send(payload); err != nil {
+			a.logger.Error("failed to call alert webhook", "url", a.url, "error", err)
+		}
+	}()
+}
+
+// add counts batch into the bucket for now and drops the buckets that
+// have left the window. Callers hold mu.
+func (a *alertSink) add(now time.Time, batch []eventsv1http.UsageEvent) {
+	second := now.Truncate(time.Second)
+	if n := len(a.buckets); n == 0 || !a.buckets[n-1].second.Equal(second) {
+		a.buckets = append(a.buckets, alertBucket{second: second})
+	}
+	b := &a.buckets[len(a.buckets)-1]
+	for i := range batch {
+		if batch[i].Allowed {
+			b.allowed++
+		} else {
+			b.denied++
+		}
+	}
+	cutoff := now.Add(-a.window)
+	i := 0
+	for i < len(a.buckets) && !a.buckets[i].second.After(cutoff) {
+		i++
+	}
+	a.buckets = a.buckets[i:]
+}
+
+func (a *alertSink) send(payload alertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("webhook answered " + resp.Status)
+	}
+	return nil
+}
+
+// Close stops alerting and waits for webhook calls in flight.
+func (a *alertSink) Close() {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.sending.Wait()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/edgequota/edgequota-go/events"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// HandleBackfillEvents imports historical events, storing them in
+// timestamp order among the events already stored instead of at the newest
+// end. Timestamps are normalized as for live publishes and must then be
+// RFC 3339. Backfilled events are only stored: they don't reach sinks or the
+// live stream and aren't counted in the stats.
+func (s *EventService) HandleBackfillEvents(w http.ResponseWriter, r *http.Request) {
+	body := r.Body
+	if s.maxBody > 0 {
+		body = http.MaxBytesReader(w, body, s.maxBody)
+	}
+	req, err := decodeJSON(body, s.disallowUnknownFields)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			eventcore.WriteJSON(w, http.StatusRequestEntityTooLarge, eventcore.ErrorResponse{
+				Error: fmt.Sprintf("request body exceeds the maximum of %d bytes", maxErr.Limit),
+			})
+			return
+		}
+		var fieldErr *unknownFieldError
+		if errors.Is(err, errTrailingData) || errors.As(err, &fieldErr) {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: err.Error()})
+			return
+		}
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	if s.maxBatch > 0 && len(req.Events) > s.maxBatch {
+		eventcore.WriteJSON(w, http.StatusRequestEntityTooLarge, eventcore.ErrorResponse{
+			Error: fmt.Sprintf("batch of %d events exceeds the maximum of %d", len(req.Events), s.maxBatch),
+		})
+		return
+	}
+	s.normalizeTimestamps(req.Events)
+	for i, ev := range req.Events {
+		if _, err := time.Parse(time.RFC3339, ev.Timestamp); err != nil {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{
+				Error: fmt.Sprintf("event %d: timestamp %q is not RFC 3339", i, ev.Timestamp),
+			})
+			return
+		}
+	}
+
+	// SortByTimestamp takes events newest-first, so reverse the arrival
+	// order to keep events with equal timestamps in the order they came.
+	slices.Reverse(req.Events)
+	eventFields.SortByTimestamp(req.Events, true)
+
+	if err := eventcore.Backfill(r.Context(), s.storage, req.Events); err != nil {
+		if errors.Is(err, eventcore.ErrBackfillUnsupported) {
+			eventcore.WriteJSON(w, http.StatusNotImplemented, eventcore.ErrorResponse{Error: "the configured store does not support backfill"})
+			return
+		}
+		s.logger.Error("failed to backfill events", "error", err)
+		eventcore.WriteJSON(w, http.StatusInternalServerError, eventcore.ErrorResponse{Error: "failed to backfill events"})
+		return
+	}
+	s.logger.Info("events backfilled", "count", len(req.Events))
+	eventcore.WriteJSON(w, http.StatusOK, events.Accepted(len(req.Events)))
+}
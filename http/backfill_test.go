@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+func backfillRequest(t *testing.T, svc *EventService, events []eventsv1http.UsageEvent) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(eventsv1http.PublishEventsRequest{Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	svc.HandleBackfillEvents(w, httptest.NewRequest("POST", "/events/backfill", bytes.NewReader(body)))
+	return w
+}
+
+// timedEvent returns an event keyed by key at clock (hh:mm:ss) on the test
+// day.
+func timedEvent(key, clock string, allowed bool) eventsv1http.UsageEvent {
+	return eventsv1http.UsageEvent{Key: key, Method: "GET", Path: "/", Allowed: allowed, Timestamp: "2026-02-16T" + clock + "Z"}
+}
+
+func TestBackfillEvents(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"memory": storeBackends["memory"],
+		"split":  storeBackends["split"],
+		"tenant": storeBackends["tenant"],
+		"async": func(*testing.T) Store {
+			return eventcore.NewAsyncStore(slog.Default(), newMemoryStore(defaultMaxEvents), 16)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			defer st.Close()
+			svc := NewEventService(slog.Default(), st)
+			publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{
+				timedEvent("live-1", "21:00:00", true),
+				timedEvent("live-2", "21:00:10", false),
+			}})
+
+			w := backfillRequest(t, svc, []eventsv1http.UsageEvent{
+				timedEvent("old-3", "21:00:05", false),
+				timedEvent("old-1", "20:59:00", true),
+				timedEvent("old-4", "21:00:20", true),
+				timedEvent("old-2", "21:00:00", true),
+				timedEvent("old-3b", "21:00:05", true),
+			})
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp eventsv1http.PublishEventsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Accepted != 5 {
+				t.Errorf("expected 5 accepted, got %+v (err=%v)", resp, err)
+			}
+
+			keys, seqs := scanKeys(t, st, 0)
+			slices.Reverse(keys)
+			want := []string{"old-1", "live-1", "old-2", "old-3", "old-3b", "live-2", "old-4"}
+			if !reflect.DeepEqual(keys, want) {
+				t.Errorf("expected events in timestamp order %v, got %v", want, keys)
+			}
+			for i := 1; i < len(seqs); i++ {
+				if seqs[i] >= seqs[i-1] {
+					t.Errorf("expected decreasing sequence numbers, got %v", seqs)
+				}
+			}
+			if got := svc.Core().Totals().Received; got != 2 {
+				t.Errorf("expected backfilled events left out of the stats, got %d received", got)
+			}
+		})
+	}
+}
+
+func TestBackfillEvents_CapacityKeepsNewest(t *testing.T) {
+	st := newMemoryStore(3)
+	svc := NewEventService(slog.Default(), st)
+	st.Append(context.Background(), []eventsv1http.UsageEvent{timedEvent("live", "21:00:10", true)})
+
+	w := backfillRequest(t, svc, []eventsv1http.UsageEvent{
+		timedEvent("b", "21:00:05", true),
+		timedEvent("a", "21:00:01", true),
+		timedEvent("c", "21:00:20", true),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if keys, _ := scanKeys(t, st, 0); !reflect.DeepEqual(keys, []string{"c", "live", "b"}) {
+		t.Errorf("expected the oldest event dropped, got %v", keys)
+	}
+}
+
+func TestBackfillEvents_Rejected(t *testing.T) {
+	svc := testService()
+	w := backfillRequest(t, svc, []eventsv1http.UsageEvent{
+		timedEvent("ok", "21:00:00", true),
+		{Key: "bad", Method: "GET", Path: "/", Timestamp: "yesterday"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unparseable timestamp, got %d", w.Code)
+	}
+	if n, _ := svc.storage.Len(context.Background()); n != 0 {
+		t.Errorf("expected nothing stored from a rejected batch, got %d", n)
+	}
+
+	st, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "events.db"), 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	w = backfillRequest(t, NewEventService(slog.Default(), st), []eventsv1http.UsageEvent{timedEvent("a", "21:00:00", true)})
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 for a store without backfill, got %d", w.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// goldenEventsPath is the JSON both templates must produce for the same
+// events. The gRPC template's tests check it too.
+const goldenEventsPath = "../testdata/usage_events.golden.json"
+
+// readGolden returns the golden events file and its decoded documents.
+func readGolden(t *testing.T) ([]byte, []any) {
+	t.Helper()
+	data, err := os.ReadFile(goldenEventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []any
+	if err := json.Unmarshal(data, &docs); err != nil {
+		t.Fatal(err)
+	}
+	return data, docs
+}
+
+func TestConformance_GoldenJSON(t *testing.T) {
+	data, want := readGolden(t)
+	var req eventsv1http.PublishEventsRequest
+	if err := json.Unmarshal(data, &req.Events); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := testService()
+	if w := publishRequest(t, svc, req); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?sort=timestamp_asc", nil))
+	var got []any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GET /events diverged from the golden JSON:\n got %s\nwant %s", w.Body.String(), data)
+	}
+
+	for i, ev := range req.Events {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(doc, want[i]) {
+			t.Errorf("event %d: encoded as %s, want the golden document", i, body)
+		}
+	}
+}
+
+// TestStatusCode_Int32 checks that a status code keeps its int32 type and
+// value through every encoding the template reads or writes, matching the
+// protobuf field.
+func TestStatusCode_Int32(t *testing.T) {
+	const want int32 = 429
+	ev := eventsv1http.UsageEvent{Key: "k", Method: "GET", Path: "/", StatusCode: want}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded eventsv1http.UsageEvent
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.StatusCode != want {
+		t.Errorf("JSON: got %d from %s", decoded.StatusCode, body)
+	}
+
+	wire, err := proto.Marshal(toProto(ev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg eventsv1.UsageEvent
+	if err := proto.Unmarshal(wire, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := fromProto(&msg).StatusCode; got != want {
+		t.Errorf("protobuf: got %d", got)
+	}
+
+	st, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "events.db"), 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.Append(context.Background(), []eventsv1http.UsageEvent{ev}); err != nil {
+		t.Fatal(err)
+	}
+	err = st.Scan(context.Background(), 0, func(_ int64, stored eventsv1http.UsageEvent) bool {
+		if stored.StatusCode != want {
+			t.Errorf("sqlite: got %d", stored.StatusCode)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Media types accepted by POST /events.
+const (
+	mediaTypeJSON   = "application/json"
+	mediaTypeNDJSON = "application/x-ndjson"
+	// mediaTypeProtobuf bodies are binary messages of the gRPC protocol.
+	mediaTypeProtobuf = "application/x-protobuf"
+)
+
+// batchTooLargeError reports a streamed batch that went past max events.
+// Decoding stops there, so the full size is unknown.
+type batchTooLargeError struct {
+	max int
+}
+
+func (e *batchTooLargeError) Error() string {
+	return fmt.Sprintf("batch exceeds the maximum of %d events", e.max)
+}
+
+// errTrailingData reports a JSON body with more after its first value,
+// such as two concatenated requests.
+var errTrailingData = errors.New("request body must hold a single JSON object")
+
+// unknownFieldError reports a JSON field the request type doesn't have.
+type unknownFieldError struct {
+	field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %s", e.field)
+}
+
+// newJSONDecoder returns a decoder for body that, with disallowUnknown,
+// fails on fields the target type doesn't have.
+func newJSONDecoder(body io.Reader, disallowUnknown bool) *json.Decoder {
+	dec := json.NewDecoder(body)
+	if disallowUnknown {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// asUnknownFieldError turns the error encoding/json reports for an
+// unknown field into an unknownFieldError naming it, and returns other
+// errors unchanged.
+func asUnknownFieldError(err error) error {
+	if err == nil {
+		return nil
+	}
+	// encoding/json has no error type for this, only the message.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &unknownFieldError{field: field}
+	}
+	return err
+}
+
+// eventSource yields the events of a publish body.
+type eventSource interface {
+	// next returns up to n more events, or all that are left when n is
+	// zero or less. It returns io.EOF once every event has been returned,
+	// and an empty batch only for a body without events.
+	next(n int) ([]eventsv1http.UsageEvent, error)
+}
+
+// newEventSource returns the events of a publish body of the given media
+// type. maxBatch bounds how many JSON events or NDJSON lines are read; zero
+// or a negative value reads them all. disallowUnknown makes fields JSON and NDJSON
+// bodies have beyond the request's an error.
+//
+// JSON bodies are decoded an event at a time as they are asked for, so a
+// large batch needn't be held as both JSON and events; the other media
+// types are decoded whole.
+func newEventSource(mediaType string, body io.Reader, maxBatch int, disallowUnknown bool) eventSource {
+	switch mediaType {
+	case mediaTypeNDJSON:
+		return &decodedBody{decode: func() (eventsv1http.PublishEventsRequest, error) {
+			return decodeNDJSON(body, maxBatch, disallowUnknown)
+		}}
+	case mediaTypeProtobuf:
+		return &decodedBody{decode: func() (eventsv1http.PublishEventsRequest, error) {
+			return decodeProtobuf(body)
+		}}
+	default:
+		return &jsonEventStream{dec: newJSONDecoder(body, disallowUnknown), disallowUnknown: disallowUnknown, max: maxBatch}
+	}
+}
+
+// decodedBody is an eventSource for a body decoded whole, which its first
+// next returns regardless of n.
+type decodedBody struct {
+	decode func() (eventsv1http.PublishEventsRequest, error)
+	done   bool
+}
+
+func (b *decodedBody) next(int) ([]eventsv1http.UsageEvent, error) {
+	if b.done {
+		return nil, io.EOF
+	}
+	b.done = true
+	req, err := b.decode()
+	return req.Events, err
+}
+
+// jsonEventStream reads a JSON PublishEventsRequest, which must be the
+// whole body, decoding its events one at a time. Like encoding/json, it
+// matches the events field case-insensitively and reads a null body or
+// events field as no events. Once max events have been read, one more is
+// a batchTooLargeError, reported without decoding the rest.
+type jsonEventStream struct {
+	dec             *json.Decoder
+	disallowUnknown bool
+	max             int
+	// opened is set once the request's opening brace has been read, and
+	// inEvents while the decoder is inside its events array.
+	opened   bool
+	inEvents bool
+	done     bool
+	// read counts the events decoded so far.
+	read int
+}
+
+func (st *jsonEventStream) next(n int) ([]eventsv1http.UsageEvent, error) {
+	if st.done {
+		return nil, io.EOF
+	}
+	var batch []eventsv1http.UsageEvent
+	for {
+		if !st.inEvents {
+			found, err := st.openEvents()
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				st.done = true
+				if len(batch) == 0 && st.read > 0 {
+					return nil, io.EOF
+				}
+				return batch, nil
+			}
+			st.inEvents = true
+		}
+		for st.dec.More() {
+			if n > 0 && len(batch) == n {
+				return batch, nil
+			}
+			if st.max > 0 && st.read == st.max {
+				return nil, &batchTooLargeError{max: st.max}
+			}
+			var ev eventsv1http.UsageEvent
+			if err := st.dec.Decode(&ev); err != nil {
+				return nil, asUnknownFieldError(err)
+			}
+			batch = append(batch, ev)
+			st.read++
+		}
+		// The closing bracket.
+		if _, err := st.dec.Token(); err != nil {
+			return nil, err
+		}
+		st.inEvents = false
+	}
+}
+
+// openEvents reads up to the start of the request's next events array and
+// reports whether there is one. At the end of the request it checks that
+// nothing follows.
+func (st *jsonEventStream) openEvents() (bool, error) {
+	if !st.opened {
+		tok, err := st.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		st.opened = true
+		if tok == nil {
+			return false, st.checkEnd()
+		}
+		if tok != json.Delim('{') {
+			return false, errors.New("request body is not a JSON object")
+		}
+	}
+	for st.dec.More() {
+		tok, err := st.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		key, _ := tok.(string)
+		if !strings.EqualFold(key, "events") {
+			if st.disallowUnknown {
+				return false, &unknownFieldError{field: strconv.Quote(key)}
+			}
+			var skip json.RawMessage
+			if err := st.dec.Decode(&skip); err != nil {
+				return false, err
+			}
+			continue
+		}
+		tok, err = st.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		switch tok {
+		case json.Delim('['):
+			return true, nil
+		case nil:
+		default:
+			return false, errors.New("events is not a JSON array")
+		}
+	}
+	// The closing brace.
+	if _, err := st.dec.Token(); err != nil {
+		return false, err
+	}
+	return false, st.checkEnd()
+}
+
+// checkEnd returns errTrailingData unless the body ends after the request.
+func (st *jsonEventStream) checkEnd() error {
+	if _, err := st.dec.Token(); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
+	return nil
+}
+
+// decodeJSON reads a JSON PublishEventsRequest, which must be the whole
+// body.
+func decodeJSON(body io.Reader, disallowUnknown bool) (eventsv1http.PublishEventsRequest, error) {
+	var req eventsv1http.PublishEventsRequest
+	dec := newJSONDecoder(body, disallowUnknown)
+	if err := dec.Decode(&req); err != nil {
+		return req, asUnknownFieldError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return req, errTrailingData
+	}
+	return req, nil
+}
+
+// decodeNDJSON reads one UsageEvent per line and returns the whole stream
+// as one batch.
+func decodeNDJSON(body io.Reader, maxBatch int, disallowUnknown bool) (eventsv1http.PublishEventsRequest, error) {
+	var req eventsv1http.PublishEventsRequest
+	dec := newJSONDecoder(body, disallowUnknown)
+	for {
+		var ev eventsv1http.UsageEvent
+		err := dec.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			return req, nil
+		}
+		if err != nil {
+			return req, asUnknownFieldError(err)
+		}
+		if maxBatch > 0 && len(req.Events) == maxBatch {
+			return req, &batchTooLargeError{max: maxBatch}
+		}
+		req.Events = append(req.Events, ev)
+	}
+}
+
+// decodeProtobuf reads a binary eventsv1.PublishEventsRequest.
+func decodeProtobuf(body io.Reader) (eventsv1http.PublishEventsRequest, error) {
+	var req eventsv1http.PublishEventsRequest
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return req, err
+	}
+	var msg eventsv1.PublishEventsRequest
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return req, err
+	}
+	req.Events = make([]eventsv1http.UsageEvent, len(msg.GetEvents()))
+	for i, ev := range msg.GetEvents() {
+		req.Events[i] = fromProto(ev)
+	}
+	return req, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// apiEndpoint describes one route in the discovery document.
+type apiEndpoint struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Query       []string `json:"query,omitempty"`
+}
+
+// apiDocument is the body of GET /: a machine-readable list of the routes
+// newMux registers.
+type apiDocument struct {
+	Service   string        `json:"service"`
+	Endpoints []apiEndpoint `json:"endpoints"`
+}
+
+// withFilters returns the list filter parameters followed by extra.
+func withFilters(extra ...string) []string {
+	return append(slices.Clone(eventcore.FilterParams), extra...)
+}
+
+var apiDoc = apiDocument{
+	Service: "edgequota-external-events-http",
+	Endpoints: []apiEndpoint{
+		{Method: "POST", Path: "/events", Description: "Publish a batch of usage events (PublishEventsRequest JSON)", Query: []string{"strict", "dry_run"}},
+		{Method: "POST", Path: "/events/backfill", Description: "Import historical events, stored in timestamp order"},
+		{Method: "POST", Path: "/events/replay", Description: "Re-publish matching stored events to a target URL", Query: withFilters()},
+		{Method: "GET", Path: "/events", Description: "List stored events, newest first", Query: withFilters("limit", "offset", "cursor", "sort", "order", "format", "envelope")},
+		{Method: "GET", Path: "/events/{request_id}", Description: "Get the newest stored event with a request ID"},
+		{Method: "GET", Path: "/events/count", Description: "Count matching stored events", Query: withFilters()},
+		{Method: "GET", Path: "/events/export.csv", Description: "Export matching stored events as CSV", Query: withFilters()},
+		{Method: "GET", Path: "/events/stream", Description: "Stream newly published events as server-sent events", Query: withFilters()},
+		{Method: "GET", Path: "/events/stats", Description: "Aggregate counters and publish latency"},
+		{Method: "GET", Path: "/events/stats/by-tenant", Description: "Counters per tenant key"},
+		{Method: "GET", Path: "/events/stats/rate", Description: "Events per second over the last 1, 5 and 15 minutes", Query: withFilters()},
+		{Method: "GET", Path: "/events/stats/remaining", Description: "Histogram of the remaining quota of allowed events as a fraction of their limit", Query: withFilters()},
+		{Method: "POST", Path: "/events/stats/reset", Description: "Zero the counters, keeping stored events"},
+		{Method: "GET", Path: "/events/tenants", Description: "Distinct tenant keys of the stored events"},
+		{Method: "GET", Path: "/events/top/keys", Description: "Keys with the most matching stored events", Query: withFilters("n")},
+		{Method: "GET", Path: "/events/top/paths", Description: "Paths with the most matching stored events", Query: withFilters("n", "normalize")},
+		{Method: "DELETE", Path: "/events", Description: "Delete stored events and reset the counters; with filters, delete only matching events", Query: withFilters()},
+		{Method: "GET", Path: "/version", Description: "Build information"},
+		{Method: "GET", Path: "/metrics", Description: "Prometheus metrics"},
+	},
+}
+
+// handleDiscovery returns the static document describing the API.
+func handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	eventcore.WriteJSON(w, http.StatusOK, apiDoc)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestDiscovery(t *testing.T) {
+	mux := newMux(testService())
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc apiDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(method, path string) *apiEndpoint {
+		for i, ep := range doc.Endpoints {
+			if ep.Method == method && ep.Path == path {
+				return &doc.Endpoints[i]
+			}
+		}
+		return nil
+	}
+	if find("POST", "/events") == nil {
+		t.Error("expected the publish route listed")
+	}
+	list := find("GET", "/events")
+	if list == nil {
+		t.Fatal("expected the list route listed")
+	}
+	for _, param := range []string{"tenant_key", "since", "limit", "cursor"} {
+		if !slices.Contains(list.Query, param) {
+			t.Errorf("expected the list route to document %q, got %v", param, list.Query)
+		}
+	}
+
+	// Every documented route must exist.
+	for _, ep := range doc.Endpoints {
+		if _, pattern := mux.Handler(httptest.NewRequest(ep.Method, ep.Path, nil)); pattern == "" {
+			t.Errorf("%s %s is documented but not routed", ep.Method, ep.Path)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown path, got %d", w.Code)
+	}
+}
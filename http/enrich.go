@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// Enricher derives or rewrites fields of a published event before it is
+// stored. It runs on every accepted event after validation, so sinks and
+// the live stream see the enriched event too, and must be safe for
+// concurrent use.
+type Enricher func(ev *eventsv1http.UsageEvent)
+
+// noopEnricher is the default Enricher and leaves events unchanged.
+func noopEnricher(*eventsv1http.UsageEvent) {}
+
+// enrichBatch runs s.enrich on every event of batch.
+func (s *EventService) enrichBatch(batch []eventsv1http.UsageEvent) {
+	for i := range batch {
+		s.enrich(&batch[i])
+	}
+}
+
+// NewPathTemplateEnricher returns an Enricher that replaces an event's path
+// with the first of templates it matches, such as "/users/{id}" for
+// "/users/42", so that events for the same route share a path. A "{name}"
+// segment matches any one non-empty segment and other segments must match
+// exactly. Paths matching no template are left as they are.
+func NewPathTemplateEnricher(templates []string) (Enricher, error) {
+	parsed := make([][]string, len(templates))
+	for i, tmpl := range templates {
+		if !strings.HasPrefix(tmpl, "/") {
+			return nil, fmt.Errorf("path template %q must start with /", tmpl)
+		}
+		parsed[i] = strings.Split(tmpl, "/")
+		for _, seg := range parsed[i] {
+			if strings.ContainsAny(seg, "{}") && !isTemplateParam(seg) {
+				return nil, fmt.Errorf("path template %q: segment %q must be a literal or a whole {name}", tmpl, seg)
+			}
+		}
+	}
+	return func(ev *eventsv1http.UsageEvent) {
+		segs := strings.Split(ev.Path, "/")
+		for i, tmpl := range parsed {
+			if matchTemplate(tmpl, segs) {
+				ev.Path = templates[i]
+				return
+			}
+		}
+	}, nil
+}
+
+func isTemplateParam(seg string) bool {
+	return len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}'
+}
+
+// matchTemplate reports whether the path segments segs match the template
+// segments tmpl.
+func matchTemplate(tmpl, segs []string) bool {
+	if len(tmpl) != len(segs) {
+		return false
+	}
+	for i, t := range tmpl {
+		if isTemplateParam(t) {
+			if segs[i] == "" {
+				return false
+			}
+		} else if t != segs[i] {
+			return false
+		}
+	}
+	return true
+}
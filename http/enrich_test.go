@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func TestPathTemplateEnricher(t *testing.T) {
+	enrich, err := NewPathTemplateEnricher([]string{
+		"/users/{id}",
+		"/users/{id}/orders/{order}",
+		"/users/me/orders/{order}",
+		"/health",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ path, want string }{
+		{"/users/42", "/users/{id}"},
+		{"/users/alice", "/users/{id}"},
+		{"/users/42/orders/7", "/users/{id}/orders/{order}"},
+		// The first matching template wins.
+		{"/users/me/orders/7", "/users/{id}/orders/{order}"},
+		{"/health", "/health"},
+		{"/users/", "/users/"},
+		{"/users/42/profile", "/users/42/profile"},
+		{"/teams/42", "/teams/42"},
+	} {
+		ev := eventsv1http.UsageEvent{Key: "k", Path: tc.path}
+		enrich(&ev)
+		if ev.Path != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.path, tc.want, ev.Path)
+		}
+	}
+
+	for _, bad := range []string{"users/{id}", "/users/id-{id}", "/users/{}"} {
+		if _, err := NewPathTemplateEnricher([]string{bad}); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestPublishEvents_Enrichers(t *testing.T) {
+	templates, err := NewPathTemplateEnricher([]string{"/users/{id}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	svc := NewEventService(slog.Default(), newMemoryStore(0),
+		WithEnricher(templates),
+		WithEnricher(func(ev *eventsv1http.UsageEvent) { seen = append(seen, ev.Path) }),
+	)
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{
+		{Key: "a", Path: "/users/1"},
+		{Key: "b", Path: "/other"},
+	}})
+
+	stored := svc.StoredEvents()
+	if len(stored) != 2 || stored[0].Path != "/users/{id}" || stored[1].Path != "/other" {
+		t.Errorf("expected the templated path stored, got %+v", stored)
+	}
+	if len(seen) != 2 || seen[0] != "/users/{id}" {
+		t.Errorf("expected enrichers to run in order on every event, got %v", seen)
+	}
+	plain := testService()
+	publishRequest(t, plain, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{{Key: "a", Path: "/users/1"}}})
+	if got := plain.StoredEvents()[0].Path; got != "/users/1" {
+		t.Errorf("expected paths kept as sent without enrichers, got %s", got)
+	}
+}
@@ -1,150 +1,652 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
-	"sync"
-	"sync/atomic"
+	"strings"
+	"time"
 
 	"github.com/edgequota/edgequota-go/events"
 	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
-const maxStoredEvents = 10000
+// EventStats, RateStats and RemainingStats are the bodies of GET
+// /events/stats, /events/stats/rate and /events/stats/remaining.
+type (
+	EventStats     = eventcore.EventStats
+	RateStats      = eventcore.RateStats
+	RemainingStats = eventcore.RemainingStats
+)
+
+const (
+	defaultMaxBatch     = 10000
+	defaultMaxBodyBytes = 4 << 20
+	// defaultListLimit and defaultMaxListLimit bound the events returned by
+	// one GET /events.
+	defaultListLimit    = 100
+	defaultMaxListLimit = 1000
+	// publishChunkSize is how many events of a JSON publish body are
+	// stored at a time when no batch or body limit applies.
+	publishChunkSize = 1000
+)
 
-type errorResponse struct {
-	Error string `json:"error"`
+// eventFields adapts UsageEvent to the shared query API and stores. Its
+// fields map one to one onto eventcore.Event, and stores that serialize
+// events keep them as JSON.
+var eventFields = eventcore.Fields[eventsv1http.UsageEvent]{
+	View: func(ev eventsv1http.UsageEvent) eventcore.Event {
+		return eventcore.Event{
+			Key:        ev.Key,
+			TenantKey:  ev.TenantKey,
+			Method:     ev.Method,
+			Path:       ev.Path,
+			Allowed:    ev.Allowed,
+			Remaining:  ev.Remaining,
+			Limit:      ev.Limit,
+			Timestamp:  ev.Timestamp,
+			StatusCode: ev.StatusCode,
+			RequestID:  ev.RequestId,
+			Reason:     ev.Reason,
+		}
+	},
+	Make: func(ev eventcore.Event) eventsv1http.UsageEvent {
+		return eventsv1http.UsageEvent{
+			Key:        ev.Key,
+			TenantKey:  ev.TenantKey,
+			Method:     ev.Method,
+			Path:       ev.Path,
+			Allowed:    ev.Allowed,
+			Remaining:  ev.Remaining,
+			Limit:      ev.Limit,
+			Timestamp:  ev.Timestamp,
+			StatusCode: ev.StatusCode,
+			RequestId:  ev.RequestID,
+			Reason:     ev.Reason,
+		}
+	},
+	Reasons: true,
+	JSON:    func(ev eventsv1http.UsageEvent) any { return ev },
+	ProtoBatch: func(events []eventsv1http.UsageEvent) proto.Message {
+		return toProtoBatch(events)
+	},
+	Encode: func(ev eventsv1http.UsageEvent) ([]byte, error) { return json.Marshal(ev) },
+	Decode: func(data []byte) (eventsv1http.UsageEvent, error) {
+		var ev eventsv1http.UsageEvent
+		err := json.Unmarshal(data, &ev)
+		return ev, err
+	},
+	Size: func(ev eventsv1http.UsageEvent) int64 { return approxEventBytes(&ev) },
 }
 
-type EventStats struct {
-	TotalReceived int64 `json:"total_received"`
-	TotalAllowed  int64 `json:"total_allowed"`
-	TotalDenied   int64 `json:"total_denied"`
-	StoredEvents  int   `json:"stored_events"`
+func tenantKeyOf(ev *eventsv1http.UsageEvent) string { return stringValue(ev.TenantKey) }
+
+func stringValue(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
 }
 
+// EventService serves the HTTP publish path, and the query API through
+// the embedded eventcore.Service.
 type EventService struct {
-	logger *slog.Logger
+	*eventcore.Service[eventsv1http.UsageEvent]
+
+	logger   *slog.Logger
+	storage  Store
+	sinks    []Sink
+	maxBatch int
+	maxBody  int64
+	// cfg collects the query API settings given as options until the
+	// Service is built.
+	cfg eventcore.Config
+	// lenientContentType accepts publishes regardless of Content-Type.
+	lenientContentType bool
+	// disallowUnknownFields rejects JSON publishes with fields that
+	// aren't part of PublishEventsRequest.
+	disallowUnknownFields bool
+	// idempotency replays responses to retried publishes; nil disables it.
+	idempotency *idempotencyCache
+	// limiter rate limits publishes per source; nil disables it.
+	limiter *eventcore.SourceLimiter
+	// maxFieldLen caps the length in bytes of events' string fields;
+	// events over it are rejected, or with truncateFields shortened.
+	// Zero or a negative value disables the limit.
+	maxFieldLen    int
+	truncateFields bool
+	// rawTimestamps stores event timestamps as sent instead of
+	// normalizing them to RFC 3339.
+	rawTimestamps bool
+	// schema rejects published batches whose events don't match it; nil
+	// disables validation.
+	schema *eventSchema
+	// enrich runs on each accepted event before it is stored.
+	enrich Enricher
+	// sampleRate is the fraction of published events that are stored;
+	// every event is still counted in the stats.
+	sampleRate float64
+	// replayAllow lists the hosts POST /events/replay may send to; when
+	// empty, replay is disabled.
+	replayAllow []string
 
-	mu     sync.RWMutex
-	stored []eventsv1http.UsageEvent
+	clock eventcore.Clock
+
+	tracerProvider trace.TracerProvider
+	tracer         trace.Tracer
+}
+
+// Sink receives every accepted batch, after it has been stored. Publish
+// must not block.
+type Sink interface {
+	Publish(batch []eventsv1http.UsageEvent)
+}
+
+// Option configures an EventService.
+type Option func(*EventService)
+
+// WithMaxBatch caps the number of events accepted in a single publish.
+// Zero or a negative value disables the limit.
+func WithMaxBatch(n int) Option {
+	return func(s *EventService) { s.maxBatch = n }
+}
 
-	totalReceived atomic.Int64
-	totalAllowed  atomic.Int64
-	totalDenied   atomic.Int64
+// WithMaxBodyBytes caps the size of a publish request body. Zero or a
+// negative value disables the limit.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *EventService) { s.maxBody = n }
 }
 
-func NewEventService(logger *slog.Logger) *EventService {
-	return &EventService{
-		logger: logger,
-		stored: make([]eventsv1http.UsageEvent, 0, 1024),
+// WithLenientContentType accepts publish requests with any Content-Type
+// instead of requiring application/json.
+func WithLenientContentType(lenient bool) Option {
+	return func(s *EventService) { s.lenientContentType = lenient }
+}
+
+// WithDisallowUnknownFields rejects JSON and NDJSON publishes holding a
+// field PublishEventsRequest or UsageEvent doesn't have, instead of
+// ignoring it, to catch client bugs such as misspelt fields.
+func WithDisallowUnknownFields(enabled bool) Option {
+	return func(s *EventService) { s.disallowUnknownFields = enabled }
+}
+
+// WithIdempotency remembers the response to each Idempotency-Key header for
+// ttl, keeping at most keys of them. Zero or a negative keys value disables
+// Idempotency-Key handling.
+func WithIdempotency(keys int, ttl time.Duration) Option {
+	return func(s *EventService) {
+		s.idempotency = nil
+		if keys > 0 {
+			s.idempotency = newIdempotencyCache(keys, ttl)
+		}
 	}
 }
 
-func (s *EventService) HandlePublishEvents(w http.ResponseWriter, r *http.Request) {
-	var req eventsv1http.PublishEventsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
-		return
+// WithRateLimit limits each source, identified by its remote IP, to
+// perSecond publishes per second with bursts of up to burst. Zero or a
+// negative perSecond disables rate limiting.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(s *EventService) {
+		s.limiter = nil
+		if perSecond > 0 {
+			s.limiter = eventcore.NewSourceLimiter(perSecond, burst)
+		}
 	}
+}
 
-	count := int64(len(req.Events))
-	var allowed, denied int64
-	for _, ev := range req.Events {
-		if ev.Allowed {
-			allowed++
-		} else {
-			denied++
+// WithListLimits sets the number of events GET /events returns when no
+// limit is given, and the largest limit it honours; larger ones are lowered
+// to it. A defaultLimit <= 0 keeps the built-in default, and a maxLimit <= 0
+// removes the ceiling.
+func WithListLimits(defaultLimit, maxLimit int) Option {
+	return func(s *EventService) {
+		if defaultLimit > 0 {
+			s.cfg.ListDefault = defaultLimit
 		}
+		s.cfg.ListMax = maxLimit
 	}
+}
 
-	s.store(req.Events)
-	s.totalReceived.Add(count)
-	s.totalAllowed.Add(allowed)
-	s.totalDenied.Add(denied)
+// WithListEnvelope makes GET /events answer with an envelope object
+// instead of a bare array by default. Requests can still choose with the
+// envelope parameter.
+func WithListEnvelope(enabled bool) Option {
+	return func(s *EventService) { s.cfg.ListEnvelope = enabled }
+}
 
-	s.logger.Info("events received", "count", count, "allowed", allowed, "denied", denied)
-	resp := events.Accepted(len(req.Events))
-	writeJSON(w, http.StatusOK, resp)
+// WithMaxFieldLength caps the length in bytes of each string field of a
+// published event at n. Events with a longer field are rejected like other
+// invalid events, or, with truncate, stored with the field shortened to n.
+// Zero or a negative n disables the limit.
+func WithMaxFieldLength(n int, truncate bool) Option {
+	return func(s *EventService) { s.maxFieldLen, s.truncateFields = n, truncate }
+}
+
+// WithTimestampNormalization controls whether published timestamps in
+// other common formats are rewritten to RFC 3339 before storage. It is on
+// by default.
+func WithTimestampNormalization(enabled bool) Option {
+	return func(s *EventService) { s.rawTimestamps = !enabled }
 }
 
-func (s *EventService) HandleListEvents(w http.ResponseWriter, r *http.Request) {
-	tenantFilter := r.URL.Query().Get("tenant_key")
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100
-	if limitStr != "" {
-		if n, err := strconv.Atoi(limitStr); err == nil && n > 0 {
-			limit = n
+// WithSchema rejects published batches containing an event that doesn't
+// match schema.
+func WithSchema(schema *eventSchema) Option {
+	return func(s *EventService) { s.schema = schema }
+}
+
+// WithWatermarks applies backpressure as the store fills towards capacity
+// events. Once it holds a soft fraction of capacity, publishes still
+// succeed but carry a warning and a Retry-After hint; at hard, they are
+// refused until it drains. A zero fraction disables that watermark, and a
+// capacity <= 0 disables both.
+func WithWatermarks(capacity int, soft, hard float64) Option {
+	return func(s *EventService) {
+		s.cfg.Watermarks = nil
+		if capacity > 0 && (soft > 0 || hard > 0) {
+			s.cfg.Watermarks = &eventcore.Watermarks{Capacity: capacity, Soft: soft, Hard: hard}
 		}
 	}
+}
 
-	s.mu.RLock()
-	result := make([]eventsv1http.UsageEvent, 0, min(limit, len(s.stored)))
-	for i := len(s.stored) - 1; i >= 0 && len(result) < limit; i-- {
-		ev := s.stored[i]
-		if tenantFilter != "" {
-			tk := ""
-			if ev.TenantKey != nil {
-				tk = *ev.TenantKey
-			}
-			if tk != tenantFilter {
-				continue
+// WithSampleRate stores only the fraction rate of published events,
+// chosen by a hash of each event's request ID. Every event is still
+// counted in the stats and passed to sinks and the live stream. Rates are
+// clamped to [0, 1].
+func WithSampleRate(rate float64) Option {
+	return func(s *EventService) {
+		s.sampleRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithEnricher runs e on each accepted event before it is stored. It may
+// be given more than once; enrichers run in the order given.
+func WithEnricher(e Enricher) Option {
+	return func(s *EventService) {
+		prev := s.enrich
+		s.enrich = func(ev *eventsv1http.UsageEvent) {
+			prev(ev)
+			e(ev)
+		}
+	}
+}
+
+// WithTracerProvider records spans for publishes and queries with tp.
+// Without it spans go to the global provider, which discards them unless
+// one has been installed.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *EventService) { s.tracerProvider = tp }
+}
+
+// WithSink forwards accepted events to sink. It may be given more than once.
+func WithSink(sink Sink) Option {
+	return func(s *EventService) { s.sinks = append(s.sinks, sink) }
+}
+
+// WithReplayAllow lets POST /events/replay send events to hosts, each a
+// host name or IP, which allows any port, or a host:port. Empty entries are
+// ignored. Without any, replay is refused, since it would otherwise make
+// requests to whatever target a caller names.
+func WithReplayAllow(hosts []string) Option {
+	return func(s *EventService) {
+		for _, h := range hosts {
+			if h = strings.TrimSpace(h); h != "" {
+				s.replayAllow = append(s.replayAllow, h)
 			}
 		}
-		result = append(result, ev)
 	}
-	s.mu.RUnlock()
+}
 
-	writeJSON(w, http.StatusOK, result)
+// WithClock sets the clock the service tells time by; the default is the
+// system clock.
+func WithClock(c eventcore.Clock) Option {
+	return func(s *EventService) { s.clock = c }
 }
 
-func (s *EventService) HandleStats(w http.ResponseWriter, _ *http.Request) {
-	s.mu.RLock()
-	n := len(s.stored)
-	s.mu.RUnlock()
+func NewEventService(logger *slog.Logger, storage Store, opts ...Option) *EventService {
+	s := &EventService{
+		logger:   logger,
+		storage:  storage,
+		maxBatch: defaultMaxBatch,
+		maxBody:  defaultMaxBodyBytes,
+		cfg: eventcore.Config{
+			ListDefault: defaultListLimit,
+			ListMax:     defaultMaxListLimit,
+		},
+		idempotency: newIdempotencyCache(defaultIdempotencyKeys, defaultIdempotencyTTL),
+		sampleRate:  1,
+		enrich:      noopEnricher,
+		clock:       eventcore.RealClock{},
 
-	writeJSON(w, http.StatusOK, EventStats{
-		TotalReceived: s.totalReceived.Load(),
-		TotalAllowed:  s.totalAllowed.Load(),
-		TotalDenied:   s.totalDenied.Load(),
-		StoredEvents:  n,
-	})
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cfg.Clock = s.clock
+	s.Service = eventcore.NewService(logger, storage, eventFields, s.cfg)
+	if s.idempotency != nil {
+		s.idempotency.now = s.clock.Now
+	}
+	if s.limiter != nil {
+		s.limiter.Now = s.clock.Now
+	}
+	s.tracer = s.tracerProvider.Tracer(tracerName)
+	return s
+}
+
+func (s *EventService) HandlePublishEvents(w http.ResponseWriter, r *http.Request) {
+	if s.limiter != nil && !s.limiter.Allow(eventcore.SourceHost(r.RemoteAddr)) {
+		eventcore.WriteJSON(w, http.StatusTooManyRequests, eventcore.ErrorResponse{Error: "publish rate limit exceeded"})
+		return
+	}
+	start := s.clock.Now()
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("invalid dry_run %q", v)})
+			return
+		}
+	}
+
+	// A retry carrying an Idempotency-Key we've already answered gets the
+	// original response without the batch being stored again. Dry runs
+	// store nothing, so they neither use nor claim a key.
+	var accepted *publishResponse
+	if key := r.Header.Get("Idempotency-Key"); key != "" && s.idempotency != nil && !dryRun {
+		if len(key) > maxIdempotencyKeyLen {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{
+				Error: fmt.Sprintf("Idempotency-Key exceeds the maximum of %d bytes", maxIdempotencyKeyLen),
+			})
+			return
+		}
+		switch resp, state := s.idempotency.claim(key); state {
+		case idempotencyReplay:
+			w.Header().Set("Idempotent-Replayed", "true")
+			writePublishResponse(w, r, resp)
+			return
+		case idempotencyInFlight:
+			eventcore.WriteJSON(w, http.StatusConflict, eventcore.ErrorResponse{Error: "a request with this Idempotency-Key is still in progress"})
+			return
+		}
+		defer func() { s.idempotency.finish(key, accepted) }()
+	}
+
+	// Lenient mode treats any unrecognised Content-Type as JSON.
+	mediaType := mediaTypeJSON
+	ct := r.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil && (mt == mediaTypeJSON || mt == mediaTypeNDJSON || mt == mediaTypeProtobuf) {
+		mediaType = mt
+	} else if !s.lenientContentType {
+		eventcore.WriteJSON(w, http.StatusUnsupportedMediaType, eventcore.ErrorResponse{
+			Error: fmt.Sprintf("unsupported Content-Type %q, want %s, %s or %s", ct, mediaTypeJSON, mediaTypeNDJSON, mediaTypeProtobuf),
+		})
+		return
+	}
+
+	body := r.Body
+	switch enc := r.Header.Get("Content-Encoding"); {
+	case enc == "" || strings.EqualFold(enc, "identity"):
+	case strings.EqualFold(enc, "gzip"):
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "invalid gzip body"})
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		eventcore.WriteJSON(w, http.StatusUnsupportedMediaType, eventcore.ErrorResponse{Error: fmt.Sprintf("unsupported Content-Encoding %q", enc)})
+		return
+	}
+	// The limit applies to the decoded body so compressed payloads can't
+	// expand past it.
+	if s.maxBody > 0 {
+		body = http.MaxBytesReader(w, body, s.maxBody)
+	}
+
+	strict := false
+	if v := r.URL.Query().Get("strict"); v != "" {
+		var err error
+		if strict, err = strconv.ParseBool(v); err != nil {
+			eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("invalid strict %q", v)})
+			return
+		}
+	}
+
+	// Without a batch or body limit, a JSON body is published in chunks
+	// as it is decoded, so that memory doesn't grow with the batch. A
+	// limit refuses a batch as a whole, and so do strict mode and schema
+	// validation, so with any of them the whole batch is read first and
+	// nothing is stored if it is refused.
+	chunk := 0
+	if s.maxBatch <= 0 && s.maxBody <= 0 && !strict && s.schema == nil {
+		chunk = publishChunkSize
+	}
+	p := pendingPublish{dryRun: dryRun, strict: strict}
+	src := newEventSource(mediaType, body, s.maxBatch, s.disallowUnknownFields)
+	for {
+		batch, err := src.next(chunk)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			status, msg := decodeError(err)
+			p.fail(w, r, status, msg)
+		} else if s.publishChunk(w, r, &p, batch) {
+			continue
+		}
+		if p.partial != nil {
+			accepted = p.partial
+			s.ObservePublish(s.clock.Now().Sub(start))
+			s.logger.Warn("publish failed part-way", "count", p.tally.Received, "error", p.partial.Error)
+		}
+		return
+	}
+
+	count, allowed, denied := p.tally.Received, p.tally.Allowed, p.tally.Denied
+	if dryRun {
+		s.logger.Info("dry run", "count", count, "allowed", allowed, "denied", denied, "rejected", len(p.rejected))
+		writePublishResponse(w, r, publishResponse{PublishEventsResponse: events.Accepted(int(count)), Rejected: p.rejected, DryRun: true, PerTenant: acceptedPerTenant(p.tally)})
+		return
+	}
+	s.ObservePublish(s.clock.Now().Sub(start))
+	s.logger.Info("events received", "count", count, "allowed", allowed, "denied", denied)
+	// Replays get the same response without the warning, which only
+	// described the store at the time.
+	resp := publishResponse{PublishEventsResponse: events.Accepted(int(count)), Rejected: p.rejected, PerTenant: acceptedPerTenant(p.tally)}
+	accepted = &resp
+	out := resp
+	out.Warning = p.warning
+	writePublishResponse(w, r, out)
+}
+
+// pendingPublish accumulates the outcome of a publish across its chunks.
+type pendingPublish struct {
+	dryRun, strict bool
+	// read counts the events of the chunks so far, valid or not, so that
+	// rejections can be reported by their index in the whole batch.
+	read     int
+	rejected []rejectedEvent
+	tally    eventcore.Tally
+	warning  string
+	// partial is the answer to a publish that failed after some of its
+	// chunks were stored; see fail.
+	partial *publishResponse
+}
+
+// fail answers a publish that failed with status and msg. If chunks before
+// the failing one were stored, which only happens without a batch or body
+// limit, they stay stored and counted, so the
+// answer reports them like a success does, along with the error. It is
+// then kept in partial, to be replayed for the publish's Idempotency-Key
+// so that a retry can't store those events twice.
+func (p *pendingPublish) fail(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if p.dryRun || p.tally.Received == 0 {
+		eventcore.WriteJSON(w, status, eventcore.ErrorResponse{Error: msg})
+		return
+	}
+	p.partial = &publishResponse{
+		PublishEventsResponse: events.Accepted(int(p.tally.Received)),
+		Rejected:              p.rejected,
+		PerTenant:             acceptedPerTenant(p.tally),
+		Error:                 msg,
+		status:                status,
+	}
+	writePublishResponse(w, r, *p.partial)
 }
 
-func (s *EventService) HandleClearEvents(w http.ResponseWriter, _ *http.Request) {
-	s.mu.Lock()
-	s.stored = s.stored[:0]
-	s.mu.Unlock()
-	s.totalReceived.Store(0)
-	s.totalAllowed.Store(0)
-	s.totalDenied.Store(0)
+// publishChunk validates a chunk of a publish's events and, unless it is a
+// dry run, stores them, counts them and passes them on. A chunk is the
+// whole batch unless the body is published in chunks, in which case a
+// refused chunk leaves the ones before it stored. It answers a refused
+// chunk itself, through p.fail, and then returns false.
+func (s *EventService) publishChunk(w http.ResponseWriter, r *http.Request, p *pendingPublish, batch []eventsv1http.UsageEvent) bool {
+	if s.maxBatch > 0 && len(batch) > s.maxBatch {
+		p.fail(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch of %d events exceeds the maximum of %d", len(batch), s.maxBatch))
+		return false
+	}
+
+	s.truncateLongFields(batch)
+	batch, rejected := partitionValid(batch, s.maxFieldLen)
+	for i := range rejected {
+		rejected[i].Index += p.read
+	}
+	p.read += len(batch) + len(rejected)
+	if p.strict && len(rejected) > 0 {
+		eventcore.WriteJSON(w, http.StatusBadRequest, rejectedResponse{
+			Error:    fmt.Sprintf("%d of the batch's events are invalid", len(rejected)),
+			Rejected: rejected,
+		})
+		return false
+	}
+	p.rejected = append(p.rejected, rejected...)
 
-	s.logger.Info("events cleared")
-	w.WriteHeader(http.StatusNoContent)
+	s.normalizeTimestamps(batch)
+	if s.schema != nil {
+		if problems := s.schema.validate(batch); len(problems) > 0 {
+			eventcore.WriteJSON(w, http.StatusUnprocessableEntity, schemaErrorResponse{
+				Error:  "events do not match the schema",
+				Errors: problems,
+			})
+			return false
+		}
+	}
+	s.enrichBatch(batch)
+	tally := eventFields.Tally(batch)
+
+	// A dry run stops here: the batch has been validated and counted, and
+	// nothing is stored, counted in the stats or passed on.
+	if p.dryRun {
+		p.tally.Merge(tally)
+		return true
+	}
+
+	full, warning := s.CheckWatermarks(r.Context())
+	if full || warning != "" {
+		w.Header().Set("Retry-After", eventcore.WatermarkRetryAfter)
+	}
+	if full {
+		p.fail(w, r, http.StatusServiceUnavailable, "event store is full, retry later")
+		return false
+	}
+	p.warning = warning
+
+	err := s.Core().Record(tally, func() error {
+		return s.appendTraced(r.Context(), s.sample(batch), tally)
+	})
+	if err != nil {
+		if errors.Is(err, eventcore.ErrQueueFull) {
+			w.Header().Set("Retry-After", "1")
+			p.fail(w, r, http.StatusServiceUnavailable, "event queue is full, retry later")
+			return false
+		}
+		s.logger.Error("failed to store events", "error", err)
+		p.fail(w, r, http.StatusInternalServerError, "failed to store events")
+		return false
+	}
+	p.tally.Merge(tally)
+	if len(batch) > 0 {
+		s.SetLastEventAt(batch[len(batch)-1].Timestamp)
+	}
+
+	s.Broadcast(batch)
+	for _, sink := range s.sinks {
+		sink.Publish(batch)
+	}
+	s.logEvents(r.Context(), batch)
+	return true
 }
 
-func (s *EventService) store(batch []eventsv1http.UsageEvent) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.stored = append(s.stored, batch...)
-	if len(s.stored) > maxStoredEvents {
-		excess := len(s.stored) - maxStoredEvents
-		s.stored = s.stored[excess:]
+// decodeError returns the status and message answering a publish whose
+// body couldn't be decoded.
+func decodeError(err error) (int, string) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds the maximum of %d bytes", maxErr.Limit)
 	}
+	var batchErr *batchTooLargeError
+	if errors.As(err, &batchErr) {
+		return http.StatusRequestEntityTooLarge, batchErr.Error()
+	}
+	var fieldErr *unknownFieldError
+	if errors.Is(err, errTrailingData) || errors.As(err, &fieldErr) {
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusBadRequest, "invalid request body"
 }
 
-func (s *EventService) StoredEvents() []eventsv1http.UsageEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	out := make([]eventsv1http.UsageEvent, len(s.stored))
-	copy(out, s.stored)
-	return out
+// appendTraced stores batch, counted as t, inside a span, and annotates the
+// request's span with the batch's decision counts.
+func (s *EventService) appendTraced(ctx context.Context, batch []eventsv1http.UsageEvent, t eventcore.Tally) error {
+	attrs := []attribute.KeyValue{
+		attribute.Int("events.count", len(batch)),
+		attribute.Int64("events.allowed", t.Allowed),
+		attribute.Int64("events.denied", t.Denied),
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	ctx, span := s.tracer.Start(ctx, "Store.Append", trace.WithAttributes(attrs[0]))
+	defer span.End()
+	err := s.Core().Append(ctx, batch, t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "failed to store events")
+	}
+	return err
 }
 
-func writeJSON(w http.ResponseWriter, code int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(v)
+// logEvents logs each event of an accepted batch at debug level.
+func (s *EventService) logEvents(ctx context.Context, batch []eventsv1http.UsageEvent) {
+	if !s.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	for i := range batch {
+		ev := &batch[i]
+		s.logger.LogAttrs(ctx, slog.LevelDebug, "event received",
+			slog.String("key", ev.Key),
+			slog.String("tenant_key", tenantKeyOf(ev)),
+			slog.String("method", ev.Method),
+			slog.String("path", ev.Path),
+			slog.Bool("allowed", ev.Allowed),
+			slog.Int64("remaining", ev.Remaining),
+			slog.Int64("limit", ev.Limit),
+			slog.String("request_id", stringValue(ev.RequestId)),
+			slog.String("reason", stringValue(ev.Reason)),
+		)
+	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHead(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), makeEvents(3, 1))
+	srv := httptest.NewServer(newMux(svc))
+	defer srv.Close()
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for _, tc := range []struct {
+		path        string
+		contentType string
+	}{
+		{"/events", "application/json"},
+		{"/events?format=ndjson", "application/x-ndjson"},
+		{"/events/stats", "application/json"},
+		{"/events/count", "application/json"},
+		{"/events/export.csv", "text/csv"},
+		{"/events/stream", "text/event-stream"},
+		{"/version", "application/json"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			resp, err := client.Head(srv.URL + tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); len(ct) < len(tc.contentType) || ct[:len(tc.contentType)] != tc.contentType {
+				t.Errorf("expected Content-Type %s, got %q", tc.contentType, ct)
+			}
+			if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+				t.Errorf("expected no body, got %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIdempotencyKeys = 10000
+	defaultIdempotencyTTL  = 10 * time.Minute
+
+	// maxIdempotencyKeyLen bounds the memory a single key can pin.
+	maxIdempotencyKeyLen = 255
+)
+
+type idempotencyState int
+
+const (
+	// idempotencyNew means the caller now owns the key and must call finish.
+	idempotencyNew idempotencyState = iota
+	// idempotencyReplay means the key already has a stored response.
+	idempotencyReplay
+	// idempotencyInFlight means another request holding the key hasn't
+	// finished yet.
+	idempotencyInFlight
+)
+
+// idempotencyCache remembers the response to each Idempotency-Key for ttl.
+// It holds at most capacity keys and evicts the least recently used one
+// when full.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	order    *list.List // of *idempotencyEntry, most recently used first
+	entries  map[string]*list.Element
+}
+
+type idempotencyEntry struct {
+	key     string
+	expires time.Time
+	// resp is nil while the publish holding the key is in flight.
+	resp *publishResponse
+}
+
+func newIdempotencyCache(capacity int, ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// claim looks key up. If it is unknown or expired, claim reserves it and
+// returns idempotencyNew; the caller must then call finish.
+func (c *idempotencyCache) claim(key string) (publishResponse, idempotencyState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+		switch {
+		case e.resp == nil:
+			return publishResponse{}, idempotencyInFlight
+		case now.Before(e.expires):
+			c.order.MoveToFront(el)
+			return *e.resp, idempotencyReplay
+		}
+		c.remove(el)
+	}
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&idempotencyEntry{key: key})
+	return publishResponse{}, idempotencyNew
+}
+
+// finish stores resp for a key reserved by claim. A nil resp means the
+// publish failed, so the key is released and the client may retry with it.
+func (c *idempotencyCache) finish(key string, resp *publishResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	if resp == nil {
+		c.remove(el)
+		return
+	}
+	e := el.Value.(*idempotencyEntry)
+	e.resp = resp
+	e.expires = c.now().Add(c.ttl)
+}
+
+func (c *idempotencyCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*idempotencyEntry).key)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func publishWithKey(t *testing.T, svc *EventService, key string, req eventsv1http.PublishEventsRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	svc.HandlePublishEvents(w, httpReq)
+	return w
+}
+
+func TestPublishEvents_IdempotencyKey(t *testing.T) {
+	svc := testService()
+	req := eventsv1http.PublishEventsRequest{Events: makeEvents(2, 1)}
+
+	first := publishWithKey(t, svc, "batch-1", req)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first publish should not be marked as replayed")
+	}
+
+	retry := publishWithKey(t, svc, "batch-1", req)
+	if retry.Code != http.StatusOK {
+		t.Fatalf("expected 200 on retry, got %d", retry.Code)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected the retry to be marked as replayed")
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("expected the original response %s, got %s", first.Body.String(), retry.Body.String())
+	}
+	if n, _ := svc.storage.Len(context.Background()); n != 3 {
+		t.Errorf("expected the batch to be stored once (3 events), got %d", n)
+	}
+	if got := svc.Core().Totals().Received; got != 3 {
+		t.Errorf("expected total_received 3, got %d", got)
+	}
+
+	if w := publishWithKey(t, svc, "batch-2", req); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a new key, got %d", w.Code)
+	}
+	if n, _ := svc.storage.Len(context.Background()); n != 6 {
+		t.Errorf("expected a new key to store the batch again (6 events), got %d", n)
+	}
+}
+
+func TestPublishEvents_IdempotencyKeyFailureReleased(t *testing.T) {
+	svc := testService()
+	httpReq := httptest.NewRequest("POST", "/events", strings.NewReader("not json"))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Idempotency-Key", "k")
+	w := httptest.NewRecorder()
+	svc.HandlePublishEvents(w, httpReq)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	w = publishWithKey(t, svc, "k", eventsv1http.PublishEventsRequest{Events: makeEvents(1, 0)})
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected a fresh 200 after a failed attempt, got %d (replayed %q)", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if n, _ := svc.storage.Len(context.Background()); n != 1 {
+		t.Errorf("expected 1 stored event, got %d", n)
+	}
+}
+
+func TestPublishEvents_IdempotencyKeyTooLong(t *testing.T) {
+	svc := testService()
+	w := publishWithKey(t, svc, strings.Repeat("k", maxIdempotencyKeyLen+1), eventsv1http.PublishEventsRequest{Events: makeEvents(1, 0)})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestPublishEvents_IdempotencyDisabled(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithIdempotency(0, time.Minute))
+	req := eventsv1http.PublishEventsRequest{Events: makeEvents(1, 0)}
+	publishWithKey(t, svc, "k", req)
+	publishWithKey(t, svc, "k", req)
+	if n, _ := svc.storage.Len(context.Background()); n != 2 {
+		t.Errorf("expected both publishes to be stored when disabled, got %d", n)
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newIdempotencyCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, state := c.claim("a"); state != idempotencyNew {
+		t.Fatalf("expected a new key, got %v", state)
+	}
+	if _, state := c.claim("a"); state != idempotencyInFlight {
+		t.Fatalf("expected the key to be in flight, got %v", state)
+	}
+	c.finish("a", &publishResponse{PublishEventsResponse: eventsv1http.PublishEventsResponse{Accepted: 7}})
+	if resp, state := c.claim("a"); state != idempotencyReplay || resp.Accepted != 7 {
+		t.Fatalf("expected a replay of 7, got %v %+v", state, resp)
+	}
+
+	// "b" fills the cache; touching "a" makes "b" the one evicted by "c".
+	c.claim("b")
+	c.finish("b", &publishResponse{PublishEventsResponse: eventsv1http.PublishEventsResponse{Accepted: 1}})
+	c.claim("a")
+	c.claim("c")
+	c.finish("c", &publishResponse{PublishEventsResponse: eventsv1http.PublishEventsResponse{Accepted: 1}})
+	if _, state := c.claim("a"); state != idempotencyReplay {
+		t.Errorf("expected the recently used key to survive, got %v", state)
+	}
+	if _, state := c.claim("b"); state != idempotencyNew {
+		t.Errorf("expected the least recently used key to be evicted, got %v", state)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, state := c.claim("a"); state != idempotencyNew {
+		t.Errorf("expected an expired key to be claimable again, got %v", state)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter records produced messages. When block is non-nil, writes wait
+// until it is closed.
+type fakeWriter struct {
+	mu     sync.Mutex
+	msgs   []kafka.Message
+	block  chan struct{}
+	closed bool
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	w := &fakeWriter{}
+	sink := newKafkaSink(slog.Default(), w, kafkaBufferSize)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithSink(sink))
+
+	evs := []eventsv1http.UsageEvent{
+		{Key: "k1", TenantKey: ptr("tenant-a"), Method: "GET", Path: "/a", Allowed: true, Timestamp: "ts"},
+		{Key: "k2", TenantKey: ptr("tenant-b"), Method: "POST", Path: "/b", Allowed: false, Timestamp: "ts"},
+		{Key: "k3", Method: "GET", Path: "/c", Allowed: true, Timestamp: "ts"},
+	}
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: evs})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.closed {
+		t.Error("expected Close to close the writer")
+	}
+	if len(w.msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(w.msgs))
+	}
+	wantKeys := []string{"tenant-a", "tenant-b", ""}
+	for i, msg := range w.msgs {
+		if string(msg.Key) != wantKeys[i] {
+			t.Errorf("message %d: expected key %q, got %q", i, wantKeys[i], msg.Key)
+		}
+		var ev eventsv1http.UsageEvent
+		if err := json.Unmarshal(msg.Value, &ev); err != nil {
+			t.Fatalf("message %d: invalid JSON: %v", i, err)
+		}
+		if ev.Key != evs[i].Key || ev.Path != evs[i].Path {
+			t.Errorf("message %d: unexpected event %s", i, msg.Value)
+		}
+	}
+}
+
+func TestKafkaSink_NeverBlocks(t *testing.T) {
+	w := &fakeWriter{block: make(chan struct{})}
+	sink := newKafkaSink(slog.Default(), w, 2)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithSink(sink))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 3 {
+			publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(5, 0)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a stalled kafka writer")
+	}
+	if n := len(svc.StoredEvents()); n != 15 {
+		t.Errorf("expected all 15 events stored, got %d", n)
+	}
+
+	close(w.block)
+	sink.Close()
+	if len(w.msgs) >= 15 {
+		t.Errorf("expected events to be dropped while the queue was full, got %d messages", len(w.msgs))
+	}
+}
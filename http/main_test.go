@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvOrDefaultDuration(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultShutdownTimeout},
+		{"30s", 30 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"30", defaultShutdownTimeout},
+		{"soon", defaultShutdownTimeout},
+	} {
+		t.Setenv("SHUTDOWN_TIMEOUT", tc.value)
+		if got := envOrDefaultDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout); got != tc.want {
+			t.Errorf("SHUTDOWN_TIMEOUT=%q: expected %s, got %s", tc.value, tc.want, got)
+		}
+	}
+}
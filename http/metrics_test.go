@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func scrapeMetrics(t *testing.T, svc *EventService) string {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(svc)
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMetrics_Exposition(t *testing.T) {
+	svc := testService()
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(3, 2)})
+
+	body := scrapeMetrics(t, svc)
+	for _, want := range []string{
+		"edgequota_events_received_total 5",
+		"edgequota_events_allowed_total 3",
+		"edgequota_events_denied_total 2",
+		"edgequota_events_allow_ratio 0.6",
+		"edgequota_events_deny_ratio 0.4",
+		"edgequota_events_stored 5",
+		"edgequota_events_publish_duration_seconds_count 1",
+		`edgequota_events_publish_duration_seconds_bucket{le="+Inf"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in exposition:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_SurviveClear(t *testing.T) {
+	svc := testService()
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(3, 2)})
+	svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+
+	body := scrapeMetrics(t, svc)
+	for _, want := range []string{
+		"edgequota_events_received_total 5",
+		"edgequota_events_stored 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in exposition:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	eventsv1 "github.com/edgequota/edgequota-go/gen/grpc/edgequota/events/v1"
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// fromProto converts a protobuf UsageEvent to its HTTP form. The protobuf
+// message has no reason, and empty optional strings become nil.
+func fromProto(ev *eventsv1.UsageEvent) eventsv1http.UsageEvent {
+	return eventsv1http.UsageEvent{
+		Key:        ev.GetKey(),
+		TenantKey:  optionalString(ev.GetTenantKey()),
+		Method:     ev.GetMethod(),
+		Path:       ev.GetPath(),
+		Allowed:    ev.GetAllowed(),
+		Remaining:  ev.GetRemaining(),
+		Limit:      ev.GetLimit(),
+		Timestamp:  ev.GetTimestamp(),
+		StatusCode: ev.GetStatusCode(),
+		RequestId:  optionalString(ev.GetRequestId()),
+	}
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// toProto converts an HTTP UsageEvent to its protobuf form, dropping the
+// reason the protobuf message has no field for.
+func toProto(ev eventsv1http.UsageEvent) *eventsv1.UsageEvent {
+	return &eventsv1.UsageEvent{
+		Key:        ev.Key,
+		TenantKey:  stringValue(ev.TenantKey),
+		Method:     ev.Method,
+		Path:       ev.Path,
+		Allowed:    ev.Allowed,
+		Remaining:  ev.Remaining,
+		Limit:      ev.Limit,
+		Timestamp:  ev.Timestamp,
+		StatusCode: ev.StatusCode,
+		RequestId:  stringValue(ev.RequestId),
+	}
+}
+
+// toProtoBatch wraps events in the PublishEventsRequest message that
+// protobuf list responses use.
+func toProtoBatch(events []eventsv1http.UsageEvent) *eventsv1.PublishEventsRequest {
+	msg := &eventsv1.PublishEventsRequest{Events: make([]*eventsv1.UsageEvent, len(events))}
+	for i, ev := range events {
+		msg.Events[i] = toProto(ev)
+	}
+	return msg
+}
+
+// acceptsProtobuf reports whether the Accept header names
+// application/x-protobuf.
+func acceptsProtobuf(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), mediaTypeProtobuf) {
+			return true
+		}
+	}
+	return false
+}
+
+// publishResponse is the JSON body of a successful publish, or of one that
+// failed after storing part of its batch.
+type publishResponse struct {
+	eventsv1http.PublishEventsResponse
+	// Rejected lists the invalid events left out of the batch.
+	Rejected []rejectedEvent `json:"rejected,omitempty"`
+	// Warning asks the client to slow down; see WithWatermarks.
+	Warning string `json:"warning,omitempty"`
+	// DryRun is set when the batch was only validated, not stored.
+	DryRun bool `json:"dry_run,omitempty"`
+	// PerTenant breaks Accepted down by tenant key. Events without a tenant
+	// key are only counted in Accepted.
+	PerTenant map[string]int64 `json:"per_tenant,omitempty"`
+	// Error is set when the publish failed part-way, with status the
+	// error's; Accepted then counts the events stored before it failed.
+	Error  string `json:"error,omitempty"`
+	status int
+}
+
+// acceptedPerTenant returns the events of a batch per tenant key from its
+// tally, leaving out those without one. It is nil when no event has a
+// tenant key.
+func acceptedPerTenant(t eventcore.Tally) map[string]int64 {
+	var out map[string]int64
+	for tenant, ts := range t.PerTenant {
+		if tenant == "" {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]int64, len(t.PerTenant))
+		}
+		out[tenant] = ts.TotalReceived
+	}
+	return out
+}
+
+// writePublishResponse answers a successful publish as binary protobuf when
+// the client accepts it and as JSON otherwise. Errors, including a
+// publish that failed part-way, are always JSON, and the protobuf message
+// only has room for the accepted count.
+func writePublishResponse(w http.ResponseWriter, r *http.Request, resp publishResponse) {
+	if resp.Error != "" {
+		eventcore.WriteJSON(w, resp.status, resp)
+		return
+	}
+	if acceptsProtobuf(r) {
+		eventcore.WriteProtobuf(w, http.StatusOK, &eventsv1.PublishEventsResponse{Accepted: resp.Accepted})
+		return
+	}
+	eventcore.WriteJSON(w, http.StatusOK, resp)
+}
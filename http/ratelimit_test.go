@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func TestPublishEvents_RateLimit(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithRateLimit(1, 2))
+	now := time.Unix(0, 0)
+	svc.limiter.Now = func() time.Time { return now }
+
+	publishFrom := func(remoteAddr string) int {
+		body, _ := json.Marshal(eventsv1http.PublishEventsRequest{Events: makeEvents(1, 0)})
+		req := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		svc.HandlePublishEvents(w, req)
+		return w.Code
+	}
+
+	for i := range 2 {
+		if code := publishFrom("10.0.0.1:1000"); code != http.StatusOK {
+			t.Fatalf("publish %d: expected 200, got %d", i, code)
+		}
+	}
+	// Another port on the same host shares the bucket.
+	if code := publishFrom("10.0.0.1:2000"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the burst is spent, got %d", code)
+	}
+	if code := publishFrom("10.0.0.2:1000"); code != http.StatusOK {
+		t.Errorf("expected another source to be allowed, got %d", code)
+	}
+
+	now = now.Add(time.Second)
+	if code := publishFrom("10.0.0.1:1000"); code != http.StatusOK {
+		t.Errorf("expected 200 after the bucket refilled, got %d", code)
+	}
+	if got := svc.Core().Totals().Received; got != 4 {
+		t.Errorf("expected limited publishes not to be counted, got total_received %d", got)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+const (
+	defaultReplayBatch = 100
+	maxReplayBatch     = 1000
+	// replayTimeout bounds each batch sent to a replay target.
+	replayTimeout = 10 * time.Second
+	// maxReplayRate caps the rate of a replay, in events per second.
+	maxReplayRate = 1e6
+	// maxReplayInterval caps the wait between the batches of a paced
+	// replay, however low its rate.
+	maxReplayInterval = time.Hour
+)
+
+// replayRequest is the body of POST /events/replay.
+type replayRequest struct {
+	// Target is the URL each batch is POSTed to as a PublishEventsRequest.
+	Target string `json:"target"`
+	// BatchSize is the number of events per request; zero means 100.
+	BatchSize int `json:"batch_size"`
+	// Rate caps the events sent per second; zero sends batches back to back.
+	Rate float64 `json:"rate"`
+}
+
+// replayResponse summarizes a replay. Error is set when the target failed
+// a batch, and Replayed then counts the events sent before it.
+type replayResponse struct {
+	Replayed int    `json:"replayed"`
+	Batches  int    `json:"batches"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HandleReplayEvents re-publishes the stored events matching the list
+// filters to a target, oldest first, in PublishEventsRequest batches, so
+// the service can feed recorded traffic to a downstream consumer under
+// test. The target's host must be in the -replay-allow list, so the
+// endpoint can't be used to reach arbitrary hosts. It answers once every
+// batch has been sent, with 502 and the
+// partial count if the target fails one.
+func (s *EventService) HandleReplayEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := eventcore.ParseFilter(r.URL.Query())
+	if err != nil {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: err.Error()})
+		return
+	}
+	body := r.Body
+	if s.maxBody > 0 {
+		body = http.MaxBytesReader(w, body, s.maxBody)
+	}
+	var req replayRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	u, err := url.Parse(req.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: "target must be an http or https URL"})
+		return
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = defaultReplayBatch
+	}
+	if req.BatchSize < 0 || req.BatchSize > maxReplayBatch {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("batch_size must be between 1 and %d", maxReplayBatch)})
+		return
+	}
+	if !(req.Rate >= 0 && req.Rate <= maxReplayRate) {
+		eventcore.WriteJSON(w, http.StatusBadRequest, eventcore.ErrorResponse{Error: fmt.Sprintf("rate must be between 0 and %g", float64(maxReplayRate))})
+		return
+	}
+	if !replayAllowed(s.replayAllow, u.Host) {
+		eventcore.WriteJSON(w, http.StatusForbidden, eventcore.ErrorResponse{Error: "target is not in -replay-allow"})
+		return
+	}
+
+	events, err := s.Matching(r.Context(), filter)
+	if err != nil {
+		s.logger.Error("failed to read events to replay", "error", err)
+		eventcore.WriteJSON(w, http.StatusInternalServerError, eventcore.ErrorResponse{Error: "failed to read events"})
+		return
+	}
+
+	// A paced replay can outlast the server's WriteTimeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp, err := s.replay(r.Context(), req, events)
+	if err != nil {
+		s.logger.Warn("replay failed", "target", req.Target, "replayed", resp.Replayed, "error", err)
+		resp.Error = err.Error()
+		eventcore.WriteJSON(w, http.StatusBadGateway, resp)
+		return
+	}
+	s.logger.Info("events replayed", "target", req.Target, "count", resp.Replayed, "batches", resp.Batches)
+	eventcore.WriteJSON(w, http.StatusOK, resp)
+}
+
+// replay sends events to req.Target in batches, waiting between batches
+// as needed to keep to req.Rate. It stops at the first failed batch.
+// replayInterval returns the wait between batches of size events that
+// keeps to rate events per second, kept between a nanosecond and
+// maxReplayInterval so that the ticker can always be made.
+func replayInterval(size int, rate float64) time.Duration {
+	secs := float64(size) / rate
+	if secs >= maxReplayInterval.Seconds() {
+		return maxReplayInterval
+	}
+	return max(time.Duration(secs*float64(time.Second)), time.Nanosecond)
+}
+
+func (s *EventService) replay(ctx context.Context, req replayRequest, events []eventsv1http.UsageEvent) (replayResponse, error) {
+	var resp replayResponse
+	var pace eventcore.Ticker
+	if req.Rate > 0 {
+		pace = s.clock.NewTicker(replayInterval(req.BatchSize, req.Rate))
+		defer pace.Stop()
+	}
+	// Redirects aren't followed: they could lead outside -replay-allow.
+	client := &http.Client{
+		Timeout: replayTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for batch := range slices.Chunk(events, req.BatchSize) {
+		if pace != nil && resp.Batches > 0 {
+			select {
+			case <-ctx.Done():
+				return resp, ctx.Err()
+			case <-pace.C():
+			}
+		}
+		if err := postBatch(ctx, client, req.Target, batch); err != nil {
+			return resp, err
+		}
+		resp.Replayed += len(batch)
+		resp.Batches++
+	}
+	return resp, nil
+}
+
+func postBatch(ctx context.Context, client *http.Client, target string, batch []eventsv1http.UsageEvent) error {
+	body, err := json.Marshal(eventsv1http.PublishEventsRequest{Events: batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("target answered " + resp.Status)
+	}
+	return nil
+}
+
+// replayAllowed reports whether a replay may be sent to hostport, which
+// must be one of the hosts in allow, given either as a bare host name or
+// IP, matching any port, or as host:port.
+func replayAllowed(allow []string, hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	for _, a := range allow {
+		if strings.EqualFold(a, hostport) || strings.EqualFold(a, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func replayRequestTo(t *testing.T, svc *EventService, query, body string) (*httptest.ResponseRecorder, replayResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	svc.HandleReplayEvents(w, httptest.NewRequest("POST", "/events/replay?"+query, strings.NewReader(body)))
+	var resp replayResponse
+	if w.Code == http.StatusOK || w.Code == http.StatusBadGateway {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w, resp
+}
+
+// replayService returns a service allowed to replay to loopback targets.
+func replayService(opts ...Option) *EventService {
+	opts = append([]Option{WithReplayAllow([]string{"127.0.0.1"})}, opts...)
+	return NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), opts...)
+}
+
+func TestReplayEvents(t *testing.T) {
+	target := testService()
+	srv := httptest.NewServer(http.HandlerFunc(target.HandlePublishEvents))
+	defer srv.Close()
+
+	svc := replayService()
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(5, 3)})
+
+	w, resp := replayRequestTo(t, svc, "allowed=true", `{"target": "`+srv.URL+`", "batch_size": 2}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp != (replayResponse{Replayed: 5, Batches: 3}) {
+		t.Errorf("expected 5 events in 3 batches, got %+v", resp)
+	}
+	if got, want := target.StoredEvents(), makeEvents(5, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the allowed events replayed in order, got %+v", got)
+	}
+
+	w, resp = replayRequestTo(t, svc, "", `{"target": "`+srv.URL+`"}`)
+	if w.Code != http.StatusOK || resp != (replayResponse{Replayed: 8, Batches: 1}) {
+		t.Errorf("expected all 8 events in one default-sized batch, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestReplayEvents_TargetFails(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	svc := replayService()
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(5, 0)})
+	w, resp := replayRequestTo(t, svc, "", `{"target": "`+srv.URL+`", "batch_size": 2}`)
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", w.Code)
+	}
+	if resp.Replayed != 2 || resp.Batches != 1 || !strings.Contains(resp.Error, "503") {
+		t.Errorf("expected the first batch counted and the target's status reported, got %+v", resp)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected the replay to stop at the failed batch, target called %d times", n)
+	}
+}
+
+func TestReplayEvents_Rate(t *testing.T) {
+	got := make(chan int, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req eventsv1http.PublishEventsRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got <- len(req.Events)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock(time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+	svc := replayService(WithClock(clock))
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(5, 0)})
+
+	done := make(chan replayResponse)
+	go func() {
+		_, resp := replayRequestTo(t, svc, "", `{"target": "`+srv.URL+`", "batch_size": 2, "rate": 2}`)
+		done <- resp
+	}()
+
+	// Two events at two per second is a batch a second.
+	clock.waitForTickers(1)
+	<-got
+	select {
+	case <-got:
+		t.Fatal("second batch sent before the clock advanced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	<-got
+	clock.Advance(time.Second)
+	if n := <-got; n != 1 {
+		t.Errorf("expected the last batch to hold the remaining event, got %d", n)
+	}
+	if resp := <-done; resp != (replayResponse{Replayed: 5, Batches: 3}) {
+		t.Errorf("expected 5 events in 3 batches, got %+v", resp)
+	}
+}
+
+func TestReplayInterval(t *testing.T) {
+	for _, tc := range []struct {
+		size int
+		rate float64
+		want time.Duration
+	}{
+		{2, 2, time.Second},
+		{1, maxReplayRate, time.Microsecond},
+		{1, 1e300, time.Nanosecond},
+		{1000, 1e-20, maxReplayInterval},
+	} {
+		if got := replayInterval(tc.size, tc.rate); got != tc.want {
+			t.Errorf("%d events at %g/s: expected %v, got %v", tc.size, tc.rate, tc.want, got)
+		}
+	}
+}
+
+func TestReplayEvents_NoRedirects(t *testing.T) {
+	var redirected atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { redirected.Add(1) }))
+	defer other.Close()
+	srv := httptest.NewServer(http.RedirectHandler(other.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	svc := replayService()
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(1, 0)})
+	w, resp := replayRequestTo(t, svc, "", `{"target": "`+srv.URL+`"}`)
+	if w.Code != http.StatusBadGateway || !strings.Contains(resp.Error, "307") {
+		t.Errorf("expected the redirect to fail the replay, got %d %+v", w.Code, resp)
+	}
+	if n := redirected.Load(); n != 0 {
+		t.Errorf("expected the redirect not to be followed, got %d requests", n)
+	}
+}
+
+func TestReplayEvents_BadRequest(t *testing.T) {
+	svc := testService()
+	for _, tc := range []struct{ query, body string }{
+		{"", `not json`},
+		{"", `{}`},
+		{"", `{"target": "ftp://example.com/events"}`},
+		{"", `{"target": "/events"}`},
+		{"", `{"target": "http://example.com/events", "batch_size": -1}`},
+		{"", `{"target": "http://example.com/events", "batch_size": 1001}`},
+		{"", `{"target": "http://example.com/events", "rate": -1}`},
+		{"", `{"target": "http://example.com/events", "rate": 1e300}`},
+		{"allowed=maybe", `{"target": "http://example.com/events"}`},
+	} {
+		if w, _ := replayRequestTo(t, svc, tc.query, tc.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d", tc.query, tc.body, w.Code)
+		}
+	}
+}
+
+func TestReplayEvents_NotAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		allow []string
+		body  string
+	}{
+		{nil, `{"target": "http://127.0.0.1:9000/events"}`},
+		{[]string{"127.0.0.1"}, `{"target": "http://10.0.0.1/events"}`},
+		{[]string{"127.0.0.1:8000"}, `{"target": "http://127.0.0.1:9000/events"}`},
+		{[]string{"internal.example:50054"}, `{"target": "http://internal.example:8080/events"}`},
+	} {
+		svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithReplayAllow(tc.allow))
+		if w, _ := replayRequestTo(t, svc, "", tc.body); w.Code != http.StatusForbidden {
+			t.Errorf("%v %s: expected 403, got %d", tc.allow, tc.body, w.Code)
+		}
+	}
+}
+
+func TestReplayAllowed(t *testing.T) {
+	allow := []string{"127.0.0.1", "Events.Example:8080"}
+	for hostport, want := range map[string]bool{
+		"127.0.0.1":           true,
+		"127.0.0.1:9000":      true,
+		"events.example:8080": true,
+		"events.example:8081": false,
+		"events.example":      false,
+		"10.0.0.1:8080":       false,
+	} {
+		if got := replayAllowed(allow, hostport); got != want {
+			t.Errorf("replayAllowed(%q) = %v, want %v", hostport, got, want)
+		}
+	}
+}
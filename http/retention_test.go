@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func TestRunRetention(t *testing.T) {
+	svc := testService()
+	now := time.Now()
+	svc.storage.Append(context.Background(), []eventsv1http.UsageEvent{
+		{Key: "old", Method: "GET", Path: "/", Timestamp: now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{Key: "recent", Method: "GET", Path: "/", Timestamp: now.Format(time.RFC3339)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.RunRetention(ctx, time.Hour, 10*time.Millisecond)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(svc.StoredEvents()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected expired event to be pruned, %d events stored", len(svc.StoredEvents()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := svc.StoredEvents()[0].Key; got != "recent" {
+		t.Errorf("expected the recent event to survive, got %q", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunRetention did not stop after cancellation")
+	}
+}
+
+func TestRunRetention_FakeClock(t *testing.T) {
+	start := time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithClock(clock))
+	svc.storage.Append(context.Background(), []eventsv1http.UsageEvent{
+		{Key: "old", Method: "GET", Path: "/", Timestamp: start.Add(-30 * time.Minute).Format(time.RFC3339)},
+		{Key: "recent", Method: "GET", Path: "/", Timestamp: start.Format(time.RFC3339)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.RunRetention(ctx, time.Hour, time.Minute)
+	}()
+	clock.waitForTickers(1)
+
+	// Advance returns once the previous tick has been handled, so each
+	// check below follows an extra tick that can't prune anything more.
+	clock.Advance(time.Minute)
+	clock.Advance(time.Minute)
+	if n := len(svc.StoredEvents()); n != 2 {
+		t.Errorf("expected nothing pruned before the hour is up, %d events stored", n)
+	}
+
+	clock.Advance(28*time.Minute + time.Second)
+	clock.Advance(time.Minute)
+	stored := svc.StoredEvents()
+	if len(stored) != 1 || stored[0].Key != "recent" {
+		t.Errorf("expected only the recent event left once the old one expired, got %+v", stored)
+	}
+
+	cancel()
+	<-done
+}
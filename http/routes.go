@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/edgequota/external-events-template/internal/eventcore"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newMux registers the HTTP routes. Routes are method-qualified, so the mux
+// answers any other method on a known path with 405 Method Not Allowed and
+// an Allow header listing the registered ones. Every route but /metrics is
+// traced.
+func newMux(svc *EventService) *http.ServeMux {
+	mux := http.NewServeMux()
+	route := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, svc.traced(pattern, h))
+	}
+	route("POST /events", svc.HandlePublishEvents)
+	route("POST /events/backfill", svc.HandleBackfillEvents)
+	route("POST /events/replay", svc.HandleReplayEvents)
+	route("GET /events", svc.HandleListEvents)
+	route("GET /events/count", svc.HandleCountEvents)
+	route("GET /events/export.csv", svc.HandleExportCSV)
+	route("GET /events/stats", svc.HandleStats)
+	route("GET /events/stats/by-tenant", svc.HandleTenantStats)
+	route("GET /events/stats/rate", svc.HandleRateStats)
+	route("GET /events/stats/remaining", svc.HandleRemainingStats)
+	route("POST /events/stats/reset", svc.HandleResetStats)
+	route("GET /events/tenants", svc.HandleListTenants)
+	route("GET /events/top/keys", svc.HandleTopKeys)
+	route("GET /events/top/paths", svc.HandleTopPaths)
+	route("GET /events/stream", svc.HandleStreamEvents)
+	route("GET /events/{request_id}", svc.HandleGetEvent)
+	route("DELETE /events", svc.HandleClearEvents)
+	route("GET /{$}", handleDiscovery)
+	route("GET /version", eventcore.HandleVersion)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+const (
+	// s3BufferSize is the number of events the S3 sink queues before it
+	// starts dropping them.
+	s3BufferSize = 10000
+	// defaultS3FlushEvents and defaultS3FlushInterval are how many events
+	// the S3 sink collects, and how long it waits, before writing an object.
+	defaultS3FlushEvents   = 1000
+	defaultS3FlushInterval = time.Minute
+)
+
+// newS3Client returns an S3 client configured the usual AWS SDK way:
+// credentials and region from the environment, shared config files or the
+// instance role.
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg), nil
+}
+
+// objectUploader is the subset of *s3.Client used by s3Sink.
+type objectUploader interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// s3Sink archives accepted events to S3 as gzipped NDJSON objects, one
+// per flush. It flushes once flushEvents events are buffered, every
+// flushInterval if any are, and on Close. Events are queued and uploaded by
+// a background goroutine; when the queue is full they are dropped rather
+// than blocking publishers, and a failed upload drops its object's events.
+type s3Sink struct {
+	logger        *slog.Logger
+	client        objectUploader
+	bucket        string
+	prefix        string
+	flushEvents   int
+	flushInterval time.Duration
+	clock         eventcore.Clock
+	queue         chan eventsv1http.UsageEvent
+	done          chan struct{}
+	// ctx is passed to the uploads, so that Close can abandon one that
+	// outlasts its deadline by calling cancel.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// objects numbers the uploaded objects, so keys written within the
+	// same millisecond stay distinct.
+	objects int
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newS3Sink(logger *slog.Logger, client objectUploader, bucket, prefix string, flushEvents int, flushInterval time.Duration, clock eventcore.Clock) *s3Sink {
+	u := &s3Sink{
+		logger:        logger,
+		client:        client,
+		bucket:        bucket,
+		prefix:        prefix,
+		flushEvents:   max(flushEvents, 1),
+		flushInterval: flushInterval,
+		clock:         clock,
+		queue:         make(chan eventsv1http.UsageEvent, s3BufferSize),
+		done:          make(chan struct{}),
+	}
+	u.ctx, u.cancel = context.WithCancel(context.Background())
+	if u.flushInterval <= 0 {
+		u.flushInterval = defaultS3FlushInterval
+	}
+	ticker := clock.NewTicker(u.flushInterval)
+	go u.run(ticker)
+	return u
+}
+
+// Publish queues batch for upload without blocking.
+func (u *s3Sink) Publish(batch []eventsv1http.UsageEvent) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.closed {
+		return
+	}
+	for i, ev := range batch {
+		select {
+		case u.queue <- ev:
+		default:
+			u.logger.Warn("s3 sink queue full, dropping events", "dropped", len(batch)-i)
+			return
+		}
+	}
+}
+
+func (u *s3Sink) run(ticker eventcore.Ticker) {
+	defer close(u.done)
+	defer ticker.Stop()
+	var buf []eventsv1http.UsageEvent
+	add := func(ev eventsv1http.UsageEvent) {
+		buf = append(buf, ev)
+		if len(buf) >= u.flushEvents {
+			u.flush(buf)
+			buf = buf[:0]
+		}
+	}
+	for {
+		select {
+		case ev, ok := <-u.queue:
+			if !ok {
+				u.flush(buf)
+				return
+			}
+			add(ev)
+		case <-ticker.C():
+			// Take what is already queued too, so events published before
+			// the tick go out with it.
+		drain:
+			for {
+				select {
+				case ev, ok := <-u.queue:
+					if !ok {
+						break drain
+					}
+					add(ev)
+				default:
+					break drain
+				}
+			}
+			u.flush(buf)
+			buf = buf[:0]
+		}
+	}
+}
+
+// flush uploads batch as one object. It does nothing for an empty batch.
+func (u *s3Sink) flush(batch []eventsv1http.UsageEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	enc := json.NewEncoder(zw)
+	for i := range batch {
+		if err := enc.Encode(&batch[i]); err != nil {
+			u.logger.Error("failed to encode events for s3", "count", len(batch), "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		u.logger.Error("failed to compress events for s3", "count", len(batch), "error", err)
+		return
+	}
+
+	key := u.objectKey(u.clock.Now())
+	_, err := u.client.PutObject(u.ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(u.bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(body.Bytes()),
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	if err != nil {
+		u.logger.Error("failed to upload events to s3", "bucket", u.bucket, "key", key, "count", len(batch), "error", err)
+		return
+	}
+	u.logger.Debug("uploaded events to s3", "bucket", u.bucket, "key", key, "count", len(batch))
+}
+
+// objectKey names the next object written at t:
+// <prefix>/YYYY/MM/DD/<YYYYMMDD>T<HHMMSS.mmm>Z-<n>.ndjson.gz, in UTC, where n
+// counts the objects this sink has written. Keys sort by the time they
+// were written.
+func (u *s3Sink) objectKey(t time.Time) string {
+	u.objects++
+	t = t.UTC()
+	name := fmt.Sprintf("%s-%06d.ndjson.gz", t.Format("20060102T150405.000Z"), u.objects)
+	return path.Join(u.prefix, t.Format("2006/01/02"), name)
+}
+
+// Close stops accepting events, uploads what is buffered, and waits for
+// the upload to finish. If ctx is done first, the upload is abandoned, its
+// events are dropped and ctx's error is returned.
+func (u *s3Sink) Close(ctx context.Context) error {
+	defer u.cancel()
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.mu.Unlock()
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		u.cancel()
+		<-u.done
+		return ctx.Err()
+	}
+}
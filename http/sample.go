@@ -0,0 +1,56 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// sampleID is what decides whether an event is sampled: its request ID,
+// or its key and timestamp when it has none.
+func sampleID(ev eventsv1http.UsageEvent) string {
+	if id := stringValue(ev.RequestId); id != "" {
+		return id
+	}
+	return ev.Key + "\x00" + ev.Timestamp
+}
+
+// sampled reports whether an event with this sampleID is among the
+// fraction rate of events that are stored. It hashes the ID rather than
+// drawing a random number, so a replayed event gets the same answer.
+func sampled(id string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return float64(mix64(h.Sum64())) < rate*math.MaxUint64
+}
+
+// mix64 is MurmurHash3's 64-bit finalizer. FNV's high bits barely change
+// between IDs that differ only in their last characters, such as
+// sequential ones, and the comparison in sampled depends on them.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// sample returns the events of batch to store. It is batch itself when
+// every event is stored.
+func (s *EventService) sample(batch []eventsv1http.UsageEvent) []eventsv1http.UsageEvent {
+	if s.sampleRate >= 1 {
+		return batch
+	}
+	kept := make([]eventsv1http.UsageEvent, 0, int(float64(len(batch))*s.sampleRate)+1)
+	for _, ev := range batch {
+		if sampled(sampleID(ev), s.sampleRate) {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func TestPublishEvents_SampleRate(t *testing.T) {
+	const total, rate = 4000, 0.25
+	svc := NewEventService(slog.Default(), newMemoryStore(0), WithSampleRate(rate))
+	batch := make([]eventsv1http.UsageEvent, total)
+	for i := range batch {
+		batch[i] = eventsv1http.UsageEvent{Key: "k", Method: "GET", Path: "/", Allowed: i%2 == 0, RequestId: ptr(fmt.Sprintf("req-%d", i))}
+	}
+	if w := publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: batch}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if got := svc.Core().Totals().Received; got != total {
+		t.Errorf("expected all %d events counted, got %d", total, got)
+	}
+	stored := svc.StoredEvents()
+	if frac := float64(len(stored)) / total; math.Abs(frac-rate) > 0.05 {
+		t.Errorf("expected about %.0f%% of events stored, got %d of %d", rate*100, len(stored), total)
+	}
+	for _, ev := range stored {
+		if !sampled(sampleID(ev), rate) {
+			t.Fatalf("stored event %s is outside the sample", *ev.RequestId)
+		}
+	}
+
+	// The decision depends only on the event, so republishing stores the
+	// same events again.
+	again := NewEventService(slog.Default(), newMemoryStore(0), WithSampleRate(rate))
+	publishRequest(t, again, eventsv1http.PublishEventsRequest{Events: batch})
+	if n := len(again.StoredEvents()); n != len(stored) {
+		t.Errorf("expected the same %d events sampled on a second run, got %d", len(stored), n)
+	}
+}
+
+func TestSampled_Bounds(t *testing.T) {
+	for _, id := range []string{"", "a", "req-1"} {
+		if sampled(id, 0) {
+			t.Errorf("%q sampled at rate 0", id)
+		}
+		if !sampled(id, 1) {
+			t.Errorf("%q not sampled at rate 1", id)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// maxSchemaErrors caps how many violations a rejected publish reports.
+const maxSchemaErrors = 100
+
+// schemaErrorResponse is the body of a publish rejected for not matching
+// the event schema.
+type schemaErrorResponse struct {
+	Error  string   `json:"error"`
+	Errors []string `json:"errors"`
+}
+
+// eventSchema validates published events against a JSON Schema document.
+// Each event is checked in the JSON form the HTTP API uses, with absent
+// optional fields left out.
+type eventSchema struct {
+	schema *jsonschema.Schema
+}
+
+// loadEventSchema compiles the JSON Schema document at path.
+func loadEventSchema(path string) (*eventSchema, error) {
+	schema, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("compile event schema: %w", err)
+	}
+	return &eventSchema{schema: schema}, nil
+}
+
+// validate returns a description of each schema violation in batch, at
+// most maxSchemaErrors of them, or nil if every event is valid.
+func (s *eventSchema) validate(batch []eventsv1http.UsageEvent) []string {
+	var problems []string
+	for i := range batch {
+		err := s.schema.Validate(eventDocument(&batch[i]))
+		var verr *jsonschema.ValidationError
+		if !errors.As(err, &verr) {
+			continue
+		}
+		out := verr.BasicOutput()
+		units := out.Errors
+		if len(units) == 0 {
+			units = []jsonschema.OutputUnit{*out}
+		}
+		for _, u := range units {
+			if u.Error == nil {
+				continue
+			}
+			if len(problems) == maxSchemaErrors {
+				return problems
+			}
+			problems = append(problems, fmt.Sprintf("event %d%s: %s", i, u.InstanceLocation, u.Error))
+		}
+	}
+	return problems
+}
+
+// eventDocument returns ev as the generic JSON value the validator takes.
+func eventDocument(ev *eventsv1http.UsageEvent) map[string]any {
+	doc := map[string]any{
+		"key":         ev.Key,
+		"method":      ev.Method,
+		"path":        ev.Path,
+		"allowed":     ev.Allowed,
+		"remaining":   ev.Remaining,
+		"limit":       ev.Limit,
+		"timestamp":   ev.Timestamp,
+		"status_code": ev.StatusCode,
+	}
+	if ev.TenantKey != nil {
+		doc["tenant_key"] = *ev.TenantKey
+	}
+	if ev.RequestId != nil {
+		doc["request_id"] = *ev.RequestId
+	}
+	if ev.Reason != nil {
+		doc["reason"] = *ev.Reason
+	}
+	return doc
+}
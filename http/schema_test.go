@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// sampleSchema requires a tenant key and an upper-case method, and limits
+// paths to /api.
+const sampleSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["key", "tenant_key", "method", "path", "timestamp"],
+	"properties": {
+		"tenant_key": {"type": "string", "minLength": 1},
+		"method": {"enum": ["GET", "POST", "PUT", "PATCH", "DELETE"]},
+		"path": {"type": "string", "pattern": "^/api/"},
+		"status_code": {"type": "integer", "minimum": 100, "maximum": 599}
+	}
+}`
+
+func writeSchema(t *testing.T) *eventSchema {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "event.schema.json")
+	if err := os.WriteFile(path, []byte(sampleSchema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := loadEventSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestPublishEvents_Schema(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithSchema(writeSchema(t)))
+	valid := eventsv1http.UsageEvent{Key: "k1", TenantKey: ptr("tenant-a"), Method: "GET", Path: "/api/v1", Allowed: true, Timestamp: "2026-02-16T21:00:00Z", StatusCode: 200}
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{valid}})
+
+	invalid := eventsv1http.UsageEvent{Key: "k2", Method: "get", Path: "/health", Timestamp: "2026-02-16T21:00:01Z", StatusCode: 200}
+	body, _ := json.Marshal(eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{valid, invalid}})
+	req := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	svc.HandlePublishEvents(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp schemaErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 3 {
+		t.Errorf("expected the missing tenant key, method and path reported, got %q", resp.Errors)
+	}
+	for _, e := range resp.Errors {
+		if !strings.HasPrefix(e, "event 1") {
+			t.Errorf("expected only the second event reported, got %q", e)
+		}
+	}
+	if n := len(svc.StoredEvents()); n != 1 {
+		t.Errorf("expected the rejected batch left unstored, got %d events", n)
+	}
+}
+
+func TestLoadEventSchema_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`{"type": 12}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadEventSchema(path); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+	if _, err := loadEventSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
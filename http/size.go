@@ -0,0 +1,29 @@
+package main
+
+import (
+	"unsafe"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+var (
+	// eventOverhead is the fixed size of a stored event, not counting the
+	// string data it refers to.
+	eventOverhead = int64(unsafe.Sizeof(eventsv1http.UsageEvent{}))
+	// stringHeaderSize is what each set optional string adds besides its
+	// data: the string header its pointer refers to.
+	stringHeaderSize = int64(unsafe.Sizeof(""))
+)
+
+// approxEventBytes estimates the memory ev occupies: the struct itself plus
+// the contents of its strings. Allocator rounding and any sharing of string
+// data between events are ignored.
+func approxEventBytes(ev *eventsv1http.UsageEvent) int64 {
+	n := eventOverhead + int64(len(ev.Key)+len(ev.Method)+len(ev.Path)+len(ev.Timestamp))
+	for _, p := range []*string{ev.TenantKey, ev.RequestId, ev.Reason} {
+		if p != nil {
+			n += stringHeaderSize + int64(len(*p))
+		}
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// decisionEvents returns events keyed by keys, allowed unless the key
+// starts with "d".
+func decisionEvents(keys ...string) []eventsv1http.UsageEvent {
+	events := keyedEvents(keys...)
+	for i := range events {
+		events[i].Allowed = events[i].Key[0] != 'd'
+	}
+	return events
+}
+
+func TestListEvents_SplitStore(t *testing.T) {
+	st, err := openStore("memory", 10, 5, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewEventService(slog.Default(), st)
+	ctx := context.Background()
+	st.Append(ctx, decisionEvents("d1", "d2"))
+	for range 100 {
+		st.Append(ctx, decisionEvents("a"))
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+		var events []eventsv1http.UsageEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		keys := make([]string, len(events))
+		for i, ev := range events {
+			keys[i] = ev.Key
+		}
+		return keys
+	}
+
+	if got := list("allowed=false"); !reflect.DeepEqual(got, []string{"d2", "d1"}) {
+		t.Errorf("expected the denials to survive the flood, got %v", got)
+	}
+	if got := list("allowed=true&limit=3"); !reflect.DeepEqual(got, []string{"a", "a", "a"}) {
+		t.Errorf("unexpected allowed list: %v", got)
+	}
+	got := list("limit=20")
+	if len(got) != 12 || !reflect.DeepEqual(got[10:], []string{"d2", "d1"}) {
+		t.Errorf("expected the merged list to end with the older denials, got %v", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// newMemoryStore returns an in-memory store keeping at most capacity
+// events.
+func newMemoryStore(capacity int) *eventcore.MemoryStore[eventsv1http.UsageEvent] {
+	return eventcore.NewMemoryStore(eventFields, capacity, 0)
+}
+
+// storeBackends opens each kind of store -store can select; the service
+// must work the same over all of them.
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store { return openTestStore(t, "memory", 0, 0) },
+	"split":  func(t *testing.T) Store { return openTestStore(t, "memory", defaultMaxEvents, 0) },
+	"tenant": func(t *testing.T) Store { return openTestStore(t, "memory", 0, defaultMaxEvents) },
+	"redis":  func(t *testing.T) Store { return openTestStore(t, "redis://"+miniredis.RunT(t).Addr(), 0, 0) },
+	"sqlite": func(t *testing.T) Store {
+		return openTestStore(t, "sqlite:"+filepath.Join(t.TempDir(), "events.db"), 0, 0)
+	},
+}
+
+func openTestStore(t *testing.T, spec string, maxDenied, maxPerTenant int) Store {
+	t.Helper()
+	st, err := openStore(spec, defaultMaxEvents, maxDenied, maxPerTenant, defaultInitialCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func forEachStore(t *testing.T, fn func(t *testing.T, st Store)) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			t.Cleanup(func() { st.Close() })
+			fn(t, st)
+		})
+	}
+}
+
+func keyedEvents(keys ...string) []eventsv1http.UsageEvent {
+	out := make([]eventsv1http.UsageEvent, len(keys))
+	for i, k := range keys {
+		out[i] = eventsv1http.UsageEvent{Key: k, Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"}
+	}
+	return out
+}
+
+func scanKeys(t *testing.T, st Store, before int64) ([]string, []int64) {
+	t.Helper()
+	var keys []string
+	var seqs []int64
+	err := st.Scan(context.Background(), before, func(seq int64, ev eventsv1http.UsageEvent) bool {
+		keys = append(keys, ev.Key)
+		seqs = append(seqs, seq)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, seqs
+}
+
+func TestStore_ListThroughService(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store) {
+		svc := NewEventService(slog.Default(), st)
+		publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{
+			{Key: "k1", TenantKey: ptr("tenant-a"), Method: "GET", Path: "/a", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"},
+			{Key: "k2", TenantKey: ptr("tenant-b"), Method: "POST", Path: "/b", Allowed: false, Timestamp: "2026-02-16T21:00:01Z"},
+			{Key: "k3", TenantKey: ptr("tenant-a"), Method: "POST", Path: "/a/x", Allowed: false, Timestamp: "2026-02-16T21:00:02Z"},
+			{Key: "k4", TenantKey: ptr("tenant-a"), Method: "GET", Path: "/a", Allowed: true, Timestamp: "2026-02-16T21:00:03Z"},
+		}})
+
+		list := func(query string) eventsPage {
+			t.Helper()
+			w := httptest.NewRecorder()
+			svc.HandleListEvents(w, httptest.NewRequest("GET", "/events?"+query, nil))
+			var page eventsPage
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			return page
+		}
+
+		page := list("cursor=&tenant_key=tenant-a&path_prefix=/a")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k4", "k3", "k1"}) {
+			t.Errorf("unexpected filtered list: %v", got)
+		}
+
+		page = list("cursor=&limit=2")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k4", "k3"}) {
+			t.Fatalf("unexpected first page: %v", got)
+		}
+		page = list("limit=2&cursor=" + page.NextCursor)
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k2", "k1"}) {
+			t.Errorf("unexpected second page: %v", got)
+		}
+
+		page = list("cursor=&allowed=false&offset=1")
+		if got := pageKeys(page); !reflect.DeepEqual(got, []string{"k2"}) {
+			t.Errorf("unexpected offset page: %v", got)
+		}
+	})
+}
+
+// bufferedStore holds appended events until Flush, like a store that
+// batches writes to a remote backend.
+type bufferedStore struct {
+	*eventcore.MemoryStore[eventsv1http.UsageEvent]
+	pending []eventsv1http.UsageEvent
+}
+
+func (b *bufferedStore) Append(_ context.Context, batch []eventsv1http.UsageEvent) error {
+	b.pending = append(b.pending, batch...)
+	return nil
+}
+
+func (b *bufferedStore) Flush(ctx context.Context) error {
+	if err := b.MemoryStore.Append(ctx, b.pending); err != nil {
+		return err
+	}
+	b.pending = nil
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	st := &bufferedStore{MemoryStore: newMemoryStore(defaultMaxEvents)}
+	svc := NewEventService(slog.Default(), st)
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(2, 1)})
+
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Fatalf("expected events to be buffered before Flush, got %d stored", n)
+	}
+	if err := svc.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(svc.StoredEvents()); n != 3 {
+		t.Errorf("expected 3 events after Flush, got %d", n)
+	}
+}
+
+func TestFlush_SQLiteDurable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	st, err := openStore("sqlite:"+path, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewEventService(slog.Default(), st)
+	publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(2, 1)})
+	if err := svc.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	reopened, err := openStore("sqlite:"+path, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if n, err := reopened.Len(context.Background()); err != nil || n != 3 {
+		t.Errorf("expected 3 events after reopening, got %d (err=%v)", n, err)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	for _, spec := range []string{"", "memory", "sqlite:" + filepath.Join(t.TempDir(), "events.db")} {
+		st, err := openStore(spec, defaultMaxEvents, 0, 0, defaultInitialCapacity)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", spec, err)
+			continue
+		}
+		st.Close()
+	}
+	if _, err := openStore("postgres://localhost", defaultMaxEvents, 0, 0, defaultInitialCapacity); err == nil {
+		t.Error("expected error for unknown store")
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 100, 0, defaultInitialCapacity); err != nil {
+		t.Errorf("memory with a denied cap: unexpected error: %v", err)
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "split.db"), defaultMaxEvents, 100, 0, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a denied cap on sqlite")
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 0, 100, defaultInitialCapacity); err != nil {
+		t.Errorf("memory with a per-tenant cap: unexpected error: %v", err)
+	}
+	if _, err := openStore("memory", defaultMaxEvents, 100, 100, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap combined with a denied cap")
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "tenant.db"), defaultMaxEvents, 0, 100, defaultInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap on sqlite")
+	}
+}
+
+func pageKeys(page eventsPage) []string {
+	keys := make([]string, len(page.Events))
+	for i, ev := range page.Events {
+		keys[i] = ev.Key
+	}
+	return keys
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+func TestNormalizeTimestamp(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		ok             bool
+	}{
+		{"rfc3339", "2026-02-16T21:00:00Z", "2026-02-16T21:00:00Z", true},
+		{"rfc3339 offset", "2026-02-16T23:00:00+02:00", "2026-02-16T23:00:00+02:00", true},
+		{"rfc3339 fraction", "2026-02-16T21:00:00.5Z", "2026-02-16T21:00:00.5Z", true},
+		{"no zone", "2026-02-16T21:00:00", "2026-02-16T21:00:00Z", true},
+		{"no zone fraction", "2026-02-16T21:00:00.250", "2026-02-16T21:00:00.25Z", true},
+		{"space separated", "2026-02-16 21:00:00", "2026-02-16T21:00:00Z", true},
+		{"epoch seconds", "1771275600", "2026-02-16T21:00:00Z", true},
+		{"epoch millis", "1771275600123", "2026-02-16T21:00:00.123Z", true},
+		{"garbage", "yesterday", "yesterday", false},
+		{"signed epoch", "-1771275600", "-1771275600", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeTimestamp(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: normalizeTimestamp(%q) = %q, %v; want %q, %v", tt.name, tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPublishEvents_NormalizesTimestamps(t *testing.T) {
+	publish := func(svc *EventService) {
+		t.Helper()
+		publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: []eventsv1http.UsageEvent{
+			{Key: "millis", Timestamp: "1771275600000"},
+			{Key: "zoneless", Timestamp: "2026-02-16T21:00:05"},
+			{Key: "bad", Timestamp: "yesterday"},
+		}})
+	}
+
+	svc := testService()
+	publish(svc)
+	got := map[string]string{}
+	for _, ev := range svc.StoredEvents() {
+		got[ev.Key] = ev.Timestamp
+	}
+	want := map[string]string{"millis": "2026-02-16T21:00:00Z", "zoneless": "2026-02-16T21:00:05Z", "bad": "yesterday"}
+	for k, ts := range want {
+		if got[k] != ts {
+			t.Errorf("%s: expected timestamp %q, got %q", k, ts, got[k])
+		}
+	}
+	w := httptest.NewRecorder()
+	svc.HandleCountEvents(w, httptest.NewRequest("GET", "/events/count?since=2026-02-16T21:00:00Z&until=2026-02-16T21:00:10Z", nil))
+	if body := w.Body.String(); body != "{\"count\":2}\n" {
+		t.Errorf("expected both normalized events in the time range, got %s", body)
+	}
+
+	raw := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithTimestampNormalization(false))
+	publish(raw)
+	if ts := raw.StoredEvents()[0].Timestamp; ts != "1771275600000" {
+		t.Errorf("expected the timestamp kept as sent with normalization off, got %q", ts)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// loadTLSConfig returns a server TLS config serving the given certificate
+// and key files, or nil when neither is set.
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS certificate and key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS key pair: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
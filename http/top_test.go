@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// trafficEvents returns events from several keys to several paths, with
+// traffic and denials unevenly spread.
+func trafficEvents() []eventsv1http.UsageEvent {
+	var events []eventsv1http.UsageEvent
+	add := func(key, tenant, path string, allowed, denied int) {
+		for range allowed {
+			events = append(events, eventsv1http.UsageEvent{Key: key, TenantKey: ptr(tenant), Method: "GET", Path: path, Allowed: true, Timestamp: "2026-02-16T21:00:00Z"})
+		}
+		for range denied {
+			events = append(events, eventsv1http.UsageEvent{Key: key, TenantKey: ptr(tenant), Method: "GET", Path: path, Timestamp: "2026-02-16T21:00:10Z"})
+		}
+	}
+	add("10.0.0.1", "tenant-a", "/api/orders", 5, 1)
+	add("10.0.0.2", "tenant-a", "/api/users", 1, 2)
+	add("10.0.0.3", "tenant-b", "/api/orders", 0, 4)
+	add("10.0.0.4", "tenant-b", "/api/login", 2, 1)
+	add("10.0.0.1", "tenant-a", "/api/login", 1, 0)
+	return events
+}
+
+func TestTopPaths(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), trafficEvents())
+
+	for _, tc := range []struct {
+		query string
+		want  []eventcore.TopPath
+	}{
+		{"", []eventcore.TopPath{
+			{Path: "/api/orders", EventCounts: eventcore.EventCounts{Count: 10, Allowed: 5, Denied: 5}},
+			{Path: "/api/login", EventCounts: eventcore.EventCounts{Count: 4, Allowed: 3, Denied: 1}},
+			{Path: "/api/users", EventCounts: eventcore.EventCounts{Count: 3, Allowed: 1, Denied: 2}},
+		}},
+		{"n=1", []eventcore.TopPath{{Path: "/api/orders", EventCounts: eventcore.EventCounts{Count: 10, Allowed: 5, Denied: 5}}}},
+		{"tenant_key=tenant-b", []eventcore.TopPath{
+			{Path: "/api/orders", EventCounts: eventcore.EventCounts{Count: 4, Denied: 4}},
+			{Path: "/api/login", EventCounts: eventcore.EventCounts{Count: 3, Allowed: 2, Denied: 1}},
+		}},
+		{"since=2026-02-16T21:00:05Z", []eventcore.TopPath{
+			{Path: "/api/orders", EventCounts: eventcore.EventCounts{Count: 5, Denied: 5}},
+			{Path: "/api/users", EventCounts: eventcore.EventCounts{Count: 2, Denied: 2}},
+			{Path: "/api/login", EventCounts: eventcore.EventCounts{Count: 1, Denied: 1}},
+		}},
+		{"tenant_key=nobody", []eventcore.TopPath{}},
+	} {
+		w := httptest.NewRecorder()
+		svc.HandleTopPaths(w, httptest.NewRequest("GET", "/events/top/paths?"+tc.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tc.query, w.Code, w.Body.String())
+		}
+		var got []eventcore.TopPath
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: expected %+v, got %+v", tc.query, tc.want, got)
+		}
+	}
+
+	for _, query := range []string{"n=0", "n=-1", "n=ten", "allowed=maybe", "normalize=sometimes"} {
+		w := httptest.NewRecorder()
+		svc.HandleTopPaths(w, httptest.NewRequest("GET", "/events/top/paths?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestTopPaths_Normalize(t *testing.T) {
+	svc := testService()
+	var events []eventsv1http.UsageEvent
+	for _, path := range []string{
+		"/users/1", "/users/2", "/users/3",
+		"/orders/550e8400-e29b-41d4-a716-446655440000",
+		"/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"/users/1/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"/health",
+	} {
+		events = append(events, eventsv1http.UsageEvent{Key: "k", Method: "GET", Path: path, Allowed: true, Timestamp: "2026-02-16T21:00:00Z"})
+	}
+	svc.storage.Append(context.Background(), events)
+
+	w := httptest.NewRecorder()
+	svc.HandleTopPaths(w, httptest.NewRequest("GET", "/events/top/paths?normalize=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got []eventcore.TopPath
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []eventcore.TopPath{
+		{Path: "/users/{id}", EventCounts: eventcore.EventCounts{Count: 3, Allowed: 3}},
+		{Path: "/orders/{uuid}", EventCounts: eventcore.EventCounts{Count: 2, Allowed: 2}},
+		{Path: "/health", EventCounts: eventcore.EventCounts{Count: 1, Allowed: 1}},
+		{Path: "/users/{id}/orders/{uuid}", EventCounts: eventcore.EventCounts{Count: 1, Allowed: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	svc.HandleTopPaths(w, httptest.NewRequest("GET", "/events/top/paths?normalize=false&n=1", nil))
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != "/health" {
+		t.Errorf("expected raw paths without normalize, got %+v", got)
+	}
+	for _, ev := range svc.StoredEvents() {
+		if strings.Contains(ev.Path, "{") {
+			t.Errorf("expected stored paths left raw, got %q", ev.Path)
+		}
+	}
+}
+
+func TestTopKeys(t *testing.T) {
+	svc := testService()
+	svc.storage.Append(context.Background(), trafficEvents())
+
+	for _, tc := range []struct {
+		query string
+		want  []eventcore.TopKey
+	}{
+		// 10.0.0.2 and 10.0.0.4 tie on traffic; 10.0.0.2 has more denials.
+		{"", []eventcore.TopKey{
+			{Key: "10.0.0.1", EventCounts: eventcore.EventCounts{Count: 7, Allowed: 6, Denied: 1}},
+			{Key: "10.0.0.3", EventCounts: eventcore.EventCounts{Count: 4, Denied: 4}},
+			{Key: "10.0.0.2", EventCounts: eventcore.EventCounts{Count: 3, Allowed: 1, Denied: 2}},
+			{Key: "10.0.0.4", EventCounts: eventcore.EventCounts{Count: 3, Allowed: 2, Denied: 1}},
+		}},
+		{"n=2&allowed=false", []eventcore.TopKey{
+			{Key: "10.0.0.3", EventCounts: eventcore.EventCounts{Count: 4, Denied: 4}},
+			{Key: "10.0.0.2", EventCounts: eventcore.EventCounts{Count: 2, Denied: 2}},
+		}},
+		{"tenant_key=tenant-a&path_prefix=/api/login", []eventcore.TopKey{
+			{Key: "10.0.0.1", EventCounts: eventcore.EventCounts{Count: 1, Allowed: 1}},
+		}},
+	} {
+		w := httptest.NewRecorder()
+		svc.HandleTopKeys(w, httptest.NewRequest("GET", "/events/top/keys?"+tc.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tc.query, w.Code, w.Body.String())
+		}
+		var got []eventcore.TopKey
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: expected %+v, got %+v", tc.query, tc.want, got)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleTopKeys(w, httptest.NewRequest("GET", "/events/top/keys?n=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid n, got %d", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceParent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func spanAttr(span *tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_Publish(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	svc := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithTracerProvider(tp))
+	mux := newMux(svc)
+
+	body, _ := json.Marshal(eventsv1http.PublishEventsRequest{Events: makeEvents(2, 1)})
+	req := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", testTraceParent)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(spans, "POST /events")
+	if server == nil {
+		t.Fatalf("expected a POST /events span, got %v", spans)
+	}
+	if got := server.SpanContext.TraceID().String(); got != testTraceID {
+		t.Errorf("expected the span to continue trace %s, got %s", testTraceID, got)
+	}
+	if got := spanAttr(server, "events.denied").AsInt64(); got != 1 {
+		t.Errorf("expected events.denied 1, got %d", got)
+	}
+
+	store := findSpan(spans, "Store.Append")
+	if store == nil {
+		t.Fatal("expected a Store.Append span")
+	}
+	if store.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("expected Store.Append to be a child of the request span")
+	}
+	if got := spanAttr(store, "events.count").AsInt64(); got != 3 {
+		t.Errorf("expected events.count 3, got %d", got)
+	}
+}
+
+func TestTracing_Query(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	mux := newMux(NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithTracerProvider(tp)))
+
+	for _, path := range []string{"/events", "/events/stats"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if findSpan(exporter.GetSpans(), "GET "+path) == nil {
+			t.Errorf("expected a span for GET %s", path)
+		}
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	if findSpan(exporter.GetSpans(), "GET /metrics") != nil {
+		t.Error("expected /metrics not to be traced")
+	}
+}
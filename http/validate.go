@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+)
+
+// defaultMaxFieldLength is the default cap, in bytes, on each string field
+// of a published event: room for long URL paths, far below what would
+// strain memory.
+const defaultMaxFieldLength = 2048
+
+// rejectedEvent reports an event left out of a partially accepted batch.
+type rejectedEvent struct {
+	// Index is the event's position in the published batch.
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// rejectedResponse is the body of a strict publish refused because some of
+// its events are invalid.
+type rejectedResponse struct {
+	Error    string          `json:"error"`
+	Rejected []rejectedEvent `json:"rejected"`
+}
+
+// validateEvent returns why ev can't be stored, or "" if it can. Only the
+// key is required; the numeric fields must be in range when set, and with
+// a positive maxFieldLen no string field may be longer, in bytes.
+func validateEvent(ev *eventsv1http.UsageEvent, maxFieldLen int) string {
+	switch {
+	case ev.Key == "":
+		return "key is required"
+	case ev.Limit < 0:
+		return fmt.Sprintf("limit %d is negative", ev.Limit)
+	case ev.Remaining < 0:
+		return fmt.Sprintf("remaining %d is negative", ev.Remaining)
+	case ev.StatusCode != 0 && (ev.StatusCode < 100 || ev.StatusCode > 599):
+		return fmt.Sprintf("status_code %d is not an HTTP status", ev.StatusCode)
+	}
+	if maxFieldLen > 0 {
+		for _, f := range stringFields(ev) {
+			if len(*f.value) > maxFieldLen {
+				return fmt.Sprintf("%s is %d bytes, over the maximum of %d", f.name, len(*f.value), maxFieldLen)
+			}
+		}
+	}
+	return ""
+}
+
+// eventString is one of an event's string fields.
+type eventString struct {
+	name  string
+	value *string
+}
+
+// stringFields returns the fields of ev the field length limit applies to:
+// key, method, path, and tenant_key, request_id and reason when set.
+func stringFields(ev *eventsv1http.UsageEvent) []eventString {
+	fields := []eventString{{"key", &ev.Key}, {"method", &ev.Method}, {"path", &ev.Path}}
+	for _, f := range []eventString{{"tenant_key", ev.TenantKey}, {"request_id", ev.RequestId}, {"reason", ev.Reason}} {
+		if f.value != nil {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// truncateString shortens v to at most n bytes without splitting a UTF-8
+// sequence.
+func truncateString(v string, n int) string {
+	if len(v) <= n {
+		return v
+	}
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n]
+}
+
+// truncateLongFields shortens the string fields of batch's events to
+// s.maxFieldLen in place when the service truncates rather than rejects
+// them.
+func (s *EventService) truncateLongFields(batch []eventsv1http.UsageEvent) {
+	if !s.truncateFields || s.maxFieldLen <= 0 {
+		return
+	}
+	truncated := 0
+	for i := range batch {
+		for _, f := range stringFields(&batch[i]) {
+			if len(*f.value) > s.maxFieldLen {
+				*f.value = truncateString(*f.value, s.maxFieldLen)
+				truncated++
+			}
+		}
+	}
+	if truncated > 0 {
+		s.logger.Debug("truncated long event fields", "count", truncated, "max_field_length", s.maxFieldLen)
+	}
+}
+
+// partitionValid splits batch into the events that pass validateEvent with
+// maxFieldLen, in their original order, and the rejections for the rest.
+// When nothing is rejected valid is batch itself.
+func partitionValid(batch []eventsv1http.UsageEvent, maxFieldLen int) (valid []eventsv1http.UsageEvent, rejected []rejectedEvent) {
+	for i := range batch {
+		if reason := validateEvent(&batch[i], maxFieldLen); reason != "" {
+			rejected = append(rejected, rejectedEvent{Index: i, Reason: reason})
+		}
+	}
+	if len(rejected) == 0 {
+		return batch, nil
+	}
+	valid = make([]eventsv1http.UsageEvent, 0, len(batch)-len(rejected))
+	next := 0
+	for i := range batch {
+		if next < len(rejected) && rejected[next].Index == i {
+			next++
+			continue
+		}
+		valid = append(valid, batch[i])
+	}
+	return valid, rejected
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+// mixedBatch returns five events of which the second and fourth are
+// invalid.
+func mixedBatch() []eventsv1http.UsageEvent {
+	return []eventsv1http.UsageEvent{
+		{Key: "a", Method: "GET", Path: "/", Allowed: true, StatusCode: 200},
+		{Method: "GET", Path: "/", Allowed: true},
+		{Key: "b", Method: "GET", Path: "/", Allowed: false, StatusCode: 429},
+		{Key: "c", Method: "GET", Path: "/", Limit: -1},
+		{Key: "d", Method: "GET", Path: "/", Allowed: true},
+	}
+}
+
+func TestPublishEvents_PartialAcceptance(t *testing.T) {
+	svc := testService()
+	w := publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: mixedBatch()})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp publishResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Accepted != 3 {
+		t.Errorf("expected 3 accepted, got %d", resp.Accepted)
+	}
+	want := []rejectedEvent{{Index: 1, Reason: "key is required"}, {Index: 3, Reason: "limit -1 is negative"}}
+	if !reflect.DeepEqual(resp.Rejected, want) {
+		t.Errorf("expected rejections %+v, got %+v", want, resp.Rejected)
+	}
+
+	var keys []string
+	for _, ev := range svc.StoredEvents() {
+		keys = append(keys, ev.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "d"}) {
+		t.Errorf("expected only the valid events stored, got %v", keys)
+	}
+	if got := svc.Core().Totals().Received; got != 3 {
+		t.Errorf("expected rejected events left out of the stats, got %d received", got)
+	}
+}
+
+func TestPublishEvents_MaxFieldLength(t *testing.T) {
+	long := func(n int) string { return strings.Repeat("x", n) }
+	batch := func() []eventsv1http.UsageEvent {
+		return []eventsv1http.UsageEvent{
+			{Key: long(8), Method: "GET", Path: "/" + long(7), Allowed: true, TenantKey: ptr(long(8))},
+			{Key: "k", Method: "GET", Path: "/" + long(8), Allowed: true},
+			{Key: "k", Method: "GET", Path: "/", Allowed: false, Reason: ptr(long(9))},
+		}
+	}
+
+	reject := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxFieldLength(8, false))
+	w := publishRequest(t, reject, eventsv1http.PublishEventsRequest{Events: batch()})
+	var resp publishResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []rejectedEvent{
+		{Index: 1, Reason: "path is 9 bytes, over the maximum of 8"},
+		{Index: 2, Reason: "reason is 9 bytes, over the maximum of 8"},
+	}
+	if resp.Accepted != 1 || !reflect.DeepEqual(resp.Rejected, want) {
+		t.Errorf("expected fields at the limit accepted and %+v rejected, got %+v", want, resp)
+	}
+
+	truncate := NewEventService(slog.Default(), newMemoryStore(defaultMaxEvents), WithMaxFieldLength(8, true))
+	w = publishRequest(t, truncate, eventsv1http.PublishEventsRequest{Events: batch()})
+	resp = publishResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Accepted != 3 || len(resp.Rejected) != 0 {
+		t.Fatalf("expected every event accepted when truncating, got %+v (err=%v)", resp, err)
+	}
+	stored := truncate.StoredEvents()
+	if got := stored[0].Key + " " + stored[1].Path + " " + stringValue(stored[2].Reason); got != long(8)+" /"+long(7)+" "+long(8) {
+		t.Errorf("expected long fields cut to 8 bytes, got %q", got)
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"aé", 2, "a"},
+		{"aé", 3, "aé"},
+		{"日本", 4, "日"},
+	} {
+		if got := truncateString(tc.in, tc.n); got != tc.want {
+			t.Errorf("truncateString(%q, %d): expected %q, got %q", tc.in, tc.n, tc.want, got)
+		}
+	}
+}
+
+func TestPublishEvents_Strict(t *testing.T) {
+	publish := func(svc *EventService, query string, batch []eventsv1http.UsageEvent) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(eventsv1http.PublishEventsRequest{Events: batch})
+		req := httptest.NewRequest("POST", "/events"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		svc.HandlePublishEvents(w, req)
+		return w
+	}
+
+	svc := testService()
+	w := publish(svc, "?strict=true", mixedBatch())
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp rejectedResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || len(resp.Rejected) != 2 {
+		t.Errorf("expected both rejections reported, got %+v (err=%v)", resp, err)
+	}
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Errorf("expected nothing stored from a strict batch with invalid events, got %d", n)
+	}
+
+	if w := publish(svc, "?strict=true", makeEvents(2, 1)); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a strict batch of valid events, got %d", w.Code)
+	}
+	if w := publish(svc, "?strict=maybe", makeEvents(1, 0)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid strict value, got %d", w.Code)
+	}
+}
+
+func TestPublishEvents_DryRun(t *testing.T) {
+	publish := func(svc *EventService, query, key string, batch []eventsv1http.UsageEvent) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(eventsv1http.PublishEventsRequest{Events: batch})
+		req := httptest.NewRequest("POST", "/events"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		svc.HandlePublishEvents(w, req)
+		return w
+	}
+
+	svc := testService()
+	w := publish(svc, "?dry_run=true", "batch-1", mixedBatch())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp publishResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Accepted != 3 || len(resp.Rejected) != 2 || !resp.DryRun {
+		t.Errorf("expected a dry run reporting 3 acceptable and 2 rejected events, got %+v", resp)
+	}
+
+	if n := len(svc.StoredEvents()); n != 0 {
+		t.Errorf("expected nothing stored, got %d events", n)
+	}
+	if received, lifetime := svc.Core().Totals().Received, svc.Core().Lifetime().Received; received != 0 || lifetime != 0 {
+		t.Errorf("expected no events counted, got %d received and %d lifetime", received, lifetime)
+	}
+	if tenants := svc.Core().TenantStats(); len(tenants.Tenants) != 0 || tenants.NoTenant != (eventcore.TenantStats{}) {
+		t.Errorf("expected no tenant counters, got %+v", tenants)
+	}
+	if reasons := svc.Core().DenyReasons(); len(reasons) != 0 {
+		t.Errorf("expected no deny reasons counted, got %v", reasons)
+	}
+	stats := httptest.NewRecorder()
+	svc.HandleStats(stats, httptest.NewRequest("GET", "/events/stats", nil))
+	var got EventStats
+	if err := json.NewDecoder(stats.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.LastEventAt != "" || got.PublishLatency.Count != 0 {
+		t.Error("expected a dry run to leave the last event time and publish latency alone")
+	}
+
+	// The dry run didn't claim the key, so the real publish with it goes
+	// through.
+	w = publish(svc, "", "batch-1", mixedBatch())
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" || len(svc.StoredEvents()) != 3 {
+		t.Errorf("expected the real publish stored after a dry run with its key, got %d (%d stored)", w.Code, len(svc.StoredEvents()))
+	}
+
+	if w := publish(svc, "?dry_run=true&strict=true", "", mixedBatch()); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a strict dry run with invalid events refused with 400, got %d", w.Code)
+	}
+	if w := publish(svc, "?dry_run=maybe", "", makeEvents(1, 0)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid dry_run value, got %d", w.Code)
+	}
+}
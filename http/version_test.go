@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	w := httptest.NewRecorder()
+	newMux(testService()).ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var got map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"version": "dev", "commit": "dev", "go_version": runtime.Version()}
+	for field, v := range want {
+		if got[field] != v {
+			t.Errorf("expected %s %q, got %q", field, v, got[field])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected exactly the fields %v, got %v", want, got)
+	}
+}
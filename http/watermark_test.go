@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	eventsv1http "github.com/edgequota/edgequota-go/gen/http/events/v1"
+	"github.com/edgequota/external-events-template/internal/eventcore"
+)
+
+func TestPublishEvents_Watermarks(t *testing.T) {
+	svc := NewEventService(slog.Default(), newMemoryStore(10), WithWatermarks(10, 0.5, 0.8))
+	publish := func(n int) (*httptest.ResponseRecorder, publishResponse) {
+		t.Helper()
+		w := publishRequest(t, svc, eventsv1http.PublishEventsRequest{Events: makeEvents(n, 0)})
+		var resp publishResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, resp
+	}
+	fillLevel := func() float64 {
+		t.Helper()
+		w := httptest.NewRecorder()
+		svc.HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+		var stats EventStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil || stats.FillLevel == nil {
+			t.Fatalf("expected a fill level in the stats, got %s (err=%v)", w.Body.String(), err)
+		}
+		return *stats.FillLevel
+	}
+
+	w, resp := publish(5)
+	if w.Code != http.StatusOK || resp.Warning != "" || w.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected a plain success below the soft watermark, got %d %+v", w.Code, resp)
+	}
+	if got := fillLevel(); got != 0.5 {
+		t.Errorf("expected fill level 0.5, got %v", got)
+	}
+
+	// At the soft watermark publishes still succeed, with a warning.
+	w, resp = publish(3)
+	if w.Code != http.StatusOK || resp.Accepted != 3 {
+		t.Fatalf("expected 3 accepted above the soft watermark, got %d %+v", w.Code, resp)
+	}
+	if resp.Warning == "" || w.Header().Get("Retry-After") != eventcore.WatermarkRetryAfter {
+		t.Errorf("expected a warning and Retry-After above the soft watermark, got %+v, %q", resp, w.Header().Get("Retry-After"))
+	}
+
+	// At the hard watermark they are refused and nothing is stored.
+	w, _ = publish(1)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != eventcore.WatermarkRetryAfter {
+		t.Fatalf("expected 503 with Retry-After at the hard watermark, got %d", w.Code)
+	}
+	if got := fillLevel(); got != 0.8 {
+		t.Errorf("expected the refused batch left unstored, got fill level %v", got)
+	}
+
+	svc.HandleClearEvents(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/events", nil))
+	if w, _ = publish(1); w.Code != http.StatusOK {
+		t.Errorf("expected publishes accepted again once the store drains, got %d", w.Code)
+	}
+}
+
+func TestWatermarks_Disabled(t *testing.T) {
+	for _, opt := range []Option{WithWatermarks(0, 0.5, 0.8), WithWatermarks(10, 0, 0)} {
+		svc := NewEventService(slog.Default(), newMemoryStore(10), opt)
+		if svc.cfg.Watermarks != nil {
+			t.Errorf("expected watermarks off, got %+v", svc.cfg.Watermarks)
+		}
+	}
+	w := httptest.NewRecorder()
+	testService().HandleStats(w, httptest.NewRequest("GET", "/events/stats", nil))
+	var stats map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stats["fill_level"]; ok {
+		t.Error("expected no fill_level without watermarks")
+	}
+}
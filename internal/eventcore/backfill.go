@@ -0,0 +1,64 @@
+package eventcore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backfiller is implemented by stores that can insert events by timestamp
+// rather than at the newest end, for importing historical events.
+type Backfiller[E any] interface {
+	// Backfill merges batch, which must be sorted by timestamp, into the
+	// stored events. Events are renumbered, so outstanding list cursors
+	// restart from the newest event.
+	Backfill(ctx context.Context, batch []E) error
+}
+
+// ErrBackfillUnsupported is returned by Backfill for stores that don't
+// implement Backfiller.
+var ErrBackfillUnsupported = errors.New("store does not support backfill")
+
+// Backfill merges batch into st, writing out anything a store from
+// NewAsyncStore still has queued first so the merge sees every accepted
+// event.
+func Backfill[E any](ctx context.Context, st Store[E], batch []E) error {
+	if a, ok := st.(*asyncStore[E]); ok {
+		if err := a.Flush(ctx); err != nil {
+			return err
+		}
+		st = a.Store
+	}
+	b, ok := st.(Backfiller[E])
+	if !ok {
+		return ErrBackfillUnsupported
+	}
+	return b.Backfill(ctx, batch)
+}
+
+// mergeByTimestamp merges two oldest-first event lists. batch must be
+// sorted by timestamp; existing is taken in its stored order, and events
+// in it whose timestamp can't be parsed stay ahead of anything merged in
+// after them. Events with equal timestamps keep existing ones first.
+func mergeByTimestamp[E any](view func(E) Event, existing, batch []E) []E {
+	out := make([]E, 0, len(existing)+len(batch))
+	for len(existing) > 0 && len(batch) > 0 {
+		if timestampBefore(view(batch[0]).Timestamp, view(existing[0]).Timestamp) {
+			out = append(out, batch[0])
+			batch = batch[1:]
+		} else {
+			out = append(out, existing[0])
+			existing = existing[1:]
+		}
+	}
+	out = append(out, existing...)
+	return append(out, batch...)
+}
+
+// timestampBefore reports whether RFC 3339 timestamp a is before b. It is
+// false when either can't be parsed.
+func timestampBefore(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	return errA == nil && errB == nil && ta.Before(tb)
+}
@@ -0,0 +1,87 @@
+package eventcore
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped, or starts missing events if it is lossy.
+const subscriberBuffer = 256
+
+// Subscription receives the newly accepted events matching its filter on
+// Events until it is unsubscribed, or dropped for falling behind, which
+// closes the channel.
+type Subscription[E any] struct {
+	Events chan E
+	filter Filter
+	// lossy subscribers skip events while their buffer is full instead of
+	// being dropped.
+	lossy bool
+}
+
+// broadcaster fans newly accepted events out to live subscribers. Publishing
+// never blocks: a subscriber whose buffer is full is dropped and its channel
+// closed, unless it is lossy.
+type broadcaster[E any] struct {
+	view func(E) Event
+	mu   sync.Mutex
+	subs map[*Subscription[E]]struct{}
+}
+
+func (b *broadcaster[E]) subscribe(filter Filter, lossy bool) *Subscription[E] {
+	sub := &Subscription[E]{
+		Events: make(chan E, subscriberBuffer),
+		filter: filter,
+		lossy:  lossy,
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[*Subscription[E]]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *broadcaster[E]) unsubscribe(sub *Subscription[E]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.Events)
+	}
+}
+
+func (b *broadcaster[E]) publish(batch []E) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !b.deliver(sub, batch) {
+			delete(b.subs, sub)
+			close(sub.Events)
+		}
+	}
+}
+
+// len returns the number of subscribers.
+func (b *broadcaster[E]) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// deliver queues the events of batch that match the subscriber's filter
+// without blocking, and reports whether the subscriber should be kept.
+func (b *broadcaster[E]) deliver(sub *Subscription[E], batch []E) bool {
+	for _, ev := range batch {
+		if !sub.filter.Match(b.view(ev)) {
+			continue
+		}
+		select {
+		case sub.Events <- ev:
+		default:
+			if !sub.lossy {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package eventcore
+
+import "testing"
+
+func TestBroadcaster_DropsSlowSubscriber(t *testing.T) {
+	b := broadcaster[Event]{view: storeFields.View}
+	slow := b.subscribe(Filter{}, false)
+	fast := b.subscribe(Filter{}, false)
+
+	batch := make([]Event, subscriberBuffer)
+	for i := range batch {
+		batch[i] = Event{Key: "k", Allowed: true}
+	}
+	b.publish(batch)
+	for range batch {
+		<-fast.Events
+	}
+
+	// slow never drained, so the next event overflows its buffer.
+	b.publish(batch[:1])
+
+	for range slow.Events {
+	}
+	if _, ok := b.subs[slow]; ok {
+		t.Error("expected slow subscriber to be dropped")
+	}
+	if _, ok := b.subs[fast]; !ok {
+		t.Error("expected fast subscriber to remain")
+	}
+	if len(fast.Events) != 1 {
+		t.Errorf("expected 1 queued event for fast subscriber, got %d", len(fast.Events))
+	}
+
+	b.unsubscribe(fast)
+	b.unsubscribe(slow)
+}
+
+func TestBroadcaster_LossySubscriberSkipsEvents(t *testing.T) {
+	b := broadcaster[Event]{view: storeFields.View}
+	sub := b.subscribe(Filter{}, true)
+
+	batch := make([]Event, subscriberBuffer+10)
+	for i := range batch {
+		batch[i] = Event{Key: "k", Allowed: true}
+	}
+	b.publish(batch)
+
+	if _, ok := b.subs[sub]; !ok {
+		t.Fatal("expected lossy subscriber to remain subscribed")
+	}
+	if len(sub.Events) != subscriberBuffer {
+		t.Errorf("expected a full buffer of %d events, got %d", subscriberBuffer, len(sub.Events))
+	}
+
+	b.unsubscribe(sub)
+}
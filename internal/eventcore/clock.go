@@ -0,0 +1,32 @@
+package eventcore
+
+import "time"
+
+// Clock tells the time for the services, so that tests can control it.
+// Everything they do by the clock — uptime, publish latency, rate windows,
+// retention, idempotency expiry and publish rate limiting — goes through
+// it.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a Ticker that ticks every d, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the part of *time.Ticker that Clock users need.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock backed by the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+
+func (t realTicker) Stop() { t.t.Stop() }
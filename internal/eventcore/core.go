@@ -0,0 +1,241 @@
+// Package eventcore is the storage and query logic shared by the HTTP and
+// gRPC event templates: the Store interface and its backends, the counters
+// behind the stats endpoints, and the Service serving the query API. Each
+// template keeps its own publish path and protocol handlers.
+//
+// The templates' event types differ, the HTTP one being a plain struct and
+// the gRPC one a protobuf message, so the package is generic over the
+// event type and reads the fields it needs through Fields.
+package eventcore
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Core keeps a Store and the counters reported by the stats endpoints in
+// step. The counters run from the last clear or reset, except for the
+// lifetime totals behind /metrics, which are never reset and are always
+// kept in memory. A store that is a Counter keeps the others itself.
+type Core[E any] struct {
+	storage Store[E]
+	fields  Fields[E]
+	// counter is storage when it keeps the counters, in which case the
+	// in-memory ones below stay at zero.
+	counter Counter[E]
+
+	// mu keeps the stored events and the counters below in step.
+	// Publishes hold it for reading while they store a batch and count it;
+	// clearing, filtered deletes, counter resets and snapshots hold it for
+	// writing, so none of them can fall between a publish's store and its
+	// count.
+	mu          sync.RWMutex
+	received    atomic.Int64
+	allowed     atomic.Int64
+	denied      atomic.Int64
+	byTenant    tenantCounters
+	denyReasons reasonCounters
+	// droppedBase is the store's drop count at the last counter reset;
+	// Snapshot reports drops relative to it.
+	droppedBase int64
+
+	lifetimeReceived atomic.Int64
+	lifetimeAllowed  atomic.Int64
+	lifetimeDenied   atomic.Int64
+}
+
+// New returns a Core for storage, counting events by fields.
+func New[E any](storage Store[E], fields Fields[E]) *Core[E] {
+	return &Core[E]{storage: storage, fields: fields, counter: counterOf(storage)}
+}
+
+// Append stores batch, for the store function passed to Record. A store
+// that keeps the counters adds t to them in the same write.
+func (c *Core[E]) Append(ctx context.Context, batch []E, t Tally) error {
+	if c.counter != nil {
+		return c.counter.AppendCounted(ctx, batch, t)
+	}
+	return c.storage.Append(ctx, batch)
+}
+
+// Record calls store to store a batch and, if it succeeds, counts t. The
+// stores of concurrent publishes may overlap, but never a Snapshot,
+// Clear, Delete or ResetCounters.
+func (c *Core[E]) Record(t Tally, store func() error) error {
+	c.mu.RLock()
+	err := store()
+	if err == nil && c.counter == nil {
+		c.received.Add(t.Received)
+		c.allowed.Add(t.Allowed)
+		c.denied.Add(t.Denied)
+		c.byTenant.add(t.PerTenant)
+		c.denyReasons.add(t.Reasons)
+	}
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	c.lifetimeReceived.Add(t.Received)
+	c.lifetimeAllowed.Add(t.Allowed)
+	c.lifetimeDenied.Add(t.Denied)
+	return nil
+}
+
+// Snapshot is the counters and the number of stored events at one moment.
+type Snapshot struct {
+	Totals
+	Stored int
+	// Dropped counts the events the store discarded to stay within its cap
+	// since the counters were last reset.
+	Dropped int64
+	Reasons map[string]int64
+}
+
+// Snapshot counts the stored events and reads the counters with publishes
+// held off, so it never shows a batch stored but not yet counted.
+func (c *Core[E]) Snapshot(ctx context.Context) (Snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, err := c.storage.Len(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	counts, err := c.Counts(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{
+		Totals:  counts.Totals,
+		Stored:  n,
+		Dropped: DroppedEvents(c.storage) - c.droppedBase,
+		Reasons: counts.Reasons,
+	}, nil
+}
+
+// Counts returns the counters since the last clear or reset, read from the
+// store if it keeps them.
+func (c *Core[E]) Counts(ctx context.Context) (Tally, error) {
+	if c.counter != nil {
+		return c.counter.Counts(ctx)
+	}
+	return Tally{
+		Totals:    Totals{Received: c.received.Load(), Allowed: c.allowed.Load(), Denied: c.denied.Load()},
+		PerTenant: c.byTenant.snapshot(),
+		Reasons:   c.denyReasons.snapshot(),
+	}, nil
+}
+
+// Totals returns the counters since the last clear or reset. Counters kept
+// by the store read as zero if it can't be reached.
+func (c *Core[E]) Totals() Totals {
+	t, _ := c.Counts(context.Background())
+	return t.Totals
+}
+
+// Lifetime returns the counters since the service started.
+func (c *Core[E]) Lifetime() Totals {
+	return Totals{Received: c.lifetimeReceived.Load(), Allowed: c.lifetimeAllowed.Load(), Denied: c.lifetimeDenied.Load()}
+}
+
+// TenantStats returns the counters broken down by tenant key, like
+// Totals.
+func (c *Core[E]) TenantStats() TenantStatsResponse {
+	t, _ := c.Counts(context.Background())
+	return t.TenantStats()
+}
+
+// DenyReasons returns the denied events counted by reason, like Totals.
+func (c *Core[E]) DenyReasons() map[string]int64 {
+	t, _ := c.Counts(context.Background())
+	return t.Reasons
+}
+
+// Clear deletes every stored event and resets the counters.
+func (c *Core[E]) Clear(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.storage.Clear(ctx); err != nil {
+		return err
+	}
+	c.resetCounters()
+	return nil
+}
+
+// ResetCounters zeroes the counters without touching the stored events.
+// The lifetime totals are left alone.
+func (c *Core[E]) ResetCounters(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counter != nil {
+		if err := c.counter.ResetCounts(ctx); err != nil {
+			return err
+		}
+	}
+	c.resetCounters()
+	return nil
+}
+
+// resetCounters zeroes the in-memory counters, for callers holding mu. A
+// store that keeps the counters resets its drop count itself.
+func (c *Core[E]) resetCounters() {
+	c.received.Store(0)
+	c.allowed.Store(0)
+	c.denied.Store(0)
+	c.byTenant.reset()
+	c.denyReasons.reset()
+	if c.counter == nil {
+		c.droppedBase = DroppedEvents(c.storage)
+	}
+}
+
+// Delete deletes the stored events match returns true for, takes them off
+// the counters, and returns how many it deleted.
+func (c *Core[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	var t Tally
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	del := c.storage.Delete
+	if c.counter != nil {
+		del = c.counter.DeleteCounted
+	}
+	n, err := del(ctx, func(ev E) bool {
+		if !match(ev) {
+			return false
+		}
+		c.fields.add(&t, ev)
+		return true
+	})
+	if err != nil || c.counter != nil {
+		return n, err
+	}
+	decrease(&c.received, t.Received)
+	decrease(&c.allowed, t.Allowed)
+	decrease(&c.denied, t.Denied)
+	c.byTenant.subtract(t.PerTenant)
+	c.denyReasons.subtract(t.Reasons)
+	return n, nil
+}
+
+// Events returns every stored event, oldest first.
+func (c *Core[E]) Events(ctx context.Context) ([]E, error) {
+	var out []E
+	err := c.storage.Scan(ctx, 0, func(_ int64, ev E) bool {
+		out = append(out, ev)
+		return true
+	})
+	slices.Reverse(out)
+	return out, err
+}
+
+// Flush persists any events the store has accepted but not yet written,
+// returning early if ctx is done. It only has work to do for stores that
+// implement Flusher.
+func (c *Core[E]) Flush(ctx context.Context) error {
+	f, ok := c.storage.(Flusher)
+	if !ok {
+		return nil
+	}
+	return f.Flush(ctx)
+}
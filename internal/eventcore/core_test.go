@@ -0,0 +1,212 @@
+package eventcore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testEvent stands in for the templates' event types.
+type testEvent struct {
+	tenant  string
+	allowed bool
+	reason  string
+}
+
+var testFields = Fields[testEvent]{
+	View: func(ev testEvent) Event {
+		return Event{TenantKey: &ev.tenant, Allowed: ev.allowed, Reason: &ev.reason}
+	},
+	Reasons: true,
+}
+
+// sliceStore is a Store kept in a slice, newest last, that can be made to
+// fail and reports a fixed drop count.
+type sliceStore struct {
+	events  []testEvent
+	fail    error
+	dropped int64
+}
+
+func (s *sliceStore) Append(_ context.Context, batch []testEvent) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.events = append(s.events, batch...)
+	return nil
+}
+
+func (s *sliceStore) Scan(_ context.Context, _ int64, fn func(int64, testEvent) bool) error {
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if !fn(int64(i+1), s.events[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *sliceStore) Len(context.Context) (int, error) { return len(s.events), s.fail }
+
+func (s *sliceStore) Prune(context.Context, time.Time) (int, error) { return 0, nil }
+
+func (s *sliceStore) Delete(_ context.Context, match func(testEvent) bool) (int, error) {
+	if s.fail != nil {
+		return 0, s.fail
+	}
+	n := len(s.events)
+	s.events = slices.DeleteFunc(s.events, match)
+	return n - len(s.events), nil
+}
+
+func (s *sliceStore) Clear(context.Context) error {
+	s.events = nil
+	return s.fail
+}
+
+func (s *sliceStore) Close() error { return nil }
+
+func (s *sliceStore) Dropped() int64 { return s.dropped }
+
+// wrappedStore wraps a store the way a write queue does.
+type wrappedStore struct{ Store[testEvent] }
+
+func (w wrappedStore) Unwrap() Store[testEvent] { return w.Store }
+
+func record(t *testing.T, c *Core[testEvent], st *sliceStore, batch ...testEvent) {
+	t.Helper()
+	err := c.Record(testFields.Tally(batch), func() error { return st.Append(context.Background(), batch) })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFieldsTally(t *testing.T) {
+	got := testFields.Tally([]testEvent{
+		{tenant: "a", allowed: true},
+		{tenant: "a", reason: "quota"},
+		{tenant: "b", reason: "quota"},
+		{},
+	})
+	want := Tally{
+		Totals: Totals{Received: 4, Allowed: 1, Denied: 3},
+		PerTenant: map[string]TenantStats{
+			"a": {TotalReceived: 2, TotalAllowed: 1, TotalDenied: 1},
+			"b": {TotalReceived: 1, TotalDenied: 1},
+			"":  {TotalReceived: 1, TotalDenied: 1},
+		},
+		Reasons: map[string]int64{"quota": 2, UnspecifiedReason: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	var merged Tally
+	merged.Merge(testFields.Tally([]testEvent{{tenant: "a", allowed: true}, {tenant: "a", reason: "quota"}}))
+	merged.Merge(Tally{})
+	merged.Merge(testFields.Tally([]testEvent{{tenant: "b", reason: "quota"}, {}}))
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("expected merged tallies %+v, got %+v", want, merged)
+	}
+
+	noReasons := testFields
+	noReasons.Reasons = false
+	if got := noReasons.Tally([]testEvent{{reason: "quota"}}); len(got.Reasons) != 0 {
+		t.Errorf("expected no reasons counted without Reasons, got %v", got.Reasons)
+	}
+}
+
+func TestCore(t *testing.T) {
+	ctx := context.Background()
+	st := &sliceStore{dropped: 3}
+	c := New[testEvent](st, testFields)
+
+	record(t, c, st, testEvent{tenant: "a", allowed: true}, testEvent{tenant: "b", reason: "quota"})
+	record(t, c, st, testEvent{tenant: "a"})
+	snap, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Snapshot{
+		Totals:  Totals{Received: 3, Allowed: 1, Denied: 2},
+		Stored:  3,
+		Dropped: 3,
+		Reasons: map[string]int64{"quota": 1, UnspecifiedReason: 1},
+	}
+	if !reflect.DeepEqual(snap, want) {
+		t.Errorf("expected %+v, got %+v", want, snap)
+	}
+
+	// A failed store counts nothing.
+	st.fail = errors.New("disk full")
+	if err := c.Record(testFields.Tally([]testEvent{{}}), func() error { return st.Append(ctx, []testEvent{{}}) }); err == nil {
+		t.Fatal("expected the store's error")
+	}
+	st.fail = nil
+	if got := c.Totals().Received; got != 3 {
+		t.Errorf("expected a failed store not to be counted, got %d received", got)
+	}
+
+	n, err := c.Delete(ctx, func(ev testEvent) bool { return ev.tenant == "a" })
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 events deleted, got %d, %v", n, err)
+	}
+	if got, want := c.Totals(), (Totals{Received: 1, Denied: 1}); got != want {
+		t.Errorf("expected %+v after the delete, got %+v", want, got)
+	}
+	if got := c.TenantStats(); len(got.Tenants) != 1 || got.Tenants["b"].TotalReceived != 1 {
+		t.Errorf("expected only tenant b left, got %+v", got)
+	}
+	if got := c.DenyReasons(); !reflect.DeepEqual(got, map[string]int64{"quota": 1}) {
+		t.Errorf("expected only the quota denial left, got %v", got)
+	}
+
+	// Resets count drops from the reset on, and keep the lifetime totals.
+	st.dropped = 5
+	if err := c.ResetCounters(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap, _ := c.Snapshot(ctx); snap.Totals != (Totals{}) || snap.Stored != 1 || snap.Dropped != 0 {
+		t.Errorf("expected zeroed counters with the event kept, got %+v", snap)
+	}
+	if got, want := c.Lifetime(), (Totals{Received: 3, Allowed: 1, Denied: 2}); got != want {
+		t.Errorf("expected lifetime totals %+v, got %+v", want, got)
+	}
+
+	record(t, c, st, testEvent{tenant: "c", allowed: true})
+	events, err := c.Events(ctx)
+	if err != nil || !reflect.DeepEqual(events, []testEvent{{tenant: "b", reason: "quota"}, {tenant: "c", allowed: true}}) {
+		t.Errorf("expected the stored events oldest first, got %+v, %v", events, err)
+	}
+	if err := c.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap, _ := c.Snapshot(ctx); snap.Totals != (Totals{}) || snap.Stored != 0 {
+		t.Errorf("expected nothing left after a clear, got %+v", snap)
+	}
+}
+
+func TestCore_UnwrapsStores(t *testing.T) {
+	st := &sliceStore{dropped: 7}
+	if got := DroppedEvents[testEvent](wrappedStore{st}); got != 7 {
+		t.Errorf("expected the wrapped store's drops, got %d", got)
+	}
+	if ts := TenantScannerOf[testEvent](wrappedStore{st}); ts != nil {
+		t.Errorf("expected no TenantScanner, got %T", ts)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	cutoff := time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC)
+	for ts, want := range map[string]bool{
+		"2026-02-16T20:59:59Z": true,
+		"2026-02-16T21:00:00Z": false,
+		"yesterday":            false,
+	} {
+		if got := Expired(ts, cutoff); got != want {
+			t.Errorf("Expired(%q): expected %v, got %v", ts, want, got)
+		}
+	}
+}
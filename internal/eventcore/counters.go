@@ -0,0 +1,221 @@
+package eventcore
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// UnspecifiedReason buckets denied events published without a reason.
+const UnspecifiedReason = "unspecified"
+
+// Totals are the received, allowed and denied event counts.
+type Totals struct {
+	Received int64
+	Allowed  int64
+	Denied   int64
+}
+
+// TenantStats holds the counters for a single tenant.
+type TenantStats struct {
+	TotalReceived int64 `json:"total_received"`
+	TotalAllowed  int64 `json:"total_allowed"`
+	TotalDenied   int64 `json:"total_denied"`
+}
+
+// TenantStatsResponse is the body of GET /events/stats/by-tenant. Events
+// published without a tenant key are counted under NoTenant.
+type TenantStatsResponse struct {
+	Tenants  map[string]TenantStats `json:"tenants"`
+	NoTenant TenantStats            `json:"no_tenant"`
+}
+
+// Tally is a batch of events counted the way the stats report them.
+type Tally struct {
+	Totals
+	// PerTenant is keyed by tenant key, with events without one under the
+	// empty key.
+	PerTenant map[string]TenantStats
+	// Reasons counts the denied events by reason. It is empty when the
+	// events don't carry reasons.
+	Reasons map[string]int64
+}
+
+// Merge adds the counts of o to t, for batches published in parts.
+func (t *Tally) Merge(o Tally) {
+	t.Received += o.Received
+	t.Allowed += o.Allowed
+	t.Denied += o.Denied
+	if len(o.PerTenant) == 0 {
+		return
+	}
+	if t.PerTenant == nil {
+		t.PerTenant = make(map[string]TenantStats, len(o.PerTenant))
+		t.Reasons = make(map[string]int64, len(o.Reasons))
+	}
+	for tenant, d := range o.PerTenant {
+		ts := t.PerTenant[tenant]
+		ts.TotalReceived += d.TotalReceived
+		ts.TotalAllowed += d.TotalAllowed
+		ts.TotalDenied += d.TotalDenied
+		t.PerTenant[tenant] = ts
+	}
+	for reason, n := range o.Reasons {
+		t.Reasons[reason] += n
+	}
+}
+
+// TenantStats returns t's per-tenant counts as GET /events/stats/by-tenant
+// reports them.
+func (t Tally) TenantStats() TenantStatsResponse {
+	resp := TenantStatsResponse{Tenants: make(map[string]TenantStats, len(t.PerTenant))}
+	for key, ts := range t.PerTenant {
+		if key == "" {
+			resp.NoTenant = ts
+			continue
+		}
+		resp.Tenants[key] = ts
+	}
+	return resp
+}
+
+// add counts one event into t.
+func (t *Tally) add(tenant string, allowed bool, reason string, withReason bool) {
+	if t.PerTenant == nil {
+		t.PerTenant = make(map[string]TenantStats)
+		t.Reasons = make(map[string]int64)
+	}
+	ts := t.PerTenant[tenant]
+	t.Received++
+	ts.TotalReceived++
+	if allowed {
+		t.Allowed++
+		ts.TotalAllowed++
+	} else {
+		t.Denied++
+		ts.TotalDenied++
+		if withReason {
+			if reason == "" {
+				reason = UnspecifiedReason
+			}
+			t.Reasons[reason]++
+		}
+	}
+	t.PerTenant[tenant] = ts
+}
+
+// tenantCounters tracks per-tenant totals since the last clear. Events
+// without a tenant key are counted under the empty key.
+type tenantCounters struct {
+	mu     sync.Mutex
+	counts map[string]TenantStats
+}
+
+func (c *tenantCounters) add(delta map[string]TenantStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]TenantStats, len(delta))
+	}
+	for key, d := range delta {
+		ts := c.counts[key]
+		ts.TotalReceived += d.TotalReceived
+		ts.TotalAllowed += d.TotalAllowed
+		ts.TotalDenied += d.TotalDenied
+		c.counts[key] = ts
+	}
+}
+
+// subtract takes delta off the counters, for events deleted from the
+// store. Counters don't drop below zero, since the events may have been
+// received before the counters were last reset.
+func (c *tenantCounters) subtract(delta map[string]TenantStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, d := range delta {
+		ts, ok := c.counts[key]
+		if !ok {
+			continue
+		}
+		ts.TotalReceived = max(ts.TotalReceived-d.TotalReceived, 0)
+		ts.TotalAllowed = max(ts.TotalAllowed-d.TotalAllowed, 0)
+		ts.TotalDenied = max(ts.TotalDenied-d.TotalDenied, 0)
+		if ts == (TenantStats{}) {
+			delete(c.counts, key)
+		} else {
+			c.counts[key] = ts
+		}
+	}
+}
+
+func (c *tenantCounters) snapshot() map[string]TenantStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]TenantStats, len(c.counts))
+	for key, ts := range c.counts {
+		out[key] = ts
+	}
+	return out
+}
+
+func (c *tenantCounters) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = nil
+}
+
+// reasonCounters counts denied events by reason since the last clear.
+type reasonCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *reasonCounters) add(delta map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64, len(delta))
+	}
+	for reason, n := range delta {
+		c.counts[reason] += n
+	}
+}
+
+// subtract takes delta off the counters, for denied events deleted from
+// the store, without letting them drop below zero.
+func (c *reasonCounters) subtract(delta map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for reason, n := range delta {
+		if left := c.counts[reason] - n; left > 0 {
+			c.counts[reason] = left
+		} else {
+			delete(c.counts, reason)
+		}
+	}
+}
+
+func (c *reasonCounters) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.counts))
+	for reason, n := range c.counts {
+		out[reason] = n
+	}
+	return out
+}
+
+func (c *reasonCounters) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = nil
+}
+
+// decrease subtracts n from c without letting it drop below zero.
+func decrease(c *atomic.Int64, n int64) {
+	for {
+		old := c.Load()
+		if c.CompareAndSwap(old, max(old-n, 0)) {
+			return
+		}
+	}
+}
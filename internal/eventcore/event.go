@@ -0,0 +1,140 @@
+package eventcore
+
+import (
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Event is the protocol-neutral view of a usage event that the shared code
+// reads. The optional strings are nil when the event doesn't set them, so
+// that stores can keep an unset field apart from an empty one.
+type Event struct {
+	Key        string
+	TenantKey  *string
+	Method     string
+	Path       string
+	Allowed    bool
+	Remaining  int64
+	Limit      int64
+	Timestamp  string
+	StatusCode int32
+	RequestID  *string
+	Reason     *string
+}
+
+// tenant returns the tenant key, or "" when the event has none.
+func (e Event) tenant() string { return deref(e.TenantKey) }
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// Fields adapts a template's event type E to the shared code: how to read
+// and build one, and how it is encoded in responses and by stores.
+type Fields[E any] struct {
+	// View returns the parts of ev the shared code reads.
+	View func(ev E) Event
+	// Make builds an event from a view, for stores that keep events as
+	// columns.
+	Make func(Event) E
+	// Reasons is set for event types that carry a denial reason. Without
+	// it denials aren't counted by reason, and stats leave the breakdown
+	// out.
+	Reasons bool
+	// JSON returns the value ev is written as in JSON responses.
+	JSON func(ev E) any
+	// ProtoBatch wraps events in the protobuf message that protobuf list
+	// responses use.
+	ProtoBatch func(events []E) proto.Message
+	// Encode and Decode convert an event to and from the JSON value stores
+	// that serialize events keep it as.
+	Encode func(ev E) ([]byte, error)
+	Decode func(data []byte) (E, error)
+	// Size estimates the memory ev occupies, for the approx_bytes stat.
+	Size func(ev E) int64
+}
+
+// Tally counts batch.
+func (f Fields[E]) Tally(batch []E) Tally {
+	var t Tally
+	for _, ev := range batch {
+		f.add(&t, ev)
+	}
+	return t
+}
+
+// size is Size, or zero for event types without it.
+func (f Fields[E]) size(ev E) int64 {
+	if f.Size == nil {
+		return 0
+	}
+	return f.Size(ev)
+}
+
+func (f Fields[E]) add(t *Tally, ev E) {
+	v := f.View(ev)
+	t.add(v.tenant(), v.Allowed, deref(v.Reason), f.Reasons)
+}
+
+// jsonEvents returns the values events are written as in JSON responses.
+func (f Fields[E]) jsonEvents(events []E) []any {
+	out := make([]any, len(events))
+	for i, ev := range events {
+		out[i] = f.JSON(ev)
+	}
+	return out
+}
+
+// Values accepted by the list "sort" parameter. The default is insertion
+// order, newest first.
+const (
+	sortTimestampDesc = "timestamp_desc"
+	sortTimestampAsc  = "timestamp_asc"
+)
+
+// Values accepted by the list "order" parameter, the direction of the
+// default insertion order.
+const (
+	orderDesc = "desc"
+	orderAsc  = "asc"
+)
+
+// SortByTimestamp orders events, given newest-first by insertion, by their
+// RFC 3339 timestamp. Events with equal timestamps keep insertion order in
+// the requested direction, and events whose timestamp can't be parsed come
+// last, in insertion order.
+func (f Fields[E]) SortByTimestamp(events []E, asc bool) {
+	if asc {
+		slices.Reverse(events)
+	}
+	type keyed struct {
+		ts time.Time
+		ok bool
+		ev E
+	}
+	keys := make([]keyed, len(events))
+	for i, ev := range events {
+		ts, err := time.Parse(time.RFC3339, f.View(ev).Timestamp)
+		keys[i] = keyed{ts: ts, ok: err == nil, ev: ev}
+	}
+	slices.SortStableFunc(keys, func(a, b keyed) int {
+		if a.ok != b.ok {
+			if a.ok {
+				return -1
+			}
+			return 1
+		}
+		if asc {
+			return a.ts.Compare(b.ts)
+		}
+		return b.ts.Compare(a.ts)
+	})
+	for i, k := range keys {
+		events[i] = k.ev
+	}
+}
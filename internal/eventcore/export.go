@@ -0,0 +1,144 @@
+package eventcore
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Media types of the list encodings besides JSON.
+const (
+	MediaTypeNDJSON   = "application/x-ndjson"
+	MediaTypeProtobuf = "application/x-protobuf"
+)
+
+// csvHeader names the event fields in the order csvRecord writes them. The
+// reason column is only written for event types that have one.
+var csvHeader = []string{
+	"key", "tenant_key", "method", "path", "allowed", "remaining",
+	"limit", "timestamp", "status_code", "request_id", "reason",
+}
+
+// listFormat is the encoding of a list response.
+type listFormat int
+
+const (
+	formatJSON listFormat = iota
+	formatNDJSON
+	formatProtobuf
+)
+
+// negotiateListFormat picks the list response encoding: format=json,
+// format=ndjson or format=protobuf if given, otherwise the first of
+// application/x-ndjson and application/x-protobuf named in the Accept
+// header, falling back to a JSON array.
+func negotiateListFormat(r *http.Request) (listFormat, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case "json":
+		return formatJSON, nil
+	case "ndjson":
+		return formatNDJSON, nil
+	case "protobuf":
+		return formatProtobuf, nil
+	default:
+		return formatJSON, fmt.Errorf("invalid format parameter %q", format)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch mediaType = strings.TrimSpace(mediaType); {
+		case strings.EqualFold(mediaType, MediaTypeNDJSON):
+			return formatNDJSON, nil
+		case strings.EqualFold(mediaType, MediaTypeProtobuf):
+			return formatProtobuf, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// writeNDJSON writes events one JSON object per line.
+func (s *Service[E]) writeNDJSON(w http.ResponseWriter, events []E) {
+	w.Header().Set("Content-Type", MediaTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, ev := range events {
+		if err := enc.Encode(s.fields.JSON(ev)); err != nil {
+			return
+		}
+	}
+}
+
+// WriteProtobuf writes msg as a binary protobuf message. List responses
+// wrap their events in a PublishEventsRequest, the repeated UsageEvent
+// message both protocols already share.
+func WriteProtobuf(w http.ResponseWriter, code int, msg proto.Message) {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to encode response"})
+		return
+	}
+	w.Header().Set("Content-Type", MediaTypeProtobuf)
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// HandleExportCSV streams every stored event matching the list filters as
+// CSV, newest first. Rows are written as the store is scanned rather than
+// buffered, so a failure part-way through truncates the export; it is
+// logged but can't change the status code.
+func (s *Service[E]) HandleExportCSV(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="events.csv"`)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(s.csvColumns(csvHeader))
+	err = s.Scan(r.Context(), filter, 0, func(_ int64, ev E) bool {
+		v := s.fields.View(ev)
+		if !filter.Match(v) {
+			return true
+		}
+		return cw.Write(s.csvColumns(csvRecord(v))) == nil
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		s.logger.Error("failed to export events", "error", err)
+	}
+}
+
+// csvColumns drops the reason column from a row for event types without
+// one.
+func (s *Service[E]) csvColumns(row []string) []string {
+	if s.fields.Reasons {
+		return row
+	}
+	return row[:len(row)-1]
+}
+
+func csvRecord(ev Event) []string {
+	return []string{
+		ev.Key,
+		ev.tenant(),
+		ev.Method,
+		ev.Path,
+		strconv.FormatBool(ev.Allowed),
+		strconv.FormatInt(ev.Remaining, 10),
+		strconv.FormatInt(ev.Limit, 10),
+		ev.Timestamp,
+		strconv.FormatInt(int64(ev.StatusCode), 10),
+		deref(ev.RequestID),
+		deref(ev.Reason),
+	}
+}
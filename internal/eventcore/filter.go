@@ -0,0 +1,133 @@
+package eventcore
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter holds the query-string predicates accepted by the query API.
+// The zero value matches every event.
+type Filter struct {
+	TenantKey  string
+	Allowed    *bool
+	Method     string
+	PathPrefix string
+	Since      time.Time
+	Until      time.Time
+	// StatusMin and StatusMax bound the status code, inclusively.
+	StatusMin *int32
+	StatusMax *int32
+	// Query is matched, lowercased, against the event's text fields.
+	Query string
+}
+
+// FilterParams are the query parameters ParseFilter reads.
+var FilterParams = []string{
+	"tenant_key", "allowed", "method", "path_prefix", "q", "status_min", "status_max", "since", "until",
+}
+
+// ParseFilter reads a Filter from the list query parameters.
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		TenantKey:  q.Get("tenant_key"),
+		Method:     q.Get("method"),
+		PathPrefix: q.Get("path_prefix"),
+		Query:      strings.ToLower(q.Get("q")),
+	}
+	if v := q.Get("allowed"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid allowed parameter %q", v)
+		}
+		f.Allowed = &b
+	}
+	for _, bound := range []struct {
+		name string
+		dst  **int32
+	}{{"status_min", &f.StatusMin}, {"status_max", &f.StatusMax}} {
+		v := q.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s parameter %q", bound.name, v)
+		}
+		code := int32(n)
+		*bound.dst = &code
+	}
+	if f.StatusMin != nil && f.StatusMax != nil && *f.StatusMin > *f.StatusMax {
+		return f, fmt.Errorf("status_min %d is greater than status_max %d", *f.StatusMin, *f.StatusMax)
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("invalid since parameter %q: must be RFC 3339", v)
+		}
+		f.Since = t
+	}
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("invalid until parameter %q: must be RFC 3339", v)
+		}
+		f.Until = t
+	}
+	return f, nil
+}
+
+// Match reports whether ev satisfies every predicate of f.
+func (f Filter) Match(ev Event) bool {
+	if f.TenantKey != "" && ev.tenant() != f.TenantKey {
+		return false
+	}
+	if f.Allowed != nil && ev.Allowed != *f.Allowed {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(ev.Method, f.Method) {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(ev.Path, f.PathPrefix) {
+		return false
+	}
+	if f.StatusMin != nil && ev.StatusCode < *f.StatusMin {
+		return false
+	}
+	if f.StatusMax != nil && ev.StatusCode > *f.StatusMax {
+		return false
+	}
+	if f.Query != "" && !f.matchQuery(ev) {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		// Events whose timestamp can't be parsed can't be placed in the
+		// window, so they are excluded rather than failing the request.
+		ts, err := time.Parse(time.RFC3339, ev.Timestamp)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && ts.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && ts.After(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchQuery reports whether any of the event's text fields contains the
+// query, ignoring case.
+func (f Filter) matchQuery(ev Event) bool {
+	for _, field := range [...]string{
+		ev.Key, ev.Path, ev.Method, ev.tenant(), deref(ev.RequestID), deref(ev.Reason),
+	} {
+		if strings.Contains(strings.ToLower(field), f.Query) {
+			return true
+		}
+	}
+	return false
+}
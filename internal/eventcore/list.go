@@ -0,0 +1,57 @@
+package eventcore
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+)
+
+// EventsPage is the JSON body of a cursor-paged list.
+type EventsPage[E any] struct {
+	Events     []E    `json:"events"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// EventsEnvelope is the JSON body of a list when an envelope is asked
+// for. Count is the number of events in this response and Total the
+// number matching the filters.
+type EventsEnvelope[E any] struct {
+	Events     []E    `json:"events"`
+	Count      int    `json:"count"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListLimit returns the limit to apply for the limit query parameter v:
+// defaultLimit when v is missing or not a positive number, lowered to
+// maxLimit when that is positive.
+func ListLimit(v string, defaultLimit, maxLimit int) int {
+	limit := defaultLimit
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		limit = n
+	}
+	if maxLimit > 0 {
+		limit = min(limit, maxLimit)
+	}
+	return limit
+}
+
+// EncodeCursor returns an opaque list cursor for the given sequence number.
+func EncodeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(seq, 10)))
+}
+
+// DecodeCursor returns the sequence number of a cursor from EncodeCursor.
+func DecodeCursor(c string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || seq <= 0 {
+		return 0, errors.New("malformed cursor")
+	}
+	return seq, nil
+}
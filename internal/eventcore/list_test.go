@@ -0,0 +1,36 @@
+package eventcore
+
+import "testing"
+
+func TestCursor(t *testing.T) {
+	for _, seq := range []int64{1, 42, 1 << 40} {
+		got, err := DecodeCursor(EncodeCursor(seq))
+		if err != nil || got != seq {
+			t.Errorf("round trip of %d: got %d, %v", seq, got, err)
+		}
+	}
+	for _, c := range []string{"!!", EncodeCursor(0), "YWJj"} {
+		if _, err := DecodeCursor(c); err == nil {
+			t.Errorf("%q: expected an error", c)
+		}
+	}
+}
+
+func TestListLimit(t *testing.T) {
+	for _, tc := range []struct {
+		v                 string
+		defaultLimit, max int
+		want              int
+	}{
+		{"", 100, 1000, 100},
+		{"5", 100, 1000, 5},
+		{"-5", 100, 1000, 100},
+		{"abc", 100, 1000, 100},
+		{"5000", 100, 1000, 1000},
+		{"5000", 100, 0, 5000},
+	} {
+		if got := ListLimit(tc.v, tc.defaultLimit, tc.max); got != tc.want {
+			t.Errorf("ListLimit(%q, %d, %d): expected %d, got %d", tc.v, tc.defaultLimit, tc.max, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package eventcore
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// ParseLogLevel parses debug, info, warn or error, ignoring case. Any other
+// value yields slog.LevelInfo and ok == false.
+func ParseLogLevel(s string) (level slog.Level, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelInfo, false
+	}
+}
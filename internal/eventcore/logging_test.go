@@ -0,0 +1,28 @@
+package eventcore
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   slog.Level
+		wantOK bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"INFO", slog.LevelInfo, true},
+		{" warn ", slog.LevelWarn, true},
+		{"warning", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"", slog.LevelInfo, false},
+		{"verbose", slog.LevelInfo, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseLogLevel(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseLogLevel(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
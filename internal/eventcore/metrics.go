@@ -0,0 +1,47 @@
+package eventcore
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	receivedDesc   = prometheus.NewDesc("edgequota_events_received_total", "Total usage events received.", nil, nil)
+	allowedDesc    = prometheus.NewDesc("edgequota_events_allowed_total", "Total usage events with an allowed decision.", nil, nil)
+	deniedDesc     = prometheus.NewDesc("edgequota_events_denied_total", "Total usage events with a denied decision.", nil, nil)
+	allowRatioDesc = prometheus.NewDesc("edgequota_events_allow_ratio", "Fraction of the usage events received since startup with an allowed decision.", nil, nil)
+	denyRatioDesc  = prometheus.NewDesc("edgequota_events_deny_ratio", "Fraction of the usage events received since startup with a denied decision.", nil, nil)
+	storedDesc     = prometheus.NewDesc("edgequota_events_stored", "Usage events currently held in memory.", nil, nil)
+	latencyDesc    = prometheus.NewDesc("edgequota_events_publish_duration_seconds", "Time taken to decode and store an accepted publish.", nil, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (s *Service[E]) Describe(ch chan<- *prometheus.Desc) {
+	ch <- receivedDesc
+	ch <- allowedDesc
+	ch <- deniedDesc
+	ch <- allowRatioDesc
+	ch <- denyRatioDesc
+	ch <- storedDesc
+	ch <- latencyDesc
+}
+
+// Collect implements prometheus.Collector. The counters are the lifetime
+// totals, which HandleClearEvents never resets, so they stay monotonic.
+func (s *Service[E]) Collect(ch chan<- prometheus.Metric) {
+	lifetime := s.core.Lifetime()
+	received, allowed, denied := lifetime.Received, lifetime.Allowed, lifetime.Denied
+	ch <- prometheus.MustNewConstMetric(receivedDesc, prometheus.CounterValue, float64(received))
+	ch <- prometheus.MustNewConstMetric(allowedDesc, prometheus.CounterValue, float64(allowed))
+	ch <- prometheus.MustNewConstMetric(deniedDesc, prometheus.CounterValue, float64(denied))
+	ch <- prometheus.MustNewConstMetric(allowRatioDesc, prometheus.GaugeValue, Ratio(allowed, received))
+	ch <- prometheus.MustNewConstMetric(denyRatioDesc, prometheus.GaugeValue, Ratio(denied, received))
+	ch <- prometheus.MustNewConstMetric(storedDesc, prometheus.GaugeValue, float64(s.storedCount()))
+
+	counts, total := s.publishLatency.Counts()
+	buckets := make(map[float64]uint64, len(LatencyBuckets))
+	var cumulative int64
+	for i, bound := range LatencyBuckets {
+		cumulative += counts[i]
+		buckets[bound.Seconds()] = uint64(cumulative)
+	}
+	sum := s.publishLatency.Sum().Seconds()
+	ch <- prometheus.MustNewConstHistogram(latencyDesc, uint64(total), sum, buckets)
+}
@@ -0,0 +1,42 @@
+package eventcore
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// newPprofMux serves the net/http/pprof handlers under /debug/pprof/. It
+// is kept off the API mux so that profiles are only reachable on the
+// -pprof-addr listener.
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// pprofListenAddr binds an address without a host, such as ":6060", to
+// localhost, so that profiles aren't exposed unless a host is given.
+func pprofListenAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// NewPprofServer returns the server for -pprof-addr. It has no write
+// timeout, since CPU profiles and traces run for as long as asked.
+func NewPprofServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              pprofListenAddr(addr),
+		Handler:           newPprofMux(),
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+}
@@ -0,0 +1,43 @@
+package eventcore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPprofMux(t *testing.T) {
+	mux := newPprofMux()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d", path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected the profile index, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected the API routes to be left out, got %d for /events", w.Code)
+	}
+}
+
+func TestPprofListenAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		":6060":         "localhost:6060",
+		"0.0.0.0:6060":  "0.0.0.0:6060",
+		"10.0.0.5:6060": "10.0.0.5:6060",
+		"[::1]:6060":    "[::1]:6060",
+	} {
+		if got := pprofListenAddr(addr); got != want {
+			t.Errorf("pprofListenAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package eventcore
+
+import (
+	"net/http"
+	"time"
+)
+
+// RateStats is the body of GET /events/stats/rate: the average events per
+// second over each of the trailing windows.
+type RateStats struct {
+	OneMinute      float64 `json:"1m"`
+	FiveMinutes    float64 `json:"5m"`
+	FifteenMinutes float64 `json:"15m"`
+}
+
+// HandleRateStats reports recent throughput from the stored events'
+// timestamps, over the last 1, 5 and 15 minutes. It accepts the list
+// filters. Events with unparseable or future timestamps are left out, and
+// an empty window has a rate of zero.
+func (s *Service[E]) HandleRateStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	now := s.cfg.Clock.Now()
+	var in1, in5, in15 int
+	err = s.Scan(r.Context(), filter, 0, func(_ int64, e E) bool {
+		ev := s.fields.View(e)
+		if !filter.Match(ev) {
+			return true
+		}
+		ts, err := time.Parse(time.RFC3339, ev.Timestamp)
+		if err != nil || ts.After(now) {
+			return true
+		}
+		switch age := now.Sub(ts); {
+		case age <= time.Minute:
+			in1++
+			fallthrough
+		case age <= 5*time.Minute:
+			in5++
+			fallthrough
+		case age <= 15*time.Minute:
+			in15++
+		}
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to compute event rates", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to compute event rates"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, RateStats{
+		OneMinute:      float64(in1) / time.Minute.Seconds(),
+		FiveMinutes:    float64(in5) / (5 * time.Minute).Seconds(),
+		FifteenMinutes: float64(in15) / (15 * time.Minute).Seconds(),
+	})
+}
@@ -0,0 +1,105 @@
+package eventcore
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRateLimitBurst is the burst a source may publish in above its
+	// rate when none is configured.
+	DefaultRateLimitBurst = 20
+
+	// maxRateLimitSources bounds the number of sources tracked at once.
+	maxRateLimitSources = 10000
+)
+
+// SourceLimiter rate limits publishes with a token bucket per source. A
+// source idle long enough for its bucket to refill is indistinguishable from
+// a new one, so such entries are pruned to keep the map bounded.
+type SourceLimiter struct {
+	// Now is the clock buckets refill by; NewSourceLimiter sets it to
+	// time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	idle      time.Duration
+	lastPrune time.Time
+	sources   map[string]*sourceEntry
+}
+
+type sourceEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewSourceLimiter allows each source perSecond publishes per second on
+// average, with bursts of up to burst.
+func NewSourceLimiter(perSecond float64, burst int) *SourceLimiter {
+	burst = max(burst, 1)
+	return &SourceLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		idle:    max(time.Duration(float64(burst)/perSecond*float64(time.Second)), time.Second),
+		Now:     time.Now,
+		sources: make(map[string]*sourceEntry),
+	}
+}
+
+// Allow reports whether source may publish now, taking a token if so.
+func (l *SourceLimiter) Allow(source string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	if now.Sub(l.lastPrune) >= l.idle {
+		l.prune(now)
+	}
+	e, ok := l.sources[source]
+	if !ok {
+		if len(l.sources) >= maxRateLimitSources {
+			l.prune(now)
+			if len(l.sources) >= maxRateLimitSources {
+				l.evictOldest()
+			}
+		}
+		e = &sourceEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.sources[source] = e
+	}
+	e.lastSeen = now
+	return e.limiter.AllowN(now, 1)
+}
+
+// prune drops sources whose bucket has had time to refill completely.
+func (l *SourceLimiter) prune(now time.Time) {
+	l.lastPrune = now
+	for source, e := range l.sources {
+		if now.Sub(e.lastSeen) >= l.idle {
+			delete(l.sources, source)
+		}
+	}
+}
+
+func (l *SourceLimiter) evictOldest() {
+	var oldest string
+	var oldestEntry *sourceEntry
+	for source, e := range l.sources {
+		if oldestEntry == nil || e.lastSeen.Before(oldestEntry.lastSeen) {
+			oldest, oldestEntry = source, e
+		}
+	}
+	delete(l.sources, oldest)
+}
+
+// SourceHost strips the port from a remote address. Addresses without one,
+// such as in-memory test listeners, are used as they are.
+func SourceHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,72 @@
+package eventcore
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSourceLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewSourceLimiter(1, 2)
+	l.Now = func() time.Time { return now }
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if l.Allow("a") {
+		t.Fatal("expected a publish beyond the burst to be limited")
+	}
+	if !l.Allow("b") {
+		t.Error("expected other sources to have their own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow("a") {
+		t.Error("expected a token after the bucket refilled")
+	}
+	if l.Allow("a") {
+		t.Error("expected only one token to have refilled")
+	}
+}
+
+func TestSourceLimiter_Prune(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewSourceLimiter(1, 2)
+	l.Now = func() time.Time { return now }
+	l.Allow("a")
+	l.Allow("b")
+
+	now = now.Add(time.Second)
+	l.Allow("b")
+	now = now.Add(time.Second)
+	l.Allow("c")
+	if _, ok := l.sources["a"]; ok {
+		t.Error("expected the idle source to be pruned")
+	}
+	if len(l.sources) != 2 {
+		t.Errorf("expected 2 tracked sources, got %d", len(l.sources))
+	}
+}
+
+func TestSourceLimiter_Bounded(t *testing.T) {
+	l := NewSourceLimiter(1, 1)
+	for i := range maxRateLimitSources + 10 {
+		l.Allow(strconv.Itoa(i))
+	}
+	if len(l.sources) > maxRateLimitSources {
+		t.Errorf("expected at most %d sources, got %d", maxRateLimitSources, len(l.sources))
+	}
+}
+
+func TestSourceHost(t *testing.T) {
+	for addr, want := range map[string]string{
+		"10.0.0.1:4321": "10.0.0.1",
+		"[::1]:80":      "::1",
+		"bufconn":       "bufconn",
+	} {
+		if got := SourceHost(addr); got != want {
+			t.Errorf("SourceHost(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
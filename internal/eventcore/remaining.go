@@ -0,0 +1,83 @@
+package eventcore
+
+import "net/http"
+
+// remainingBuckets are the ranges of GET /events/stats/remaining, as
+// fractions of the limit left after a decision. Each bucket holds ratios
+// from the previous bound up to but excluding its own; the last one also
+// holds a full bucket.
+var remainingBuckets = []struct {
+	label string
+	below float64
+}{
+	{"0-10%", 0.10},
+	{"10-25%", 0.25},
+	{"25-50%", 0.50},
+	{"50-75%", 0.75},
+	{"75-100%", 1},
+}
+
+// RemainingBucket counts the allowed events whose remaining quota was in
+// Range of their limit.
+type RemainingBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+// RemainingStats is the body of GET /events/stats/remaining.
+type RemainingStats struct {
+	Buckets []RemainingBucket `json:"buckets"`
+	// Total is the number of events in the buckets.
+	Total int `json:"total"`
+	// NoLimit counts the allowed events left out for having no limit set.
+	NoLimit int `json:"no_limit"`
+}
+
+// remainingBucket returns the index in remainingBuckets of the ratio
+// remaining/limit, for a positive limit.
+func remainingBucket(remaining, limit int64) int {
+	ratio := float64(remaining) / float64(limit)
+	for i, b := range remainingBuckets {
+		if ratio < b.below {
+			return i
+		}
+	}
+	return len(remainingBuckets) - 1
+}
+
+// HandleRemainingStats reports how close clients run to their limits: a
+// histogram of the remaining quota as a fraction of the limit across the
+// stored allowed events, in one scan. Denied events, which have nothing
+// left by definition, are left out, and so are events without a limit. It
+// accepts the list filters.
+func (s *Service[E]) HandleRemainingStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	stats := RemainingStats{Buckets: make([]RemainingBucket, len(remainingBuckets))}
+	for i, b := range remainingBuckets {
+		stats.Buckets[i].Range = b.label
+	}
+	err = s.Scan(r.Context(), filter, 0, func(_ int64, e E) bool {
+		ev := s.fields.View(e)
+		if !ev.Allowed || !filter.Match(ev) {
+			return true
+		}
+		if ev.Limit <= 0 {
+			stats.NoLimit++
+			return true
+		}
+		stats.Buckets[remainingBucket(ev.Remaining, ev.Limit)].Count++
+		stats.Total++
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to compute the remaining quota histogram", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to compute the remaining quota histogram"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, stats)
+}
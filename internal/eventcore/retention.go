@@ -0,0 +1,40 @@
+package eventcore
+
+import (
+	"context"
+	"time"
+)
+
+// RetentionInterval returns how often to prune for the given retention:
+// a tenth of it, kept between one second and one minute.
+func RetentionInterval(retention time.Duration) time.Duration {
+	return min(max(retention/10, time.Second), time.Minute)
+}
+
+// RunRetention prunes events older than retention every interval until ctx
+// is cancelled.
+func (s *Service[E]) RunRetention(ctx context.Context, retention, interval time.Duration) {
+	ticker := s.cfg.Clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			s.pruneExpired(ctx, now.Add(-retention))
+		}
+	}
+}
+
+func (s *Service[E]) pruneExpired(ctx context.Context, cutoff time.Time) {
+	n, err := s.storage.Prune(ctx, cutoff)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to prune expired events", "error", err)
+		}
+		return
+	}
+	if n > 0 {
+		s.logger.Info("pruned expired events", "count", n, "cutoff", cutoff.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,18 @@
+package eventcore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionInterval(t *testing.T) {
+	for retention, want := range map[time.Duration]time.Duration{
+		time.Second:    time.Second,
+		time.Minute:    6 * time.Second,
+		24 * time.Hour: time.Minute,
+	} {
+		if got := RetentionInterval(retention); got != want {
+			t.Errorf("RetentionInterval(%v) = %v, want %v", retention, got, want)
+		}
+	}
+}
@@ -0,0 +1,639 @@
+package eventcore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the query API settings of a Service.
+type Config struct {
+	// ListDefault and ListMax are the GET /events limit when none is given
+	// and the most that may be asked for; see ListLimit.
+	ListDefault int
+	ListMax     int
+	// ListEnvelope wraps GET /events responses in an EventsEnvelope unless
+	// the request's envelope parameter says otherwise.
+	ListEnvelope bool
+	// Watermarks applies backpressure as the store fills up; nil disables
+	// it.
+	Watermarks *Watermarks
+	// Clock is what the service tells time by; nil means the system clock.
+	Clock Clock
+}
+
+// Service serves the query API over a Store: listing, counting, exporting,
+// streaming and deleting events, and the stats endpoints. The templates
+// embed it next to their publish path, which reports each accepted batch
+// to it through Core, Broadcast, SetLastEventAt and ObservePublish.
+type Service[E any] struct {
+	logger  *slog.Logger
+	storage Store[E]
+	fields  Fields[E]
+	cfg     Config
+	// core holds the counters behind the stats endpoints and keeps them
+	// in step with storage.
+	core *Core[E]
+
+	broadcast      broadcaster[E]
+	streamsClosed  chan struct{}
+	closeStreams   sync.Once
+	started        time.Time
+	lastEventAt    atomic.Pointer[string]
+	publishLatency LatencyHistogram
+}
+
+// NewService returns a Service for storage, reading events through fields.
+func NewService[E any](logger *slog.Logger, storage Store[E], fields Fields[E], cfg Config) *Service[E] {
+	if cfg.Clock == nil {
+		cfg.Clock = RealClock{}
+	}
+	return &Service[E]{
+		logger:        logger,
+		storage:       storage,
+		fields:        fields,
+		cfg:           cfg,
+		core:          New(storage, fields),
+		broadcast:     broadcaster[E]{view: fields.View},
+		streamsClosed: make(chan struct{}),
+		started:       cfg.Clock.Now(),
+	}
+}
+
+// Core returns the counters kept in step with the store, through which
+// publishes store and count their batches.
+func (s *Service[E]) Core() *Core[E] { return s.core }
+
+// Broadcast passes an accepted batch to the live stream's subscribers.
+func (s *Service[E]) Broadcast(batch []E) { s.broadcast.publish(batch) }
+
+// Subscribe starts delivering the newly accepted events matching filter.
+// A subscriber that falls too far behind is dropped, or with lossy skips
+// events until it catches up.
+func (s *Service[E]) Subscribe(filter Filter, lossy bool) *Subscription[E] {
+	return s.broadcast.subscribe(filter, lossy)
+}
+
+// Unsubscribe stops the deliveries to sub and closes its channel.
+func (s *Service[E]) Unsubscribe(sub *Subscription[E]) { s.broadcast.unsubscribe(sub) }
+
+// CloseStreams ends every open HandleStreamEvents stream and any opened
+// later. A stream otherwise lasts until its client goes away, so register
+// it with http.Server.RegisterOnShutdown to keep Shutdown from waiting on
+// them.
+func (s *Service[E]) CloseStreams() {
+	s.closeStreams.Do(func() { close(s.streamsClosed) })
+}
+
+// Subscribers returns the number of live subscribers.
+func (s *Service[E]) Subscribers() int { return s.broadcast.len() }
+
+// SetLastEventAt records the timestamp of the most recently received event.
+func (s *Service[E]) SetLastEventAt(ts string) { s.lastEventAt.Store(&ts) }
+
+// ObservePublish records how long an accepted publish took.
+func (s *Service[E]) ObservePublish(d time.Duration) { s.publishLatency.Observe(d) }
+
+// match reports whether ev satisfies filter.
+func (s *Service[E]) match(filter Filter, ev E) bool {
+	return filter.Match(s.fields.View(ev))
+}
+
+// Scan is Store.Scan, except that when filter pins the decision and the
+// store keeps denied events apart, only that decision's ring is read, and
+// likewise when filter pins the tenant and the store keeps tenants apart.
+// Callers still match events against the filter themselves.
+func (s *Service[E]) Scan(ctx context.Context, filter Filter, before int64, fn func(int64, E) bool) error {
+	if ts := TenantScannerOf(s.storage); ts != nil && filter.TenantKey != "" {
+		return ts.ScanTenant(ctx, filter.TenantKey, before, fn)
+	}
+	if split := splitStoreOf(s.storage); split != nil && filter.Allowed != nil {
+		return split.ring(*filter.Allowed).Scan(ctx, before, fn)
+	}
+	return s.storage.Scan(ctx, before, fn)
+}
+
+// Matching returns the stored events matching filter, oldest first.
+func (s *Service[E]) Matching(ctx context.Context, filter Filter) ([]E, error) {
+	var out []E
+	err := s.Scan(ctx, filter, 0, func(_ int64, ev E) bool {
+		if s.match(filter, ev) {
+			out = append(out, ev)
+		}
+		return true
+	})
+	slices.Reverse(out)
+	return out, err
+}
+
+// EventStats is the body of GET /events/stats.
+type EventStats struct {
+	TotalReceived int64 `json:"total_received"`
+	TotalAllowed  int64 `json:"total_allowed"`
+	TotalDenied   int64 `json:"total_denied"`
+	StoredEvents  int   `json:"stored_events"`
+	// AllowRate and DenyRate are TotalAllowed and TotalDenied as fractions
+	// of TotalReceived, both 0 while nothing has been received.
+	AllowRate float64 `json:"allow_rate"`
+	DenyRate  float64 `json:"deny_rate"`
+	// TotalDropped counts the oldest events the store discarded to stay
+	// within its cap. A steadily growing value means the cap is too small
+	// for the retention wanted.
+	TotalDropped int64 `json:"total_dropped"`
+	// ApproxBytes is a rough estimate of the memory the stored events
+	// occupy, for sizing the service; see Fields.Size.
+	ApproxBytes int64 `json:"approx_bytes"`
+	// UptimeSeconds is the time since the service started.
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	// LastEventAt is the Timestamp of the most recently received event, or
+	// empty if none has been received since the service started.
+	LastEventAt string `json:"last_event_at"`
+	// PublishLatency covers decoding and storing each accepted batch since
+	// the service started.
+	PublishLatency LatencyStats `json:"publish_latency"`
+	// FillLevel is StoredEvents as a fraction of the watermark capacity,
+	// present only when watermarks are configured.
+	FillLevel *float64 `json:"fill_level,omitempty"`
+	// Reasons counts denied events by their reason; events without one are
+	// counted as "unspecified". It is left out for event types without a
+	// reason.
+	Reasons map[string]int64 `json:"reasons,omitzero"`
+}
+
+// CountResponse is the body of GET /events/count.
+type CountResponse struct {
+	Count int `json:"count"`
+}
+
+// DeleteResponse is the body of a filtered DELETE /events.
+type DeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// HandleListEvents returns the stored events matching the list filters,
+// newest first unless a sort or order says otherwise, as JSON, NDJSON or
+// protobuf.
+func (s *Service[E]) HandleListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	format, err := negotiateListFormat(r)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	envelope, err := s.wantEnvelope(r.URL.Query().Get("envelope"))
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	limit := ListLimit(r.URL.Query().Get("limit"), s.cfg.ListDefault, s.cfg.ListMax)
+	w.Header().Set("X-Applied-Limit", strconv.Itoa(limit))
+	offset := 0
+	if n, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	paged := r.URL.Query().Has("cursor")
+	var before int64
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		seq, err := DecodeCursor(c)
+		if err != nil {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid cursor"})
+			return
+		}
+		before = seq
+	}
+
+	order := r.URL.Query().Get("order")
+	if order != "" && order != orderDesc && order != orderAsc {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid order parameter %q", order)})
+		return
+	}
+
+	switch sortBy := r.URL.Query().Get("sort"); sortBy {
+	case "":
+	case sortTimestampDesc, sortTimestampAsc:
+		if paged {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "sort cannot be combined with cursor"})
+			return
+		}
+		if order != "" {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "order cannot be combined with sort, which sets its own direction"})
+			return
+		}
+		s.listSorted(w, r, filter, func(events []E) { s.fields.SortByTimestamp(events, sortBy == sortTimestampAsc) }, offset, limit, format, envelope)
+		return
+	default:
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid sort parameter %q", sortBy)})
+		return
+	}
+	if order == orderAsc {
+		if paged {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "order=asc cannot be combined with cursor"})
+			return
+		}
+		s.listSorted(w, r, filter, slices.Reverse[[]E], offset, limit, format, envelope)
+		return
+	}
+
+	// A cursor whose event has since been trimmed or cleared makes the store
+	// restart from the newest event.
+	result := []E{}
+	var next int64
+	total := 0
+	skip := offset
+	err = s.Scan(r.Context(), filter, before, func(seq int64, ev E) bool {
+		if !s.match(filter, ev) {
+			return true
+		}
+		// The scan carries on past the page to count every match for
+		// X-Total-Count.
+		total++
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if len(result) < limit {
+			result = append(result, ev)
+			if len(result) == limit {
+				next = seq
+			}
+		}
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to list events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list events"})
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if format != formatJSON {
+		// NDJSON and protobuf have no envelope, so the next cursor travels
+		// in a header.
+		if paged && next > 0 {
+			w.Header().Set("X-Next-Cursor", EncodeCursor(next))
+		}
+		if format == formatNDJSON {
+			s.writeNDJSON(w, result)
+		} else {
+			WriteProtobuf(w, http.StatusOK, s.fields.ProtoBatch(result))
+		}
+		return
+	}
+	var nextCursor string
+	if paged && next > 0 {
+		nextCursor = EncodeCursor(next)
+	}
+	switch {
+	case envelope:
+		WriteJSONCompressed(w, r, http.StatusOK, EventsEnvelope[any]{
+			Events:     s.fields.jsonEvents(result),
+			Count:      len(result),
+			Total:      total,
+			Limit:      limit,
+			Offset:     offset,
+			NextCursor: nextCursor,
+		})
+	case paged:
+		WriteJSONCompressed(w, r, http.StatusOK, EventsPage[any]{Events: s.fields.jsonEvents(result), NextCursor: nextCursor})
+	default:
+		WriteJSONCompressed(w, r, http.StatusOK, s.fields.jsonEvents(result))
+	}
+}
+
+// wantEnvelope reports whether a list response should be wrapped in an
+// EventsEnvelope, given the request's envelope parameter v.
+func (s *Service[E]) wantEnvelope(v string) (bool, error) {
+	if v == "" {
+		return s.cfg.ListEnvelope, nil
+	}
+	envelope, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid envelope parameter %q", v)
+	}
+	return envelope, nil
+}
+
+// listSorted serves HandleListEvents when a sort or the oldest-first order
+// is requested. Every matching event has to be collected, newest first, and
+// put in order by arrange before offset and limit can be applied, so sorted
+// lists don't support cursors.
+func (s *Service[E]) listSorted(w http.ResponseWriter, r *http.Request, filter Filter, arrange func([]E), offset, limit int, format listFormat, envelope bool) {
+	matched := []E{}
+	err := s.Scan(r.Context(), filter, 0, func(_ int64, ev E) bool {
+		if s.match(filter, ev) {
+			matched = append(matched, ev)
+		}
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to list events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list events"})
+		return
+	}
+
+	total := len(matched)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	arrange(matched)
+	matched = matched[min(offset, len(matched)):]
+	matched = matched[:min(limit, len(matched))]
+	switch format {
+	case formatNDJSON:
+		s.writeNDJSON(w, matched)
+		return
+	case formatProtobuf:
+		WriteProtobuf(w, http.StatusOK, s.fields.ProtoBatch(matched))
+		return
+	}
+	if envelope {
+		WriteJSONCompressed(w, r, http.StatusOK, EventsEnvelope[any]{
+			Events: s.fields.jsonEvents(matched),
+			Count:  len(matched),
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		})
+		return
+	}
+	WriteJSONCompressed(w, r, http.StatusOK, s.fields.jsonEvents(matched))
+}
+
+// HandleGetEvent returns the stored event with the request_id in the path.
+// Request IDs aren't unique, so when several events share one the newest
+// is returned.
+func (s *Service[E]) HandleGetEvent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("request_id")
+	var found *E
+	if id != "" {
+		err := s.storage.Scan(r.Context(), 0, func(_ int64, ev E) bool {
+			if deref(s.fields.View(ev).RequestID) == id {
+				found = &ev
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			s.logger.Error("failed to look up event", "request_id", id, "error", err)
+			WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to look up event"})
+			return
+		}
+	}
+	if found == nil {
+		WriteJSON(w, http.StatusNotFound, ErrorResponse{Error: "event not found"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, s.fields.JSON(*found))
+}
+
+// HandleCountEvents returns the number of stored events matching the same
+// filters as HandleListEvents, without returning the events themselves.
+func (s *Service[E]) HandleCountEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var n int
+	err = s.Scan(r.Context(), filter, 0, func(_ int64, ev E) bool {
+		if s.match(filter, ev) {
+			n++
+		}
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to count events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to count events"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, CountResponse{Count: n})
+}
+
+// HandleListTenants returns the sorted, distinct non-empty tenant keys of
+// the stored events.
+func (s *Service[E]) HandleListTenants(w http.ResponseWriter, r *http.Request) {
+	seen := make(map[string]struct{})
+	err := s.storage.Scan(r.Context(), 0, func(_ int64, ev E) bool {
+		if tk := s.fields.View(ev).tenant(); tk != "" {
+			seen[tk] = struct{}{}
+		}
+		return true
+	})
+	if err != nil {
+		s.logger.Error("failed to list tenants", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list tenants"})
+		return
+	}
+	tenants := slices.Sorted(maps.Keys(seen))
+	if tenants == nil {
+		tenants = []string{}
+	}
+	WriteJSON(w, http.StatusOK, tenants)
+}
+
+// HandleStreamEvents streams newly accepted events as server-sent events,
+// one JSON-encoded event per "data:" frame. It accepts the same filter
+// parameters as HandleListEvents. Clients that fall too far behind are
+// disconnected, and every client is once CloseStreams is called.
+func (s *Service[E]) HandleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// The mux routes HEAD to GET handlers and the server drops their body,
+	// but a stream never ends on its own, so answer with the headers alone.
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	sub := s.Subscribe(filter, false)
+	defer s.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's WriteTimeout.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsClosed:
+			return
+		case ev, ok := <-sub.Events:
+			if !ok {
+				s.logger.Warn("dropped slow event stream subscriber")
+				return
+			}
+			data, err := json.Marshal(s.fields.JSON(ev))
+			if err != nil {
+				s.logger.Error("failed to encode event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandleStats returns the counters since the last clear or reset, with
+// the state of the store and the service.
+//
+// A conditional GET is answered from the counters alone: the size of the
+// stored events is only worked out once the ETag has changed.
+func (s *Service[E]) HandleStats(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.core.Snapshot(r.Context())
+	if err != nil {
+		s.logger.Error("failed to count events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to count events"})
+		return
+	}
+	etag := StatsETag(snap.Totals, snap.Stored)
+	w.Header().Set("ETag", etag)
+	if ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	size, err := ApproxBytes(r.Context(), s.storage, s.fields)
+	if err != nil {
+		s.logger.Error("failed to size events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to size events"})
+		return
+	}
+	stats := EventStats{
+		TotalReceived:  snap.Received,
+		TotalAllowed:   snap.Allowed,
+		TotalDenied:    snap.Denied,
+		AllowRate:      Ratio(snap.Allowed, snap.Received),
+		DenyRate:       Ratio(snap.Denied, snap.Received),
+		StoredEvents:   snap.Stored,
+		TotalDropped:   snap.Dropped,
+		ApproxBytes:    size,
+		UptimeSeconds:  int64(s.cfg.Clock.Now().Sub(s.started).Seconds()),
+		PublishLatency: s.publishLatency.Stats(),
+		FillLevel:      s.cfg.Watermarks.fillLevel(snap.Stored),
+		LastEventAt:    deref(s.lastEventAt.Load()),
+	}
+	if s.fields.Reasons {
+		stats.Reasons = snap.Reasons
+	}
+	WriteJSON(w, http.StatusOK, stats)
+}
+
+// HandleTenantStats returns the received/allowed/denied counters broken
+// down by tenant key.
+func (s *Service[E]) HandleTenantStats(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.core.Counts(r.Context())
+	if err != nil {
+		s.logger.Error("failed to read counters", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to count events"})
+		return
+	}
+	WriteJSON(w, http.StatusOK, counts.TenantStats())
+}
+
+// HandleClearEvents deletes every stored event and resets the counters.
+// With any of the list filters it deletes only the matching events
+// instead, takes them off the counters, and reports how many it deleted.
+// Other parameters are rejected, so a misspelt filter can't clear
+// everything.
+func (s *Service[E]) HandleClearEvents(w http.ResponseWriter, r *http.Request) {
+	if q := r.URL.Query(); len(q) > 0 {
+		s.deleteMatching(w, r, q)
+		return
+	}
+	if err := s.core.Clear(r.Context()); err != nil {
+		s.logger.Error("failed to clear events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to clear events"})
+		return
+	}
+
+	s.logger.Info("events cleared")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleResetStats zeroes the received/allowed/denied counters, including
+// the per-tenant ones, without touching the stored events, to start a
+// fresh counting window.
+func (s *Service[E]) HandleResetStats(w http.ResponseWriter, r *http.Request) {
+	if err := s.core.ResetCounters(r.Context()); err != nil {
+		s.logger.Error("failed to reset counters", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to reset counters"})
+		return
+	}
+	s.logger.Info("counters reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service[E]) deleteMatching(w http.ResponseWriter, r *http.Request, q url.Values) {
+	for name := range q {
+		if !slices.Contains(FilterParams, name) {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("unsupported parameter %q for DELETE /events", name)})
+			return
+		}
+	}
+	filter, err := ParseFilter(q)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	n, err := s.core.Delete(r.Context(), func(ev E) bool { return s.match(filter, ev) })
+	if err != nil {
+		s.logger.Error("failed to delete events", "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete events"})
+		return
+	}
+
+	s.logger.Info("events deleted", "count", n, "filter", r.URL.RawQuery)
+	WriteJSON(w, http.StatusOK, DeleteResponse{Deleted: n})
+}
+
+// Flush persists any events the store has accepted but not yet written,
+// returning early if ctx is done. It only has work to do for stores that
+// implement Flusher, such as the queue NewAsyncStore puts in front of one.
+func (s *Service[E]) Flush(ctx context.Context) error {
+	return s.core.Flush(ctx)
+}
+
+func (s *Service[E]) storedCount() int {
+	n, _ := s.storage.Len(context.Background())
+	return n
+}
+
+// StoredEvents returns a snapshot of all stored events, oldest first.
+func (s *Service[E]) StoredEvents() []E {
+	out, _ := s.core.Events(context.Background())
+	return out
+}
@@ -0,0 +1,134 @@
+package eventcore
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ratio returns n/total, or 0 when total is 0.
+func Ratio(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// StatsETag returns the entity tag of a stats response: a weak one, since
+// it only follows the counters and the number of stored events, not the
+// uptime or latency figures that change on every poll.
+func StatsETag(t Totals, stored int) string {
+	return fmt.Sprintf(`W/"%d-%d-%d-%d"`, t.Received, t.Allowed, t.Denied, stored)
+}
+
+// ETagMatches reports whether an If-None-Match header lists etag, using
+// the weak comparison RFC 9110 requires for it.
+func ETagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LatencyBuckets are the upper bounds of the publish latency histogram.
+// Slower publishes land in an implicit overflow bucket.
+var LatencyBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// LatencyStats summarises the publish latency histogram. Percentiles are
+// estimated by interpolating within the bucket they fall in.
+type LatencyStats struct {
+	Count int64   `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// LatencyHistogram is a fixed-bucket histogram of publish durations, safe
+// for concurrent use. The zero value is ready to use.
+type LatencyHistogram struct {
+	buckets [len(LatencyBuckets) + 1]atomic.Int64
+	sum     atomic.Int64 // nanoseconds
+}
+
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Counts returns the per-bucket counts and their total. Observations made
+// while it runs may or may not be included.
+func (h *LatencyHistogram) Counts() ([len(LatencyBuckets) + 1]int64, int64) {
+	var out [len(LatencyBuckets) + 1]int64
+	var total int64
+	for i := range h.buckets {
+		out[i] = h.buckets[i].Load()
+		total += out[i]
+	}
+	return out, total
+}
+
+// Sum returns the total of the observed durations.
+func (h *LatencyHistogram) Sum() time.Duration {
+	return time.Duration(h.sum.Load())
+}
+
+func (h *LatencyHistogram) Stats() LatencyStats {
+	counts, total := h.Counts()
+	return LatencyStats{
+		Count: total,
+		P50Ms: quantileMs(counts, total, 0.50),
+		P90Ms: quantileMs(counts, total, 0.90),
+		P99Ms: quantileMs(counts, total, 0.99),
+	}
+}
+
+// quantileMs estimates the q-quantile in milliseconds. Observations in the
+// overflow bucket are reported at the largest bound.
+func quantileMs(counts [len(LatencyBuckets) + 1]int64, total int64, q float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	var seen int64
+	for i, n := range counts {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		if i == len(LatencyBuckets) {
+			break
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = LatencyBuckets[i-1]
+		}
+		upper := LatencyBuckets[i]
+		frac := (rank - float64(seen)) / float64(n)
+		return float64(lower+time.Duration(frac*float64(upper-lower))) / float64(time.Millisecond)
+	}
+	return float64(LatencyBuckets[len(LatencyBuckets)-1]) / float64(time.Millisecond)
+}
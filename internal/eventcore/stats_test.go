@@ -0,0 +1,63 @@
+package eventcore
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h LatencyHistogram
+	if got := h.Stats(); got != (LatencyStats{}) {
+		t.Errorf("expected zero stats when empty, got %+v", got)
+	}
+
+	for range 50 {
+		h.Observe(80 * time.Microsecond)
+		h.Observe(3 * time.Millisecond)
+	}
+	got := h.Stats()
+	want := LatencyStats{Count: 100, P50Ms: 0.1, P90Ms: 4.5, P99Ms: 4.95}
+	if got.Count != want.Count || !approx(got.P50Ms, want.P50Ms) || !approx(got.P90Ms, want.P90Ms) || !approx(got.P99Ms, want.P99Ms) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := h.Sum(); got != 50*(80*time.Microsecond+3*time.Millisecond) {
+		t.Errorf("expected the durations summed, got %v", got)
+	}
+
+	var slow LatencyHistogram
+	slow.Observe(time.Minute)
+	if got := slow.Stats().P50Ms; got != 5000 {
+		t.Errorf("expected overflow observations at the largest bound, got %vms", got)
+	}
+}
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestETagMatches(t *testing.T) {
+	etag := StatsETag(Totals{Received: 3, Allowed: 2, Denied: 1}, 3)
+	if etag != `W/"3-2-1-3"` {
+		t.Fatalf("unexpected etag %s", etag)
+	}
+	for header, want := range map[string]bool{
+		etag:               true,
+		`"3-2-1-3"`:        true,
+		`"x", W/"3-2-1-3"`: true,
+		"*":                true,
+		`W/"3-2-1-4"`:      false,
+		"":                 false,
+	} {
+		if got := ETagMatches(header, etag); got != want {
+			t.Errorf("ETagMatches(%q): expected %v, got %v", header, want, got)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	if got := Ratio(1, 4); got != 0.25 {
+		t.Errorf("expected 0.25, got %v", got)
+	}
+	if got := Ratio(0, 0); got != 0 {
+		t.Errorf("expected 0 for an empty total, got %v", got)
+	}
+}
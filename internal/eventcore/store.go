@@ -0,0 +1,146 @@
+package eventcore
+
+import (
+	"context"
+	"time"
+)
+
+// Store persists usage events of type E for the query API.
+//
+// Every stored event is assigned a sequence number, starting at 1, that
+// increases with insertion order and is never reused. List cursors are
+// built from these numbers.
+type Store[E any] interface {
+	// Append stores a batch of events in order.
+	Append(ctx context.Context, batch []E) error
+	// Scan calls fn for stored events newest-first until fn returns false.
+	// When before is > 0 and still refers to a stored event, the scan starts
+	// with the event immediately older than it; otherwise it starts from the
+	// newest event. fn must not call back into the store.
+	Scan(ctx context.Context, before int64, fn func(seq int64, ev E) bool) error
+	// Len returns the number of stored events.
+	Len(ctx context.Context) (int, error)
+	// Prune removes events whose timestamp is older than cutoff and returns
+	// how many were removed. Events with unparseable timestamps are kept.
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+	// Delete removes the events match returns true for and returns how
+	// many were removed. match must not call back into the store.
+	Delete(ctx context.Context, match func(ev E) bool) (int, error)
+	// Clear removes all stored events.
+	Clear(ctx context.Context) error
+	Close() error
+}
+
+// Flusher is implemented by stores that buffer writes, so that shutdown can
+// wait for every accepted event to be persisted.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Dropper is implemented by stores that discard their oldest events to
+// stay within a cap.
+type Dropper interface {
+	// Dropped returns how many events have been discarded since the store
+	// was opened.
+	Dropped() int64
+}
+
+// TenantScanner is implemented by stores that keep each tenant's events
+// apart, so that a scan for one tenant can skip the others.
+type TenantScanner[E any] interface {
+	// ScanTenant is Scan limited to the events of one tenant.
+	ScanTenant(ctx context.Context, tenant string, before int64, fn func(seq int64, ev E) bool) error
+}
+
+// Sizer is implemented by stores that keep the total Fields.Size of their
+// events up to date, or can sum it without reading the events back, so
+// the stats needn't scan the store to report it.
+type Sizer interface {
+	// ApproxBytes returns the total size of the stored events.
+	ApproxBytes(ctx context.Context) (int64, error)
+}
+
+// Counter is implemented by stores that keep the stats counters next to
+// the events, so that every replica sharing the store reports the same
+// ones. Core then counts through the store instead of in memory, and the
+// store's Clear resets the counters and its drop count along with the
+// events.
+type Counter[E any] interface {
+	// AppendCounted is Append that also adds t to the counters, in the
+	// same write. t counts every published event, even when only a sample
+	// of them is in batch, so it is added even if batch is empty.
+	AppendCounted(ctx context.Context, batch []E, t Tally) error
+	// DeleteCounted is Delete that also takes the removed events off the
+	// counters, without letting them drop below zero.
+	DeleteCounted(ctx context.Context, match func(ev E) bool) (int, error)
+	// Counts returns the counters.
+	Counts(ctx context.Context) (Tally, error)
+	// ResetCounts zeroes the counters and the drop count without touching
+	// the stored events.
+	ResetCounts(ctx context.Context) error
+}
+
+// Wrapper is implemented by stores that wrap another, such as a write
+// queue in front of the store that keeps the events. The optional
+// interfaces above are looked up on the wrapped store, except for
+// Counter, which the wrapper must pass on itself.
+type Wrapper[E any] interface {
+	Unwrap() Store[E]
+}
+
+// unwrap returns the store st wraps, or st itself.
+func unwrap[E any](st Store[E]) Store[E] {
+	if w, ok := st.(Wrapper[E]); ok {
+		return w.Unwrap()
+	}
+	return st
+}
+
+// TenantScannerOf returns st, or the store it wraps, if it is a
+// TenantScanner, and nil otherwise.
+func TenantScannerOf[E any](st Store[E]) TenantScanner[E] {
+	ts, _ := unwrap(st).(TenantScanner[E])
+	return ts
+}
+
+// counterOf returns st if it keeps the counters, which takes the store it
+// wraps, if any, being a Counter, and nil otherwise.
+func counterOf[E any](st Store[E]) Counter[E] {
+	if _, ok := unwrap(st).(Counter[E]); !ok {
+		return nil
+	}
+	c, _ := st.(Counter[E])
+	return c
+}
+
+// ApproxBytes returns the total Fields.Size of the events in st, asking
+// st, or the store it wraps, when it is a Sizer and scanning every event
+// otherwise.
+func ApproxBytes[E any](ctx context.Context, st Store[E], fields Fields[E]) (int64, error) {
+	if sz, ok := unwrap(st).(Sizer); ok {
+		return sz.ApproxBytes(ctx)
+	}
+	var total int64
+	err := st.Scan(ctx, 0, func(_ int64, ev E) bool {
+		total += fields.size(ev)
+		return true
+	})
+	return total, err
+}
+
+// DroppedEvents returns how many events st, or the store it wraps, has
+// discarded to stay within its cap; zero for stores without one.
+func DroppedEvents[E any](st Store[E]) int64 {
+	if d, ok := unwrap(st).(Dropper); ok {
+		return d.Dropped()
+	}
+	return 0
+}
+
+// Expired reports whether an event timestamp is older than cutoff. Events
+// whose timestamp can't be parsed are never considered expired, so they
+// are only removed by the count cap or a clear.
+func Expired(timestamp string, cutoff time.Time) bool {
+	ts, err := time.Parse(time.RFC3339, timestamp)
+	return err == nil && ts.Before(cutoff)
+}
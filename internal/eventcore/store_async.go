@@ -0,0 +1,219 @@
+package eventcore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// asyncMaxMerge caps how many events the writer of an asyncStore merges
+// into a single Append.
+const asyncMaxMerge = 5000
+
+// ErrQueueFull is returned by the Append of a store from NewAsyncStore when
+// its queue has no room for another batch.
+var ErrQueueFull = errors.New("event queue is full")
+
+// asyncStore queues appended batches and writes them to the underlying
+// store from a single goroutine, merging whatever has queued up meanwhile
+// into one Append. Publishers then don't contend for the store's write
+// lock, at the cost of events showing up in queries a little later than
+// Append returns.
+//
+// Append never blocks: it fails with ErrQueueFull instead. Flush waits for
+// every batch queued so far to be written, and Close drains the queue
+// before closing the underlying store.
+type asyncStore[E any] struct {
+	Store[E]
+	logger *slog.Logger
+	queue  chan asyncItem[E]
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// asyncItem is either a batch to write, with its tally when counted is
+// set, or, when flushed is set, a marker closed once everything queued
+// before it has been written.
+type asyncItem[E any] struct {
+	batch   []E
+	tally   Tally
+	counted bool
+	flushed chan struct{}
+}
+
+// NewAsyncStore wraps st with a queue of up to size batches.
+func NewAsyncStore[E any](logger *slog.Logger, st Store[E], size int) Store[E] {
+	a := &asyncStore[E]{
+		Store:  st,
+		logger: logger,
+		queue:  make(chan asyncItem[E], size),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Unwrap returns the store the queue writes to.
+func (a *asyncStore[E]) Unwrap() Store[E] { return a.Store }
+
+func (a *asyncStore[E]) Append(_ context.Context, batch []E) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	return a.enqueue(asyncItem[E]{batch: batch})
+}
+
+// AppendCounted queues batch like Append, to be written along with t.
+// Like the other Counter methods, it is only called when the underlying
+// store is a Counter, and the counters catch up when the batch is
+// written.
+func (a *asyncStore[E]) AppendCounted(_ context.Context, batch []E, t Tally) error {
+	if len(batch) == 0 && t.Received == 0 {
+		return nil
+	}
+	return a.enqueue(asyncItem[E]{batch: batch, tally: t, counted: true})
+}
+
+func (a *asyncStore[E]) enqueue(item asyncItem[E]) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return errors.New("store is closed")
+	}
+	select {
+	case a.queue <- item:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Flush waits until every batch queued before the call has been written,
+// then flushes the underlying store if it buffers writes itself.
+func (a *asyncStore[E]) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return nil
+	}
+	select {
+	case a.queue <- asyncItem[E]{flushed: flushed}:
+		a.mu.RUnlock()
+	case <-ctx.Done():
+		a.mu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if f, ok := a.Store.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Clear writes out queued batches first so that events published before
+// the clear don't reappear after it.
+func (a *asyncStore[E]) Clear(ctx context.Context) error {
+	if err := a.Flush(ctx); err != nil {
+		return err
+	}
+	return a.Store.Clear(ctx)
+}
+
+// Delete, like Clear, writes out queued batches first so that the events
+// it removes include every one published before it.
+func (a *asyncStore[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	if err := a.Flush(ctx); err != nil {
+		return 0, err
+	}
+	return a.Store.Delete(ctx, match)
+}
+
+// DeleteCounted is Delete for the underlying Counter.
+func (a *asyncStore[E]) DeleteCounted(ctx context.Context, match func(E) bool) (int, error) {
+	if err := a.Flush(ctx); err != nil {
+		return 0, err
+	}
+	return a.Store.(Counter[E]).DeleteCounted(ctx, match)
+}
+
+// Counts returns the underlying store's counters, which leave out batches
+// still queued.
+func (a *asyncStore[E]) Counts(ctx context.Context) (Tally, error) {
+	return a.Store.(Counter[E]).Counts(ctx)
+}
+
+// ResetCounts, like Clear, writes out queued batches first so that events
+// published before the reset aren't counted after it.
+func (a *asyncStore[E]) ResetCounts(ctx context.Context) error {
+	if err := a.Flush(ctx); err != nil {
+		return err
+	}
+	return a.Store.(Counter[E]).ResetCounts(ctx)
+}
+
+// Close writes out queued batches and closes the underlying store.
+func (a *asyncStore[E]) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+	return a.Store.Close()
+}
+
+func (a *asyncStore[E]) run() {
+	defer close(a.done)
+	var (
+		merged  []E
+		tally   Tally
+		counted bool
+	)
+	write := func() {
+		var err error
+		switch {
+		case counted:
+			err = a.Store.(Counter[E]).AppendCounted(context.Background(), merged, tally)
+		case len(merged) > 0:
+			err = a.Store.Append(context.Background(), merged)
+		}
+		if err != nil {
+			a.logger.Error("failed to store queued events", "count", len(merged), "error", err)
+		}
+		merged, tally, counted = nil, Tally{}, false
+	}
+	for item := range a.queue {
+		if item.counted {
+			tally.Merge(item.tally)
+			counted = true
+		}
+		if merged == nil {
+			// Clipped so that merging more batches copies instead of
+			// writing into the publisher's backing array.
+			merged = slices.Clip(item.batch)
+		} else {
+			merged = append(merged, item.batch...)
+		}
+		if item.flushed != nil {
+			write()
+			close(item.flushed)
+			continue
+		}
+		// Keep merging while more batches are already waiting.
+		if len(a.queue) > 0 && len(merged) < asyncMaxMerge {
+			continue
+		}
+		write()
+	}
+	write()
+}
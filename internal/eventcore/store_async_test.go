@@ -0,0 +1,109 @@
+package eventcore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// gatedStore blocks in Append until gate is closed, signalling entered
+// first, so tests can hold the async writer mid-write.
+type gatedStore struct {
+	*MemoryStore[Event]
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedStore) Append(ctx context.Context, batch []Event) error {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.gate
+	return g.MemoryStore.Append(ctx, batch)
+}
+
+func TestAsyncStore(t *testing.T) {
+	inner := NewMemoryStore(storeFields, testMaxEvents, 0)
+	st := NewAsyncStore(slog.Default(), inner, 16)
+	defer st.Close()
+	ctx := context.Background()
+
+	st.Append(ctx, keyedEvents("a", "b"))
+	st.Append(ctx, keyedEvents("c"))
+	st.Append(ctx, nil)
+	if err := st.(Flusher).Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := scanKeys(t, st, 0)
+	if !reflect.DeepEqual(keys, []string{"c", "b", "a"}) {
+		t.Errorf("expected events in publish order, got %v", keys)
+	}
+
+	st.Append(ctx, keyedEvents("d"))
+	if err := st.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := st.Len(ctx); n != 0 {
+		t.Errorf("expected queued events to be cleared too, got %d", n)
+	}
+}
+
+func TestAsyncStore_CloseDrains(t *testing.T) {
+	inner := NewMemoryStore(storeFields, testMaxEvents, 0)
+	st := NewAsyncStore(slog.Default(), inner, 16)
+	st.Append(context.Background(), keyedEvents("a", "b", "c"))
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := inner.Len(context.Background()); n != 3 {
+		t.Errorf("expected Close to write queued events, got %d stored", n)
+	}
+	if err := st.Append(context.Background(), keyedEvents("d")); err == nil {
+		t.Error("expected Append after Close to fail")
+	}
+}
+
+func TestAsyncStore_QueueFull(t *testing.T) {
+	inner := &gatedStore{MemoryStore: NewMemoryStore(storeFields, testMaxEvents, 0), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	st := NewAsyncStore(slog.Default(), inner, 1)
+	defer st.Close()
+	ctx := context.Background()
+
+	// The writer takes the first batch and blocks; the second fills the
+	// queue.
+	st.Append(ctx, keyedEvents("a"))
+	<-inner.entered
+	if err := st.Append(ctx, keyedEvents("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Append(ctx, keyedEvents("c")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(inner.gate)
+	if err := st.(Flusher).Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := st.Len(ctx); n != 2 {
+		t.Errorf("expected the 2 queued events to be stored, got %d", n)
+	}
+}
+
+func TestAsyncStore_FlushTimeout(t *testing.T) {
+	inner := &gatedStore{MemoryStore: NewMemoryStore(storeFields, testMaxEvents, 0), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	st := NewAsyncStore(slog.Default(), inner, 1)
+	defer st.Close()
+	defer close(inner.gate)
+
+	st.Append(context.Background(), keyedEvents("a"))
+	<-inner.entered
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := st.(Flusher).Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Flush to give up with the context, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package eventcore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// openMemoryStore opens the in-memory store: a single ring capped at
+// MaxEvents, a splitStore when MaxDenied is set, or a tenantStore when
+// MaxPerTenant is.
+func openMemoryStore[E any](fields Fields[E], opts StoreOptions) (Store[E], error) {
+	if opts.MaxPerTenant > 0 {
+		if opts.MaxDenied > 0 {
+			return nil, errors.New("a per-tenant cap and a separate denied-event cap can't be combined")
+		}
+		return newTenantStore(fields, opts.MaxPerTenant), nil
+	}
+	if opts.MaxDenied > 0 {
+		return newSplitStore(fields, opts.MaxEvents, opts.MaxDenied, opts.InitialCapacity), nil
+	}
+	return NewMemoryStore(fields, opts.MaxEvents, opts.InitialCapacity), nil
+}
+
+// MemoryStore keeps the most recent capacity events in memory, in a ring
+// buffer that grows on demand up to capacity and then overwrites its oldest
+// entry on each append. A capacity <= 0 lets it grow without bound.
+type MemoryStore[E any] struct {
+	view     func(E) Event
+	size     func(E) int64
+	mu       sync.RWMutex
+	capacity int
+	// ring holds count entries ordered by seq, starting at ring[start] and
+	// wrapping around. seq only has gaps where events were pruned, or where a
+	// splitStore numbered them for its other ring.
+	ring    []memoryEntry[E]
+	start   int
+	count   int
+	lastSeq int64
+	// dropped counts the entries overwritten once the ring was full.
+	dropped int64
+	// bytes is the total size of the count entries.
+	bytes int64
+}
+
+type memoryEntry[E any] struct {
+	seq int64
+	ev  E
+}
+
+// NewMemoryStore returns a store that keeps at most capacity events, with
+// room for initialCapacity of them allocated up front, so a store expected
+// to fill quickly doesn't regrow its ring along the way. initialCapacity is
+// clamped to capacity. Zero or a negative capacity keeps every event until
+// pruned or cleared, so memory use grows with traffic.
+func NewMemoryStore[E any](fields Fields[E], capacity, initialCapacity int) *MemoryStore[E] {
+	if capacity > 0 {
+		initialCapacity = min(initialCapacity, capacity)
+	}
+	m := &MemoryStore[E]{view: fields.View, size: fields.size, capacity: capacity}
+	if initialCapacity > 0 {
+		m.ring = make([]memoryEntry[E], 0, initialCapacity)
+	}
+	return m
+}
+
+// at returns a pointer to the i-th oldest entry.
+func (m *MemoryStore[E]) at(i int) *memoryEntry[E] {
+	return &m.ring[(m.start+i)%len(m.ring)]
+}
+
+// search returns the index of the oldest entry whose seq is at least seq,
+// and whether that entry's seq is exactly seq.
+func (m *MemoryStore[E]) search(seq int64) (int, bool) {
+	i := sort.Search(m.count, func(i int) bool { return m.at(i).seq >= seq })
+	return i, i < m.count && m.at(i).seq == seq
+}
+
+func (m *MemoryStore[E]) push(e memoryEntry[E]) {
+	m.bytes += m.size(e.ev)
+	switch {
+	case m.count < len(m.ring):
+		*m.at(m.count) = e
+		m.count++
+	case m.capacity <= 0 || len(m.ring) < m.capacity:
+		// The ring only wraps once it has reached capacity, so while it is
+		// still growing its entries start at index 0.
+		m.ring = append(m.ring, e)
+		m.count++
+	default:
+		m.bytes -= m.size(m.ring[m.start].ev)
+		m.ring[m.start] = e
+		m.start = (m.start + 1) % len(m.ring)
+		m.dropped++
+	}
+}
+
+func (m *MemoryStore[E]) Append(_ context.Context, batch []E) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ev := range batch {
+		m.lastSeq++
+		m.push(memoryEntry[E]{seq: m.lastSeq, ev: ev})
+	}
+	return nil
+}
+
+// Backfill merges batch into the ring by timestamp and renumbers every
+// event. If the result exceeds capacity, the oldest events are dropped.
+func (m *MemoryStore[E]) Backfill(_ context.Context, batch []E) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := make([]E, m.count)
+	for i := range m.count {
+		existing[i] = m.at(i).ev
+	}
+	merged := mergeByTimestamp(m.view, existing, batch)
+	m.reset()
+	for _, ev := range merged {
+		m.lastSeq++
+		m.push(memoryEntry[E]{seq: m.lastSeq, ev: ev})
+	}
+	return nil
+}
+
+func (m *MemoryStore[E]) Scan(_ context.Context, before int64, fn func(int64, E) bool) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	start := m.count - 1
+	if i, ok := m.search(before); ok {
+		start = i - 1
+	}
+	for i := start; i >= 0; i-- {
+		e := m.at(i)
+		if !fn(e.seq, e.ev) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore[E]) Len(_ context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.count, nil
+}
+
+// ApproxBytes returns the total size of the stored events, which the
+// ring keeps up to date as entries come and go.
+func (m *MemoryStore[E]) ApproxBytes(context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bytes, nil
+}
+
+func (m *MemoryStore[E]) Dropped() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dropped
+}
+
+func (m *MemoryStore[E]) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	return m.Delete(ctx, func(ev E) bool { return Expired(m.view(ev).Timestamp, cutoff) })
+}
+
+func (m *MemoryStore[E]) Delete(_ context.Context, match func(E) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := 0
+	for i := range m.count {
+		e := *m.at(i)
+		if !match(e.ev) {
+			*m.at(kept) = e
+			kept++
+		} else {
+			m.bytes -= m.size(e.ev)
+		}
+	}
+	for i := kept; i < m.count; i++ {
+		*m.at(i) = memoryEntry[E]{}
+	}
+	removed := m.count - kept
+	m.count = kept
+	return removed, nil
+}
+
+func (m *MemoryStore[E]) Clear(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reset()
+	return nil
+}
+
+// reset empties the ring, keeping its sequence numbers and drop count.
+// Callers hold mu.
+func (m *MemoryStore[E]) reset() {
+	clear(m.ring)
+	m.start, m.count, m.bytes = 0, 0, 0
+}
+
+func (m *MemoryStore[E]) Close() error { return nil }
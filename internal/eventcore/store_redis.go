@@ -0,0 +1,492 @@
+package eventcore
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix starts every key the Redis store writes. Deployments
+	// sharing a server keep apart by using different databases.
+	redisKeyPrefix = "edgequota:events:"
+	// redisDialTimeout bounds the connection check when the store opens.
+	redisDialTimeout = 5 * time.Second
+	// redisCallTimeout bounds the calls made without a caller's context,
+	// such as Dropped, so a stalled server can't hang a metrics scrape.
+	redisCallTimeout = 2 * time.Second
+	// redisTxRetries is how often Delete retries a tenant whose list
+	// another replica changed while it was being rewritten.
+	redisTxRetries = 5
+)
+
+// redisStore keeps events in Redis so that every replica behind a load
+// balancer lists and counts the same ones. Each tenant's events are a list
+// of entries, newest first, capped like a tenantStore ring; a set
+// names the tenants with a list, and counters hand out sequence numbers
+// and count the events dropped by the caps, so both are shared too, as is
+// the total size of the stored events, kept for the stats. It is
+// a Counter, keeping the stats counters in a hash that is updated in the
+// same transactions as the lists.
+//
+// Scans read every list and merge them by sequence number, so their cost
+// grows with the number of stored events.
+type redisStore[E any] struct {
+	fields Fields[E]
+	client *redis.Client
+	// capacity caps each tenant's list; zero or less leaves it unbounded.
+	capacity int
+}
+
+// redisEntry is a stored event with its sequence number and Fields.Size.
+type redisEntry[E any] struct {
+	Seq   int64
+	Size  int64
+	Event E
+}
+
+// redisWireEntry is how a redisEntry is stored: JSON holding the event as
+// Fields.Encode writes it. Size comes before the event, so that
+// redisTrimScript finds it without decoding the entry; entries stored
+// before it was added have none.
+type redisWireEntry struct {
+	Seq   int64           `json:"seq"`
+	Size  int64           `json:"size"`
+	Event json.RawMessage `json:"event"`
+}
+
+func (s *redisStore[E]) encode(e redisEntry[E]) ([]byte, error) {
+	ev, err := s.fields.Encode(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(redisWireEntry{Seq: e.Seq, Size: e.Size, Event: ev})
+}
+
+func (s *redisStore[E]) decode(data string) (redisEntry[E], error) {
+	var w redisWireEntry
+	if err := json.Unmarshal([]byte(data), &w); err != nil {
+		return redisEntry[E]{}, fmt.Errorf("decode stored event: %w", err)
+	}
+	ev, err := s.fields.Decode(w.Event)
+	if err != nil {
+		return redisEntry[E]{}, fmt.Errorf("decode stored event: %w", err)
+	}
+	return redisEntry[E]{Seq: w.Seq, Size: w.Size, Event: ev}, nil
+}
+
+// openRedisStore connects to the server in a redis:// or rediss:// URL,
+// such as redis://:password@host:6379/0. MaxEvents caps each tenant's list.
+func openRedisStore[E any](fields Fields[E], spec string, opts StoreOptions) (Store[E], error) {
+	redisOpts, err := redis.ParseURL(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	client := redis.NewClient(redisOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisDialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis %s: %w", redisOpts.Addr, err)
+	}
+	return &redisStore[E]{fields: fields, client: client, capacity: opts.MaxEvents}, nil
+}
+
+func (s *redisStore[E]) key(name string) string { return redisKeyPrefix + name }
+
+func (s *redisStore[E]) tenantKey(tenant string) string { return redisKeyPrefix + "tenant:" + tenant }
+
+func (s *redisStore[E]) Append(ctx context.Context, batch []E) error {
+	return s.AppendCounted(ctx, batch, Tally{})
+}
+
+// AppendCounted pushes batch and adds t to the counters hash in one
+// transaction, so no replica reads one without the other.
+func (s *redisStore[E]) AppendCounted(ctx context.Context, batch []E, t Tally) error {
+	if len(batch) == 0 && t.Received == 0 {
+		return nil
+	}
+	entries := make(map[string][]any)
+	var bytes int64
+	if len(batch) > 0 {
+		last, err := s.client.IncrBy(ctx, s.key("seq"), int64(len(batch))).Result()
+		if err != nil {
+			return err
+		}
+		seq := last - int64(len(batch))
+		for _, ev := range batch {
+			seq++
+			size := s.fields.size(ev)
+			bytes += size
+			data, err := s.encode(redisEntry[E]{Seq: seq, Size: size, Event: ev})
+			if err != nil {
+				return err
+			}
+			tenant := s.fields.View(ev).tenant()
+			entries[tenant] = append(entries[tenant], data)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	for field, n := range countFields(t) {
+		pipe.HIncrBy(ctx, s.key("counts"), field, n)
+	}
+	pushes := make([]*redis.IntCmd, 0, len(entries))
+	for tenant, values := range entries {
+		key := s.tenantKey(tenant)
+		pushes = append(pushes, pipe.LPush(ctx, key, values...))
+		if s.capacity > 0 {
+			redisTrimScript.Eval(ctx, pipe, []string{key, s.key("bytes")}, s.capacity)
+		}
+		pipe.SAdd(ctx, s.key("tenants"), tenant)
+	}
+	if bytes > 0 {
+		pipe.IncrBy(ctx, s.key("bytes"), bytes)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if s.capacity <= 0 {
+		return nil
+	}
+	// LPUSH answers with the length before the trim.
+	var dropped int64
+	for _, push := range pushes {
+		dropped += max(push.Val()-int64(s.capacity), 0)
+	}
+	if dropped > 0 {
+		return s.client.IncrBy(ctx, s.key("dropped"), dropped).Err()
+	}
+	return nil
+}
+
+// load reads the lists of tenants, or of every tenant when none are
+// given, and returns their entries newest-first.
+func (s *redisStore[E]) load(ctx context.Context, tenants ...string) ([]redisEntry[E], error) {
+	if len(tenants) == 0 {
+		var err error
+		if tenants, err = s.client.SMembers(ctx, s.key("tenants")).Result(); err != nil {
+			return nil, err
+		}
+	}
+	pipe := s.client.Pipeline()
+	lists := make([]*redis.StringSliceCmd, len(tenants))
+	for i, tenant := range tenants {
+		lists[i] = pipe.LRange(ctx, s.tenantKey(tenant), 0, -1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	var entries []redisEntry[E]
+	for _, list := range lists {
+		for _, data := range list.Val() {
+			e, err := s.decode(data)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+	// Replicas append concurrently, so a list isn't always in sequence
+	// order.
+	slices.SortFunc(entries, func(a, b redisEntry[E]) int { return cmp.Compare(b.Seq, a.Seq) })
+	return entries, nil
+}
+
+// scanEntries calls fn for entries, sorted newest-first, as Scan does.
+func scanEntries[E any](entries []redisEntry[E], before int64, fn func(int64, E) bool) {
+	start := 0
+	if before > 0 {
+		i, found := slices.BinarySearchFunc(entries, before, func(e redisEntry[E], seq int64) int { return cmp.Compare(seq, e.Seq) })
+		if found {
+			start = i + 1
+		}
+	}
+	for _, e := range entries[start:] {
+		if !fn(e.Seq, e.Event) {
+			return
+		}
+	}
+}
+
+func (s *redisStore[E]) Scan(ctx context.Context, before int64, fn func(int64, E) bool) error {
+	entries, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	scanEntries(entries, before, fn)
+	return nil
+}
+
+// ScanTenant is Scan limited to the events of one tenant, reading only
+// that tenant's list.
+func (s *redisStore[E]) ScanTenant(ctx context.Context, tenant string, before int64, fn func(int64, E) bool) error {
+	entries, err := s.load(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	scanEntries(entries, before, fn)
+	return nil
+}
+
+func (s *redisStore[E]) Len(ctx context.Context) (int, error) {
+	tenants, err := s.client.SMembers(ctx, s.key("tenants")).Result()
+	if err != nil {
+		return 0, err
+	}
+	pipe := s.client.Pipeline()
+	lens := make([]*redis.IntCmd, len(tenants))
+	for i, tenant := range tenants {
+		lens[i] = pipe.LLen(ctx, s.tenantKey(tenant))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, l := range lens {
+		n += int(l.Val())
+	}
+	return n, nil
+}
+
+// ApproxBytes returns the total size of the stored events, across
+// replicas.
+func (s *redisStore[E]) ApproxBytes(ctx context.Context) (int64, error) {
+	n, err := s.client.Get(ctx, s.key("bytes")).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return max(n, 0), err
+}
+
+// Dropped returns the events the caps have discarded since the counters
+// were last reset or cleared, across replicas. It reports 0 when Redis
+// can't be reached.
+func (s *redisStore[E]) Dropped() int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCallTimeout)
+	defer cancel()
+	n, _ := s.client.Get(ctx, s.key("dropped")).Int64()
+	return n
+}
+
+func (s *redisStore[E]) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	return s.Delete(ctx, func(ev E) bool { return Expired(s.fields.View(ev).Timestamp, cutoff) })
+}
+
+// Delete rewrites each tenant's list without the matching events. A list
+// is rewritten in a transaction that fails if another replica changes it
+// meanwhile, and is then read again.
+func (s *redisStore[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	return s.delete(ctx, match, false)
+}
+
+// DeleteCounted is Delete that also takes the deleted events off the
+// counters hash, in the transaction that rewrites each list.
+func (s *redisStore[E]) DeleteCounted(ctx context.Context, match func(E) bool) (int, error) {
+	return s.delete(ctx, match, true)
+}
+
+func (s *redisStore[E]) delete(ctx context.Context, match func(E) bool, counted bool) (int, error) {
+	tenants, err := s.client.SMembers(ctx, s.key("tenants")).Result()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, tenant := range tenants {
+		n, err := s.deleteTenant(ctx, tenant, match, counted)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (s *redisStore[E]) deleteTenant(ctx context.Context, tenant string, match func(E) bool, counted bool) (int, error) {
+	key := s.tenantKey(tenant)
+	for range redisTxRetries {
+		n := 0
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			n = 0
+			var (
+				deleted Tally
+				bytes   int64
+			)
+			list, err := tx.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			kept := make([]any, 0, len(list))
+			for _, data := range list {
+				e, err := s.decode(data)
+				if err != nil {
+					return err
+				}
+				if match(e.Event) {
+					n++
+					bytes += e.Size
+					if counted {
+						s.fields.add(&deleted, e.Event)
+					}
+				} else {
+					kept = append(kept, data)
+				}
+			}
+			if n == 0 {
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, key)
+				if len(kept) > 0 {
+					pipe.RPush(ctx, key, kept...)
+				} else {
+					pipe.SRem(ctx, s.key("tenants"), tenant)
+				}
+				if bytes > 0 {
+					pipe.DecrBy(ctx, s.key("bytes"), bytes)
+				}
+				if args := decrArgs(deleted); len(args) > 0 {
+					redisDecrScript.Eval(ctx, pipe, []string{s.key("counts")}, args...)
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return n, err
+		}
+	}
+	return 0, fmt.Errorf("delete events of tenant %q: list kept changing", tenant)
+}
+
+// Clear deletes every tenant's list and resets the counters. The sequence
+// counter is kept, so sequence numbers are never reused.
+func (s *redisStore[E]) Clear(ctx context.Context) error {
+	tenants, err := s.client.SMembers(ctx, s.key("tenants")).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{s.key("tenants"), s.key("bytes"), s.key("counts"), s.key("dropped")}
+	for _, tenant := range tenants {
+		keys = append(keys, s.tenantKey(tenant))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Counts reads the counters hash.
+func (s *redisStore[E]) Counts(ctx context.Context) (Tally, error) {
+	fields, err := s.client.HGetAll(ctx, s.key("counts")).Result()
+	if err != nil {
+		return Tally{}, err
+	}
+	t := Tally{PerTenant: make(map[string]TenantStats), Reasons: make(map[string]int64)}
+	for field, value := range fields {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Tally{}, fmt.Errorf("read counter %q: %w", field, err)
+		}
+		kind, name, _ := strings.Cut(field, ":")
+		switch kind {
+		case "received":
+			t.Received = n
+		case "allowed":
+			t.Allowed = n
+		case "denied":
+			t.Denied = n
+		case "reason":
+			t.Reasons[name] = n
+		case "tenant_received", "tenant_allowed", "tenant_denied":
+			ts := t.PerTenant[name]
+			switch kind {
+			case "tenant_received":
+				ts.TotalReceived = n
+			case "tenant_allowed":
+				ts.TotalAllowed = n
+			default:
+				ts.TotalDenied = n
+			}
+			t.PerTenant[name] = ts
+		}
+	}
+	return t, nil
+}
+
+// ResetCounts deletes the counters hash and the drop count.
+func (s *redisStore[E]) ResetCounts(ctx context.Context) error {
+	return s.client.Del(ctx, s.key("counts"), s.key("dropped")).Err()
+}
+
+// countFields lays t out as fields of the counters hash: the totals, then
+// "tenant_<counter>:<tenant key>" and "reason:<reason>", leaving out
+// zeros.
+func countFields(t Tally) map[string]int64 {
+	fields := make(map[string]int64)
+	set := func(field string, n int64) {
+		if n != 0 {
+			fields[field] = n
+		}
+	}
+	set("received", t.Received)
+	set("allowed", t.Allowed)
+	set("denied", t.Denied)
+	for tenant, ts := range t.PerTenant {
+		set("tenant_received:"+tenant, ts.TotalReceived)
+		set("tenant_allowed:"+tenant, ts.TotalAllowed)
+		set("tenant_denied:"+tenant, ts.TotalDenied)
+	}
+	for reason, n := range t.Reasons {
+		set("reason:"+reason, n)
+	}
+	return fields
+}
+
+// decrArgs returns the arguments of redisDecrScript taking t off the
+// counters.
+func decrArgs(t Tally) []any {
+	var args []any
+	for field, n := range countFields(t) {
+		args = append(args, field, n)
+	}
+	return args
+}
+
+// redisTrimScript trims the list in KEYS[1] to its newest ARGV[1] entries,
+// taking the sizes of the ones it removes off the counter in KEYS[2].
+var redisTrimScript = redis.NewScript(`
+local removed = redis.call('LRANGE', KEYS[1], ARGV[1], -1)
+local bytes = 0
+for _, entry in ipairs(removed) do
+	bytes = bytes + tonumber(string.match(entry, '"size":(%d+)') or '0')
+end
+redis.call('LTRIM', KEYS[1], 0, ARGV[1] - 1)
+if bytes > 0 then
+	redis.call('DECRBY', KEYS[2], bytes)
+end
+return #removed
+`)
+
+// redisDecrScript subtracts each field/amount pair of ARGV from the hash
+// in KEYS[1], removing the fields that reach zero. Like the in-memory
+// counters, they never drop below it, since the deleted events may have
+// been received before the counters were last reset.
+var redisDecrScript = redis.NewScript(`
+for i = 1, #ARGV, 2 do
+	local left = tonumber(redis.call('HGET', KEYS[1], ARGV[i]) or '0') - tonumber(ARGV[i + 1])
+	if left > 0 then
+		redis.call('HSET', KEYS[1], ARGV[i], left)
+	else
+		redis.call('HDEL', KEYS[1], ARGV[i])
+	end
+end
+return 0
+`)
+
+func (s *redisStore[E]) Close() error { return s.client.Close() }
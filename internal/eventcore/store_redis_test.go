@@ -0,0 +1,163 @@
+package eventcore
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// openRedisTestStore opens a Redis store on mr that caps each tenant's
+// events at capacity.
+func openRedisTestStore(t *testing.T, mr *miniredis.Miniredis, capacity int) *redisStore[Event] {
+	t.Helper()
+	st, err := openRedisStore(storeFields, "redis://"+mr.Addr(), StoreOptions{MaxEvents: capacity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st.(*redisStore[Event])
+}
+
+func TestRedisStore_SharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a, b := openRedisTestStore(t, mr, testMaxEvents), openRedisTestStore(t, mr, testMaxEvents)
+
+	if err := a.Append(ctx, tenantEvents("a1", "b1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Append(ctx, tenantEvents("a2")); err != nil {
+		t.Fatal(err)
+	}
+	for name, st := range map[string]Store[Event]{"a": a, "b": b} {
+		keys, seqs := scanKeys(t, st, 0)
+		if !reflect.DeepEqual(keys, []string{"a2", "b1", "a1"}) || !reflect.DeepEqual(seqs, []int64{3, 2, 1}) {
+			t.Errorf("replica %s: expected both replicas' events numbered in one sequence, got %v %v", name, keys, seqs)
+		}
+	}
+
+	if err := b.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := a.Len(ctx); err != nil || n != 0 {
+		t.Errorf("expected a clear on one replica to empty the other, got Len=%d (err=%v)", n, err)
+	}
+	if err := a.Append(ctx, tenantEvents("c1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, seqs := scanKeys(t, b, 0); !reflect.DeepEqual(seqs, []int64{4}) {
+		t.Errorf("expected sequence numbers to carry on after a clear, got %v", seqs)
+	}
+}
+
+func TestRedisStore_CountersSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a := New[Event](openRedisTestStore(t, mr, 2), storeFields)
+	b := New[Event](openRedisTestStore(t, mr, 2), storeFields)
+	publish := func(c *Core[Event], batch []Event) {
+		t.Helper()
+		tally := storeFields.Tally(batch)
+		if err := c.Record(tally, func() error { return c.Append(ctx, batch, tally) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	denied := tenantEvents("a3")
+	denied[0].Allowed, denied[0].Reason = false, ptr("quota")
+	publish(a, tenantEvents("a1", "b1"))
+	publish(b, append(tenantEvents("a2"), denied...))
+	for name, c := range map[string]*Core[Event]{"a": a, "b": b} {
+		snap, err := c.Snapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Totals{Received: 4, Allowed: 3, Denied: 1}); snap.Totals != want || snap.Dropped != 1 || !reflect.DeepEqual(snap.Reasons, map[string]int64{"quota": 1}) {
+			t.Errorf("replica %s: expected both replicas' publishes counted, got %+v", name, snap)
+		}
+		if got := c.TenantStats().Tenants["tenant-a"]; got != (TenantStats{TotalReceived: 3, TotalAllowed: 2, TotalDenied: 1}) {
+			t.Errorf("replica %s: expected tenant-a's three events, got %+v", name, got)
+		}
+	}
+
+	// A delete on one replica takes the events off the counters of both.
+	if _, err := b.Delete(ctx, func(ev Event) bool { return ev.Key == "a3" || ev.Key == "b1" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.Totals(), (Totals{Received: 2, Allowed: 2}); got != want {
+		t.Errorf("expected %+v after the delete, got %+v", want, got)
+	}
+	if got := a.TenantStats(); len(got.Tenants) != 1 || len(a.DenyReasons()) != 0 {
+		t.Errorf("expected only tenant-a left, with no denials, got %+v %v", got, a.DenyReasons())
+	}
+
+	if err := a.ResetCounters(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap, _ := b.Snapshot(ctx); snap.Totals != (Totals{}) || snap.Dropped != 0 || snap.Stored != 1 {
+		t.Errorf("expected a reset on one replica to zero the other's counters, got %+v", snap)
+	}
+	publish(a, tenantEvents("c1"))
+	if err := b.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Totals(); got != (Totals{}) {
+		t.Errorf("expected a clear on one replica to zero the other's counters, got %+v", got)
+	}
+}
+
+func TestRedisStore_CountersThroughQueue(t *testing.T) {
+	ctx := context.Background()
+	st := NewAsyncStore[Event](slog.Default(), openRedisTestStore(t, miniredis.RunT(t), testMaxEvents), 10)
+	c := New(st, storeFields)
+	// Only a sample is stored, but the whole batch is counted.
+	batch := tenantEvents("a1", "a2")
+	tally := storeFields.Tally(batch)
+	if err := c.Record(tally, func() error { return c.Append(ctx, batch[:1], tally) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := c.Snapshot(ctx); err != nil || snap.Received != 2 || snap.Stored != 1 {
+		t.Errorf("expected 2 events counted and 1 stored through the queue, got %+v (err=%v)", snap, err)
+	}
+}
+
+func TestRedisStore_CapPerTenant(t *testing.T) {
+	ctx := context.Background()
+	st := openRedisTestStore(t, miniredis.RunT(t), 2)
+	if err := st.Append(ctx, tenantEvents("a1", "a2", "a3", "b1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, _ := scanKeys(t, st, 0); !reflect.DeepEqual(keys, []string{"b1", "a3", "a2"}) {
+		t.Errorf("expected tenant-a's oldest event evicted and tenant-b's kept, got %v", keys)
+	}
+	if n := st.Dropped(); n != 1 {
+		t.Errorf("expected 1 event dropped, got %d", n)
+	}
+	checkApproxBytes(t, st)
+	var keys []string
+	err := st.ScanTenant(ctx, "tenant-a", 0, func(_ int64, ev Event) bool {
+		keys = append(keys, ev.Key)
+		return true
+	})
+	if err != nil || !reflect.DeepEqual(keys, []string{"a3", "a2"}) {
+		t.Errorf("expected tenant-a's events only, got %v (err=%v)", keys, err)
+	}
+}
+
+func TestOpenRedisStore_Errors(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	for _, spec := range []string{"redis://" + addr, "redis://localhost:6379/notadb"} {
+		if _, err := openRedisStore(storeFields, spec, StoreOptions{MaxEvents: testMaxEvents}); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package eventcore
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// StoreOptions are the store settings from the command line, passed to
+// every StoreFactory. Backends that don't keep a capped ring ignore them.
+type StoreOptions struct {
+	// MaxEvents caps the store; zero or a negative value leaves it
+	// unbounded.
+	MaxEvents int
+	// MaxDenied, when positive, gives denied events a cap of their own,
+	// leaving MaxEvents to allowed ones.
+	MaxDenied int
+	// MaxPerTenant, when positive, caps each tenant's events separately
+	// instead, and MaxEvents no longer applies.
+	MaxPerTenant int
+	// InitialCapacity is how many events to preallocate room for.
+	InitialCapacity int
+}
+
+// StoreFactory opens the backend for a -store spec, which is passed whole,
+// scheme included.
+type StoreFactory[E any] func(spec string, opts StoreOptions) (Store[E], error)
+
+// Registry maps -store schemes to the backends that open them.
+type Registry[E any] struct {
+	mu        sync.RWMutex
+	factories map[string]StoreFactory[E]
+}
+
+// NewRegistry returns a registry with the built-in backends registered:
+// memory, sqlite, and redis and rediss.
+func NewRegistry[E any](fields Fields[E]) *Registry[E] {
+	r := &Registry[E]{factories: make(map[string]StoreFactory[E])}
+	r.Register("memory", func(_ string, opts StoreOptions) (Store[E], error) {
+		return openMemoryStore(fields, opts)
+	})
+	r.Register("sqlite", func(spec string, _ StoreOptions) (Store[E], error) {
+		return openSQLite(fields, spec)
+	})
+	openRedis := func(spec string, opts StoreOptions) (Store[E], error) {
+		return openRedisStore(fields, spec, opts)
+	}
+	r.Register("redis", openRedis)
+	r.Register("rediss", openRedis)
+	return r
+}
+
+// Register makes a backend available as -store=<scheme>:... (or just
+// -store=<scheme>). Like database/sql.Register, it panics if factory is nil
+// or the scheme is already taken.
+func (r *Registry[E]) Register(scheme string, factory StoreFactory[E]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if factory == nil {
+		panic("eventcore: nil store factory for " + scheme)
+	}
+	if _, dup := r.factories[scheme]; dup {
+		panic("eventcore: store " + scheme + " registered twice")
+	}
+	r.factories[scheme] = factory
+}
+
+// Schemes returns the registered schemes, sorted.
+func (r *Registry[E]) Schemes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemes := make([]string, 0, len(r.factories))
+	for scheme := range r.factories {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	return schemes
+}
+
+// Open opens the backend registered for spec's scheme, the part before
+// the first colon; an empty spec is "memory". Only the memory store
+// supports MaxDenied and MaxPerTenant, so they are an error with any other
+// backend.
+func (r *Registry[E]) Open(spec string, opts StoreOptions) (Store[E], error) {
+	if spec == "" {
+		spec = "memory"
+	}
+	scheme, _, _ := strings.Cut(spec, ":")
+	r.mu.RLock()
+	factory := r.factories[scheme]
+	r.mu.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("unknown store %q (want one of %s)", spec, strings.Join(r.Schemes(), ", "))
+	}
+	if scheme != "memory" {
+		if opts.MaxDenied > 0 {
+			return nil, errors.New("a separate denied-event cap is only supported by the memory store")
+		}
+		if opts.MaxPerTenant > 0 {
+			return nil, errors.New("a per-tenant cap is only supported by the memory store")
+		}
+	}
+	return factory(spec, opts)
+}
@@ -0,0 +1,56 @@
+package eventcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeStore is a backend registered by TestRegistry.
+type fakeStore struct {
+	*MemoryStore[Event]
+	spec string
+	opts StoreOptions
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(storeFields)
+	reg.Register("fake", func(spec string, opts StoreOptions) (Store[Event], error) {
+		return &fakeStore{MemoryStore: NewMemoryStore(storeFields, opts.MaxEvents, 0), spec: spec, opts: opts}, nil
+	})
+
+	st, err := reg.Open("fake://events.example:6379/0", StoreOptions{MaxEvents: 50, InitialCapacity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, ok := st.(*fakeStore)
+	if !ok {
+		t.Fatalf("expected the registered backend, got %T", st)
+	}
+	if want := (StoreOptions{MaxEvents: 50, InitialCapacity: 10}); fake.spec != "fake://events.example:6379/0" || fake.opts != want {
+		t.Errorf("expected the whole spec and %+v, got %q and %+v", want, fake.spec, fake.opts)
+	}
+	if _, err := reg.Open("fake:", StoreOptions{MaxEvents: 50, MaxPerTenant: 100}); err == nil || !strings.Contains(err.Error(), "per-tenant") {
+		t.Errorf("expected a per-tenant cap to be refused for a non-memory backend, got %v", err)
+	}
+
+	_, err = reg.Open("nosuch://localhost", StoreOptions{})
+	if err == nil || !strings.Contains(err.Error(), "fake, memory, redis, rediss, sqlite") {
+		t.Errorf("expected the unknown-store error to list the registered backends, got %v", err)
+	}
+
+	for name, register := range map[string]func(){
+		"duplicate": func() {
+			reg.Register("memory", func(string, StoreOptions) (Store[Event], error) { return nil, nil })
+		},
+		"nil": func() { reg.Register("other", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected Register to panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
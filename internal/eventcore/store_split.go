@@ -0,0 +1,162 @@
+package eventcore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// splitStore keeps allowed and denied events in two memory rings with
+// separate caps, so a flood of allowed traffic can't evict the rarer
+// denials. Sequence numbers are shared between the rings and Scan merges
+// them, so to callers it behaves like a single store.
+type splitStore[E any] struct {
+	view func(E) Event
+	// mu orders appends across both rings; each ring's own lock still
+	// guards its contents.
+	mu      sync.Mutex
+	allowed *MemoryStore[E]
+	denied  *MemoryStore[E]
+	lastSeq int64
+}
+
+// newSplitStore returns a store keeping at most allowedCap allowed and
+// deniedCap denied events. Each ring preallocates room for
+// initialCapacity events, clamped to its cap.
+func newSplitStore[E any](fields Fields[E], allowedCap, deniedCap, initialCapacity int) *splitStore[E] {
+	return &splitStore[E]{
+		view:    fields.View,
+		allowed: NewMemoryStore(fields, allowedCap, initialCapacity),
+		denied:  NewMemoryStore(fields, deniedCap, initialCapacity),
+	}
+}
+
+// ring returns the ring holding events with the given decision.
+func (s *splitStore[E]) ring(allowed bool) *MemoryStore[E] {
+	if allowed {
+		return s.allowed
+	}
+	return s.denied
+}
+
+func (s *splitStore[E]) Append(_ context.Context, batch []E) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed.mu.Lock()
+	defer s.allowed.mu.Unlock()
+	s.denied.mu.Lock()
+	defer s.denied.mu.Unlock()
+	for _, ev := range batch {
+		s.lastSeq++
+		ring := s.ring(s.view(ev).Allowed)
+		ring.lastSeq = s.lastSeq
+		ring.push(memoryEntry[E]{seq: s.lastSeq, ev: ev})
+	}
+	return nil
+}
+
+// Backfill merges batch into the events of both rings by timestamp and
+// renumbers every event, routing each back to its decision's ring.
+func (s *splitStore[E]) Backfill(_ context.Context, batch []E) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed.mu.Lock()
+	defer s.allowed.mu.Unlock()
+	s.denied.mu.Lock()
+	defer s.denied.mu.Unlock()
+	entries := make([]memoryEntry[E], 0, s.allowed.count+s.denied.count)
+	for _, ring := range []*MemoryStore[E]{s.allowed, s.denied} {
+		for i := range ring.count {
+			entries = append(entries, *ring.at(i))
+		}
+		ring.reset()
+	}
+	slices.SortFunc(entries, func(a, b memoryEntry[E]) int { return cmp.Compare(a.seq, b.seq) })
+	existing := make([]E, len(entries))
+	for i, e := range entries {
+		existing[i] = e.ev
+	}
+	for _, ev := range mergeByTimestamp(s.view, existing, batch) {
+		s.lastSeq++
+		ring := s.ring(s.view(ev).Allowed)
+		ring.lastSeq = s.lastSeq
+		ring.push(memoryEntry[E]{seq: s.lastSeq, ev: ev})
+	}
+	return nil
+}
+
+// Scan merges both rings newest-first. A before found in either ring
+// positions both of them, so cursors work across the merged order.
+func (s *splitStore[E]) Scan(_ context.Context, before int64, fn func(int64, E) bool) error {
+	s.allowed.mu.RLock()
+	defer s.allowed.mu.RUnlock()
+	s.denied.mu.RLock()
+	defer s.denied.mu.RUnlock()
+	a, d := s.allowed.count-1, s.denied.count-1
+	ai, aFound := s.allowed.search(before)
+	di, dFound := s.denied.search(before)
+	if aFound || dFound {
+		a, d = ai-1, di-1
+	}
+	for a >= 0 || d >= 0 {
+		var e *memoryEntry[E]
+		if d < 0 || (a >= 0 && s.allowed.at(a).seq > s.denied.at(d).seq) {
+			e = s.allowed.at(a)
+			a--
+		} else {
+			e = s.denied.at(d)
+			d--
+		}
+		if !fn(e.seq, e.ev) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *splitStore[E]) Len(ctx context.Context) (int, error) {
+	a, _ := s.allowed.Len(ctx)
+	d, _ := s.denied.Len(ctx)
+	return a + d, nil
+}
+
+func (s *splitStore[E]) Dropped() int64 {
+	return s.allowed.Dropped() + s.denied.Dropped()
+}
+
+func (s *splitStore[E]) ApproxBytes(ctx context.Context) (int64, error) {
+	a, _ := s.allowed.ApproxBytes(ctx)
+	d, _ := s.denied.ApproxBytes(ctx)
+	return a + d, nil
+}
+
+func (s *splitStore[E]) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	a, _ := s.allowed.Prune(ctx, cutoff)
+	d, _ := s.denied.Prune(ctx, cutoff)
+	return a + d, nil
+}
+
+func (s *splitStore[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	a, _ := s.allowed.Delete(ctx, match)
+	d, _ := s.denied.Delete(ctx, match)
+	return a + d, nil
+}
+
+func (s *splitStore[E]) Clear(ctx context.Context) error {
+	_ = s.allowed.Clear(ctx)
+	return s.denied.Clear(ctx)
+}
+
+func (s *splitStore[E]) Close() error { return nil }
+
+// splitStoreOf returns the split store st is, or wraps in an asyncStore,
+// and nil for any other store.
+func splitStoreOf[E any](st Store[E]) *splitStore[E] {
+	if a, ok := st.(*asyncStore[E]); ok {
+		st = a.Store
+	}
+	split, _ := st.(*splitStore[E])
+	return split
+}
@@ -0,0 +1,77 @@
+package eventcore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// decisionEvents returns events keyed by keys, allowed unless the key
+// starts with "d".
+func decisionEvents(keys ...string) []Event {
+	events := keyedEvents(keys...)
+	for i := range events {
+		events[i].Allowed = events[i].Key[0] != 'd'
+	}
+	return events
+}
+
+func TestSplitStore_DenialsSurviveFlood(t *testing.T) {
+	ctx := context.Background()
+	flood := make([]Event, 1000)
+	for i := range flood {
+		flood[i] = Event{Key: "a", Allowed: true}
+	}
+
+	shared := NewMemoryStore(storeFields, 10, 0)
+	split := newSplitStore(storeFields, 10, 5, 0)
+	for _, st := range []Store[Event]{shared, split} {
+		if err := st.Append(ctx, decisionEvents("d1", "d2", "d3")); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Append(ctx, flood); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if keys, _ := scanKeys(t, shared, 0); reflect.DeepEqual(keys[len(keys)-3:], []string{"d3", "d2", "d1"}) {
+		t.Fatal("expected the shared ring to evict the denials")
+	}
+	keys, _ := scanKeys(t, split, 0)
+	if len(keys) != 13 {
+		t.Fatalf("expected 10 allowed and 3 denied events, got %d", len(keys))
+	}
+	if !reflect.DeepEqual(keys[10:], []string{"d3", "d2", "d1"}) {
+		t.Errorf("expected the denials to survive, oldest last, got %v", keys[10:])
+	}
+
+	if err := split.Append(ctx, decisionEvents("d4", "d5", "d6")); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ = scanKeys(t, split.denied, 0)
+	if !reflect.DeepEqual(keys, []string{"d6", "d5", "d4", "d3", "d2"}) {
+		t.Errorf("expected the denied ring to keep its own 5 newest, got %v", keys)
+	}
+}
+
+func TestSplitStore_MergedScan(t *testing.T) {
+	st := newSplitStore(storeFields, testMaxEvents, testMaxEvents, 0)
+	if err := st.Append(context.Background(), decisionEvents("a1", "d1", "d2", "a2", "a3", "d3")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, seqs := scanKeys(t, st, 0)
+	if !reflect.DeepEqual(keys, []string{"d3", "a3", "a2", "d2", "d1", "a1"}) {
+		t.Fatalf("expected both rings merged newest-first, got %v", keys)
+	}
+	if !reflect.DeepEqual(seqs, []int64{6, 5, 4, 3, 2, 1}) {
+		t.Errorf("expected sequence numbers shared across rings, got %v", seqs)
+	}
+	// A position in either ring resumes the merged scan from there.
+	if keys, _ := scanKeys(t, st, 4); !reflect.DeepEqual(keys, []string{"d2", "d1", "a1"}) {
+		t.Errorf("expected events older than a2, got %v", keys)
+	}
+	if keys, _ := scanKeys(t, st, 3); !reflect.DeepEqual(keys, []string{"d1", "a1"}) {
+		t.Errorf("expected events older than d2, got %v", keys)
+	}
+}
@@ -0,0 +1,313 @@
+package eventcore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	key         TEXT    NOT NULL,
+	tenant_key  TEXT,
+	method      TEXT    NOT NULL,
+	path        TEXT    NOT NULL,
+	allowed     INTEGER NOT NULL,
+	remaining   INTEGER NOT NULL,
+	"limit"     INTEGER NOT NULL,
+	timestamp   TEXT    NOT NULL,
+	status_code INTEGER NOT NULL,
+	request_id  TEXT,
+	reason      TEXT,
+	size        INTEGER NOT NULL DEFAULT 0
+)`
+
+const sqliteColumns = `key, tenant_key, method, path, allowed, remaining, "limit", timestamp, status_code, request_id, reason`
+
+// sqliteStore persists events in a SQLite database, one column per field.
+// Unlike MemoryStore it is not capped; the table grows until cleared.
+type sqliteStore[E any] struct {
+	fields Fields[E]
+	db     *sql.DB
+}
+
+// openSQLite opens the store for a sqlite:<path> spec.
+func openSQLite[E any](fields Fields[E], spec string) (Store[E], error) {
+	_, path, _ := strings.Cut(spec, ":")
+	if path == "" {
+		return nil, errors.New("the sqlite store needs a path (sqlite:<path>)")
+	}
+	st, err := openSQLiteStore(fields, path)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func openSQLiteStore[E any](fields Fields[E], path string) (*sqliteStore[E], error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+	// SQLite serializes writers anyway; a single connection avoids
+	// SQLITE_BUSY and keeps ":memory:" databases on one handle.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create events table: %w", err)
+	}
+	addedSize, err := migrateSQLite(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate events table: %w", err)
+	}
+	st := &sqliteStore[E]{fields: fields, db: db}
+	if addedSize {
+		if err := st.fillSizes(context.Background()); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate events table: %w", err)
+		}
+	}
+	return st, nil
+}
+
+// migrateSQLite adds the reason and size columns to tables created before
+// they were part of the schema, and reports whether it added size.
+func migrateSQLite(db *sql.DB) (addedSize bool, err error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('events')`)
+	if err != nil {
+		return false, err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return false, err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if !columns["reason"] {
+		if _, err := db.Exec(`ALTER TABLE events ADD COLUMN reason TEXT`); err != nil {
+			return false, err
+		}
+	}
+	if columns["size"] {
+		return false, nil
+	}
+	_, err = db.Exec(`ALTER TABLE events ADD COLUMN size INTEGER NOT NULL DEFAULT 0`)
+	return err == nil, err
+}
+
+// fillSizes sets the size column of the events stored before it existed.
+func (s *sqliteStore[E]) fillSizes(ctx context.Context) error {
+	sizes := make(map[int64]int64)
+	err := s.Scan(ctx, 0, func(seq int64, ev E) bool {
+		sizes[seq] = s.fields.size(ev)
+		return true
+	})
+	if err != nil || len(sizes) == 0 {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `UPDATE events SET size = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, size := range sizes {
+		if _, err := stmt.ExecContext(ctx, size, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore[E]) Append(ctx context.Context, batch []E) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO events (`+sqliteColumns+`, size) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range batch {
+		ev := s.fields.View(e)
+		if _, err := stmt.ExecContext(ctx,
+			ev.Key, ev.TenantKey, ev.Method, ev.Path, ev.Allowed, ev.Remaining,
+			ev.Limit, ev.Timestamp, ev.StatusCode, ev.RequestID, ev.Reason, s.fields.size(e),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore[E]) Scan(ctx context.Context, before int64, fn func(int64, E) bool) error {
+	if before > 0 {
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE id = ?)`, before).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			before = 0
+		}
+	}
+
+	query := `SELECT id, ` + sqliteColumns + ` FROM events`
+	args := []any{}
+	if before > 0 {
+		query += ` WHERE id < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			seq                          int64
+			ev                           Event
+			tenantKey, requestID, reason sql.NullString
+		)
+		if err := rows.Scan(
+			&seq, &ev.Key, &tenantKey, &ev.Method, &ev.Path, &ev.Allowed, &ev.Remaining,
+			&ev.Limit, &ev.Timestamp, &ev.StatusCode, &requestID, &reason,
+		); err != nil {
+			return err
+		}
+		ev.TenantKey = nullStringPtr(tenantKey)
+		ev.RequestID = nullStringPtr(requestID)
+		ev.Reason = nullStringPtr(reason)
+		if !fn(seq, s.fields.Make(ev)) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore[E]) Len(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`).Scan(&n)
+	return n, err
+}
+
+// ApproxBytes sums the size column, so the events aren't read back.
+func (s *sqliteStore[E]) ApproxBytes(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM events`).Scan(&n)
+	return n, err
+}
+
+func (s *sqliteStore[E]) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	// Timestamps are stored as text that may use any RFC 3339 offset, so
+	// they are compared in Go rather than in SQL.
+	rows, err := s.db.QueryContext(ctx, `SELECT id, timestamp FROM events`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var (
+			id int64
+			ts string
+		)
+		if err := rows.Scan(&id, &ts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if Expired(ts, cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	return s.deleteIDs(ctx, ids)
+}
+
+func (s *sqliteStore[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	// The predicate is Go code, so matching rows are found with a scan and
+	// deleted afterwards, once the scan's rows are closed.
+	var ids []int64
+	err := s.Scan(ctx, 0, func(seq int64, ev E) bool {
+		if match(ev) {
+			ids = append(ids, seq)
+		}
+		return true
+	})
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return s.deleteIDs(ctx, ids)
+}
+
+// deleteIDs deletes the events with the given ids in one transaction.
+func (s *sqliteStore[E]) deleteIDs(ctx context.Context, ids []int64) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `DELETE FROM events WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, id := range ids {
+		if _, err := stmt.ExecContext(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (s *sqliteStore[E]) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM events`)
+	return err
+}
+
+func (s *sqliteStore[E]) Close() error {
+	return s.db.Close()
+}
+
+func nullStringPtr(ns sql.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	return &ns.String
+}
@@ -0,0 +1,230 @@
+package eventcore
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// tenantStore keeps each tenant's events in a memory ring of its own with
+// the same cap, so a noisy tenant only evicts its own events. Events
+// without a tenant key share the ring of the empty key. Like splitStore,
+// sequence numbers are shared between the rings and Scan merges them, so
+// to callers it behaves like a single store.
+//
+// Memory use is bounded by the cap times the number of distinct tenants.
+type tenantStore[E any] struct {
+	fields Fields[E]
+	// mu guards rings and their contents. The rings' own locks are only
+	// taken through their methods, always with mu held.
+	mu       sync.RWMutex
+	capacity int
+	rings    map[string]*MemoryStore[E]
+	lastSeq  int64
+	// retired counts the events dropped by rings since discarded for
+	// having emptied, so Dropped never goes backwards.
+	retired int64
+}
+
+// newTenantStore returns a store keeping at most capacity events for each
+// tenant key.
+func newTenantStore[E any](fields Fields[E], capacity int) *tenantStore[E] {
+	return &tenantStore[E]{fields: fields, capacity: capacity, rings: make(map[string]*MemoryStore[E])}
+}
+
+// ScanTenant is Scan limited to the events of one tenant, reading only
+// that tenant's ring.
+func (s *tenantStore[E]) ScanTenant(ctx context.Context, tenant string, before int64, fn func(int64, E) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ring := s.rings[tenant]
+	if ring == nil {
+		return nil
+	}
+	return ring.Scan(ctx, before, fn)
+}
+
+// push stores ev under the next sequence number. Callers hold mu.
+func (s *tenantStore[E]) push(ev E) {
+	tenant := s.fields.View(ev).tenant()
+	ring := s.rings[tenant]
+	if ring == nil {
+		ring = NewMemoryStore(s.fields, s.capacity, 0)
+		s.rings[tenant] = ring
+	}
+	s.lastSeq++
+	ring.lastSeq = s.lastSeq
+	ring.push(memoryEntry[E]{seq: s.lastSeq, ev: ev})
+}
+
+func (s *tenantStore[E]) Append(_ context.Context, batch []E) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range batch {
+		s.push(ev)
+	}
+	return nil
+}
+
+// Backfill merges batch into the events of every ring by timestamp and
+// renumbers every event, routing each back to its tenant's ring.
+func (s *tenantStore[E]) Backfill(_ context.Context, batch []E) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []memoryEntry[E]
+	for _, ring := range s.rings {
+		for i := range ring.count {
+			entries = append(entries, *ring.at(i))
+		}
+	}
+	slices.SortFunc(entries, func(a, b memoryEntry[E]) int { return cmp.Compare(a.seq, b.seq) })
+	existing := make([]E, len(entries))
+	for i, e := range entries {
+		existing[i] = e.ev
+	}
+	for _, ring := range s.rings {
+		ring.reset()
+	}
+	for _, ev := range mergeByTimestamp(s.fields.View, existing, batch) {
+		s.push(ev)
+	}
+	return nil
+}
+
+// ringCursors is a max-heap of positions in the rings, ordered by the seq
+// of the entry each one points at.
+type ringCursors[E any] []ringCursor[E]
+
+type ringCursor[E any] struct {
+	ring *MemoryStore[E]
+	i    int
+}
+
+func (h ringCursors[E]) Len() int { return len(h) }
+func (h ringCursors[E]) Less(i, j int) bool {
+	return h[i].ring.at(h[i].i).seq > h[j].ring.at(h[j].i).seq
+}
+func (h ringCursors[E]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *ringCursors[E]) Push(x any)   { *h = append(*h, x.(ringCursor[E])) }
+func (h *ringCursors[E]) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// Scan merges the rings newest-first. A before found in any ring positions
+// all of them, so cursors work across the merged order.
+func (s *tenantStore[E]) Scan(_ context.Context, before int64, fn func(int64, E) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := false
+	for _, ring := range s.rings {
+		if _, ok := ring.search(before); ok {
+			found = true
+			break
+		}
+	}
+	cursors := make(ringCursors[E], 0, len(s.rings))
+	for _, ring := range s.rings {
+		i := ring.count - 1
+		if found {
+			i, _ = ring.search(before)
+			i--
+		}
+		if i >= 0 {
+			cursors = append(cursors, ringCursor[E]{ring, i})
+		}
+	}
+	heap.Init(&cursors)
+	for len(cursors) > 0 {
+		c := &cursors[0]
+		e := c.ring.at(c.i)
+		if !fn(e.seq, e.ev) {
+			break
+		}
+		if c.i--; c.i < 0 {
+			heap.Pop(&cursors)
+		} else {
+			heap.Fix(&cursors, 0)
+		}
+	}
+	return nil
+}
+
+func (s *tenantStore[E]) Len(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, ring := range s.rings {
+		c, _ := ring.Len(ctx)
+		n += c
+	}
+	return n, nil
+}
+
+func (s *tenantStore[E]) Dropped() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dropped()
+}
+
+// dropped sums the drop counts of the rings, current and retired. Callers
+// hold mu.
+func (s *tenantStore[E]) dropped() int64 {
+	n := s.retired
+	for _, ring := range s.rings {
+		n += ring.Dropped()
+	}
+	return n
+}
+
+func (s *tenantStore[E]) ApproxBytes(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int64
+	for _, ring := range s.rings {
+		b, _ := ring.ApproxBytes(ctx)
+		n += b
+	}
+	return n, nil
+}
+
+func (s *tenantStore[E]) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	return s.Delete(ctx, func(ev E) bool { return Expired(s.fields.View(ev).Timestamp, cutoff) })
+}
+
+func (s *tenantStore[E]) Delete(ctx context.Context, match func(E) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for tenant, ring := range s.rings {
+		d, _ := ring.Delete(ctx, match)
+		n += d
+		if ring.count == 0 {
+			s.retire(tenant)
+		}
+	}
+	return n, nil
+}
+
+func (s *tenantStore[E]) Clear(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tenant := range s.rings {
+		s.retire(tenant)
+	}
+	return nil
+}
+
+// retire discards the tenant's ring, so tenants that stop sending don't
+// keep one forever. Callers hold mu.
+func (s *tenantStore[E]) retire(tenant string) {
+	s.retired += s.rings[tenant].dropped
+	delete(s.rings, tenant)
+}
+
+func (s *tenantStore[E]) Close() error { return nil }
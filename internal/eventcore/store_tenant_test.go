@@ -0,0 +1,78 @@
+package eventcore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// tenantEvents returns events keyed by keys, each with the tenant key
+// "tenant-" followed by the key's first letter.
+func tenantEvents(keys ...string) []Event {
+	events := keyedEvents(keys...)
+	for i := range events {
+		events[i].TenantKey = ptr("tenant-" + events[i].Key[:1])
+	}
+	return events
+}
+
+func TestTenantStore_NoCrossTenantEviction(t *testing.T) {
+	ctx := context.Background()
+	flood := make([]Event, 1000)
+	for i := range flood {
+		flood[i] = tenantEvents("a")[0]
+	}
+
+	shared := NewMemoryStore(storeFields, 10, 0)
+	tenants := newTenantStore(storeFields, 10)
+	for _, st := range []Store[Event]{shared, tenants} {
+		if err := st.Append(ctx, tenantEvents("b1", "b2", "b3")); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Append(ctx, flood); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if keys, _ := scanKeys(t, shared, 0); reflect.DeepEqual(keys[len(keys)-3:], []string{"b3", "b2", "b1"}) {
+		t.Fatal("expected the shared ring to evict tenant-b's events")
+	}
+	keys, _ := scanKeys(t, tenants, 0)
+	if len(keys) != 13 {
+		t.Fatalf("expected 10 of tenant-a's events and 3 of tenant-b's, got %d", len(keys))
+	}
+	if !reflect.DeepEqual(keys[10:], []string{"b3", "b2", "b1"}) {
+		t.Errorf("expected tenant-b's events to survive, oldest last, got %v", keys[10:])
+	}
+	if n := tenants.Dropped(); n != 990 {
+		t.Errorf("expected 990 of tenant-a's events dropped, got %d", n)
+	}
+}
+
+func TestTenantStore_MergedScan(t *testing.T) {
+	st := newTenantStore(storeFields, testMaxEvents)
+	if err := st.Append(context.Background(), tenantEvents("a1", "b1", "c1", "a2", "b2", "c2")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, seqs := scanKeys(t, st, 0)
+	if !reflect.DeepEqual(keys, []string{"c2", "b2", "a2", "c1", "b1", "a1"}) {
+		t.Fatalf("expected the rings merged newest-first, got %v", keys)
+	}
+	if !reflect.DeepEqual(seqs, []int64{6, 5, 4, 3, 2, 1}) {
+		t.Errorf("expected sequence numbers shared across rings, got %v", seqs)
+	}
+	if keys, _ := scanKeys(t, st, 4); !reflect.DeepEqual(keys, []string{"c1", "b1", "a1"}) {
+		t.Errorf("expected events older than a2, got %v", keys)
+	}
+
+	if n, _ := st.Delete(context.Background(), func(ev Event) bool { return ev.tenant() == "tenant-b" }); n != 2 {
+		t.Errorf("expected tenant-b's 2 events deleted, got %d", n)
+	}
+	if _, ok := st.rings["tenant-b"]; ok {
+		t.Error("expected the emptied ring discarded")
+	}
+	if keys, _ := scanKeys(t, st, 0); !reflect.DeepEqual(keys, []string{"c2", "a2", "c1", "a1"}) {
+		t.Errorf("expected the other tenants' events kept, got %v", keys)
+	}
+}
@@ -0,0 +1,532 @@
+package eventcore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const (
+	testMaxEvents       = 10000
+	testInitialCapacity = 1024
+)
+
+// storeFields lets the stores keep Event itself, standing in for the
+// templates' event types.
+var storeFields = Fields[Event]{
+	View:    func(ev Event) Event { return ev },
+	Make:    func(ev Event) Event { return ev },
+	Reasons: true,
+	JSON:    func(ev Event) any { return ev },
+	Encode:  func(ev Event) ([]byte, error) { return json.Marshal(ev) },
+	Decode: func(data []byte) (Event, error) {
+		var ev Event
+		err := json.Unmarshal(data, &ev)
+		return ev, err
+	},
+	Size: func(ev Event) int64 { return int64(len(ev.Key)) },
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// storeBackends lists every Store implementation; each one must pass the
+// same conformance suite.
+var storeBackends = map[string]func(t *testing.T) Store[Event]{
+	"memory": func(*testing.T) Store[Event] { return NewMemoryStore(storeFields, testMaxEvents, 0) },
+	"split": func(*testing.T) Store[Event] {
+		return newSplitStore(storeFields, testMaxEvents, testMaxEvents, testInitialCapacity)
+	},
+	"tenant": func(*testing.T) Store[Event] { return newTenantStore(storeFields, testMaxEvents) },
+	"redis": func(t *testing.T) Store[Event] {
+		st, err := openRedisStore(storeFields, "redis://"+miniredis.RunT(t).Addr(), StoreOptions{MaxEvents: testMaxEvents})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	},
+	"sqlite": func(t *testing.T) Store[Event] {
+		st, err := openSQLiteStore(storeFields, filepath.Join(t.TempDir(), "events.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	},
+}
+
+func forEachStore(t *testing.T, fn func(t *testing.T, st Store[Event])) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			t.Cleanup(func() { st.Close() })
+			fn(t, st)
+		})
+	}
+}
+
+func keyedEvents(keys ...string) []Event {
+	out := make([]Event, len(keys))
+	for i, k := range keys {
+		out[i] = Event{Key: k, Method: "GET", Path: "/", Allowed: true, Timestamp: "2026-02-16T21:00:00Z"}
+	}
+	return out
+}
+
+func scanKeys(t *testing.T, st Store[Event], before int64) ([]string, []int64) {
+	t.Helper()
+	var keys []string
+	var seqs []int64
+	err := st.Scan(context.Background(), before, func(seq int64, ev Event) bool {
+		keys = append(keys, ev.Key)
+		seqs = append(seqs, seq)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, seqs
+}
+
+func TestStore_AppendScanNewestFirst(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		if err := st.Append(ctx, keyedEvents("a", "b")); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Append(ctx, keyedEvents("c")); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := st.Len(ctx)
+		if err != nil || n != 3 {
+			t.Fatalf("expected Len=3, got %d (err=%v)", n, err)
+		}
+		keys, seqs := scanKeys(t, st, 0)
+		if !reflect.DeepEqual(keys, []string{"c", "b", "a"}) {
+			t.Errorf("expected newest-first order, got %v", keys)
+		}
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] >= seqs[i-1] {
+				t.Errorf("expected decreasing sequence numbers, got %v", seqs)
+			}
+		}
+	})
+}
+
+func TestStore_ScanBefore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		if err := st.Append(context.Background(), keyedEvents("a", "b", "c", "d")); err != nil {
+			t.Fatal(err)
+		}
+		_, seqs := scanKeys(t, st, 0)
+
+		keys, _ := scanKeys(t, st, seqs[1])
+		if !reflect.DeepEqual(keys, []string{"b", "a"}) {
+			t.Errorf("expected events older than c, got %v", keys)
+		}
+	})
+}
+
+func TestStore_StaleBeforeRestarts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		if err := st.Append(ctx, keyedEvents("a", "b")); err != nil {
+			t.Fatal(err)
+		}
+		_, seqs := scanKeys(t, st, 0)
+		if err := st.Clear(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Append(ctx, keyedEvents("x", "y")); err != nil {
+			t.Fatal(err)
+		}
+
+		keys, _ := scanKeys(t, st, seqs[0])
+		if !reflect.DeepEqual(keys, []string{"y", "x"}) {
+			t.Errorf("expected stale position to restart at newest, got %v", keys)
+		}
+	})
+}
+
+func TestStore_Clear(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		if err := st.Append(ctx, keyedEvents("a", "b")); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Clear(ctx); err != nil {
+			t.Fatal(err)
+		}
+		n, err := st.Len(ctx)
+		if err != nil || n != 0 {
+			t.Errorf("expected Len=0 after clear, got %d (err=%v)", n, err)
+		}
+		if keys, _ := scanKeys(t, st, 0); len(keys) != 0 {
+			t.Errorf("expected no events after clear, got %v", keys)
+		}
+	})
+}
+
+func TestStore_Prune(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		batch := keyedEvents("old", "bad", "new", "older", "offset")
+		batch[0].Timestamp = "2026-02-16T20:00:00Z"
+		batch[1].Timestamp = "not-a-timestamp"
+		batch[2].Timestamp = "2026-02-16T21:30:00Z"
+		batch[3].Timestamp = "2026-02-16T19:00:00Z"
+		batch[4].Timestamp = "2026-02-16T22:30:00+02:00" // 20:30Z
+		if err := st.Append(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := st.Prune(ctx, time.Date(2026, 2, 16, 21, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 pruned events, got %d", n)
+		}
+		keys, seqs := scanKeys(t, st, 0)
+		if !reflect.DeepEqual(keys, []string{"new", "bad"}) {
+			t.Fatalf("expected new and unparseable events to survive, got %v", keys)
+		}
+		if keys, _ := scanKeys(t, st, seqs[0]); !reflect.DeepEqual(keys, []string{"bad"}) {
+			t.Errorf("expected scan before a surviving event to skip pruned ones, got %v", keys)
+		}
+
+		if err := st.Append(ctx, keyedEvents("later")); err != nil {
+			t.Fatal(err)
+		}
+		if _, newSeqs := scanKeys(t, st, 0); newSeqs[0] <= seqs[0] {
+			t.Errorf("expected sequence numbers to keep increasing after a prune, got %v", newSeqs)
+		}
+	})
+}
+
+func TestStore_Delete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		batch := keyedEvents("a1", "b1", "a2", "c1", "a3")
+		for i := range batch {
+			batch[i].Allowed = i%2 == 0
+		}
+		if err := st.Append(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := st.Delete(ctx, func(ev Event) bool { return strings.HasPrefix(ev.Key, "a") })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 deleted events, got %d", n)
+		}
+		keys, seqs := scanKeys(t, st, 0)
+		if !reflect.DeepEqual(keys, []string{"c1", "b1"}) {
+			t.Fatalf("expected the other events to survive, got %v", keys)
+		}
+		if n, err := st.Delete(ctx, func(Event) bool { return false }); err != nil || n != 0 {
+			t.Errorf("expected nothing deleted, got %d (err=%v)", n, err)
+		}
+
+		if err := st.Append(ctx, keyedEvents("later")); err != nil {
+			t.Fatal(err)
+		}
+		if _, newSeqs := scanKeys(t, st, 0); newSeqs[0] <= seqs[0] {
+			t.Errorf("expected sequence numbers to keep increasing after a delete, got %v", newSeqs)
+		}
+	})
+}
+
+// checkApproxBytes checks that st, a Sizer, reports the total size of the
+// events it holds.
+func checkApproxBytes(t *testing.T, st Store[Event]) {
+	t.Helper()
+	var want int64
+	st.Scan(context.Background(), 0, func(_ int64, ev Event) bool {
+		want += storeFields.Size(ev)
+		return true
+	})
+	got, err := st.(Sizer).ApproxBytes(context.Background())
+	if err != nil || got != want {
+		t.Errorf("expected approx bytes %d, got %d (err=%v)", want, got, err)
+	}
+}
+
+func TestStore_ApproxBytes(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		ctx := context.Background()
+		old := keyedEvents("old")
+		old[0].Timestamp = "2026-02-16T20:00:00Z"
+		if err := st.Append(ctx, append(tenantEvents("a1", "bb1", "ccc1"), old...)); err != nil {
+			t.Fatal(err)
+		}
+		checkApproxBytes(t, st)
+		if _, err := st.Delete(ctx, func(ev Event) bool { return ev.Key == "bb1" }); err != nil {
+			t.Fatal(err)
+		}
+		checkApproxBytes(t, st)
+		if _, err := st.Prune(ctx, time.Date(2026, 2, 16, 20, 30, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		checkApproxBytes(t, st)
+		if err := st.Clear(ctx); err != nil {
+			t.Fatal(err)
+		}
+		checkApproxBytes(t, st)
+	})
+}
+
+func TestMemoryStore_Wraparound(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore(storeFields, 3, 0)
+
+	st.Append(ctx, keyedEvents("a", "b"))
+	st.Append(ctx, keyedEvents("c", "d"))
+	st.Append(ctx, keyedEvents("e"))
+
+	keys, seqs := scanKeys(t, st, 0)
+	if !reflect.DeepEqual(keys, []string{"e", "d", "c"}) {
+		t.Fatalf("expected newest 3 events across the wrap, got %v", keys)
+	}
+	if keys, _ := scanKeys(t, st, seqs[1]); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Errorf("expected scan before d to return c, got %v", keys)
+	}
+	if keys, _ := scanKeys(t, st, 1); !reflect.DeepEqual(keys, []string{"e", "d", "c"}) {
+		t.Errorf("expected overwritten position to restart at newest, got %v", keys)
+	}
+	checkApproxBytes(t, st)
+
+	// Pruning a wrapped ring and filling it again keeps insertion order.
+	evs := keyedEvents("f", "g")
+	evs[0].Timestamp = "2026-02-16T20:00:00Z"
+	st.Append(ctx, evs)
+	st.Prune(ctx, time.Date(2026, 2, 16, 20, 30, 0, 0, time.UTC))
+	st.Append(ctx, keyedEvents("h", "i"))
+	if keys, _ := scanKeys(t, st, 0); !reflect.DeepEqual(keys, []string{"i", "h", "g"}) {
+		t.Errorf("unexpected order after prune and wrap: %v", keys)
+	}
+}
+
+func TestMemoryStore_ClearAfterWrap(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore(storeFields, 3, 0)
+	st.Append(ctx, keyedEvents("a", "b", "c", "d", "e"))
+	st.Clear(ctx)
+	st.Append(ctx, keyedEvents("f", "g", "h", "i"))
+
+	if keys, _ := scanKeys(t, st, 0); !reflect.DeepEqual(keys, []string{"i", "h", "g"}) {
+		t.Errorf("unexpected order: %v", keys)
+	}
+}
+
+func TestMemoryStore_Unlimited(t *testing.T) {
+	st := NewMemoryStore(storeFields, 0, 0)
+	for range 5 {
+		st.Append(context.Background(), keyedEvents("a", "b", "c"))
+	}
+	if n, _ := st.Len(context.Background()); n != 15 {
+		t.Errorf("expected an unlimited store to keep all 15 events, got %d", n)
+	}
+}
+
+func TestMemoryStore_InitialCapacity(t *testing.T) {
+	st := NewMemoryStore(storeFields, 100, 50)
+	for range 5 {
+		st.Append(context.Background(), keyedEvents("a", "b", "c", "d", "e", "f", "g", "h"))
+	}
+	if cap(st.ring) != 50 {
+		t.Errorf("expected the preallocated ring to absorb 40 events without growing, got cap %d", cap(st.ring))
+	}
+	if n, _ := st.Len(context.Background()); n != 40 {
+		t.Errorf("expected 40 stored events, got %d", n)
+	}
+
+	for _, tc := range []struct{ capacity, initial, want int }{
+		{100, 500, 100}, // clamped to capacity
+		{0, 500, 500},   // unlimited store
+		{100, 0, 0},
+		{100, -1, 0},
+	} {
+		if got := cap(NewMemoryStore(storeFields, tc.capacity, tc.initial).ring); got != tc.want {
+			t.Errorf("NewMemoryStore(%d, %d): expected cap %d, got %d", tc.capacity, tc.initial, tc.want, got)
+		}
+	}
+}
+
+// BenchmarkMemoryStoreAppend compares the ring buffer with the reslicing
+// approach it replaced, which kept reallocating its backing array.
+func BenchmarkMemoryStoreAppend(b *testing.B) {
+	batch := keyedEvents("a", "b", "c", "d", "e", "f", "g", "h")
+
+	b.Run("ring", func(b *testing.B) {
+		st := NewMemoryStore(storeFields, testMaxEvents, 0)
+		b.ReportAllocs()
+		for b.Loop() {
+			st.Append(context.Background(), batch)
+		}
+	})
+	b.Run("reslice", func(b *testing.B) {
+		events := make([]Event, 0, 1024)
+		b.ReportAllocs()
+		for b.Loop() {
+			events = append(events, batch...)
+			if len(events) > testMaxEvents {
+				events = events[len(events)-testMaxEvents:]
+			}
+		}
+	})
+}
+
+// BenchmarkMemoryStoreFill fills an empty store to capacity, with and
+// without preallocating its ring.
+func BenchmarkMemoryStoreFill(b *testing.B) {
+	batch := keyedEvents("a", "b", "c", "d", "e", "f", "g", "h")
+	for _, initial := range []int{0, testInitialCapacity, testMaxEvents} {
+		b.Run(fmt.Sprintf("initial=%d", initial), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				st := NewMemoryStore(storeFields, testMaxEvents, initial)
+				for range testMaxEvents / len(batch) {
+					st.Append(context.Background(), batch)
+				}
+			}
+		})
+	}
+}
+
+func TestStore_FieldsPreserved(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st Store[Event]) {
+		want := []Event{
+			{
+				Key:        "key-1",
+				TenantKey:  ptr("tenant-42"),
+				Method:     "DELETE",
+				Path:       "/api/v1/resource/123",
+				Allowed:    false,
+				Remaining:  0,
+				Limit:      100,
+				Timestamp:  "2026-02-16T21:30:00Z",
+				StatusCode: 429,
+				RequestID:  ptr("req-xyz"),
+				Reason:     ptr("tenant_key_rejected"),
+			},
+			{Key: "key-2", Method: "GET", Path: "/", Allowed: true, Remaining: 9, Limit: 10, Timestamp: "ts", StatusCode: 200},
+		}
+		if err := st.Append(context.Background(), want); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []Event
+		st.Scan(context.Background(), 0, func(_ int64, ev Event) bool {
+			got = append([]Event{ev}, got...)
+			return true
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("fields not preserved:\ngot  %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestRegistry_Open(t *testing.T) {
+	reg := NewRegistry(storeFields)
+	openStore := func(spec string, maxEvents, maxDenied, maxPerTenant, initialCapacity int) (Store[Event], error) {
+		return reg.Open(spec, StoreOptions{
+			MaxEvents:       maxEvents,
+			MaxDenied:       maxDenied,
+			MaxPerTenant:    maxPerTenant,
+			InitialCapacity: initialCapacity,
+		})
+	}
+	for _, spec := range []string{"", "memory", "sqlite:" + filepath.Join(t.TempDir(), "events.db")} {
+		st, err := openStore(spec, testMaxEvents, 0, 0, testInitialCapacity)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", spec, err)
+			continue
+		}
+		st.Close()
+	}
+	if _, err := openStore("postgres://localhost", testMaxEvents, 0, 0, testInitialCapacity); err == nil {
+		t.Error("expected error for unknown store")
+	}
+	if st, err := openStore("memory", testMaxEvents, 100, 0, testInitialCapacity); err != nil {
+		t.Errorf("memory with a denied cap: unexpected error: %v", err)
+	} else if _, ok := st.(*splitStore[Event]); !ok {
+		t.Errorf("memory with a denied cap: expected a split store, got %T", st)
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "split.db"), testMaxEvents, 100, 0, testInitialCapacity); err == nil {
+		t.Error("expected error for a denied cap on sqlite")
+	}
+	if st, err := openStore("memory", testMaxEvents, 0, 100, testInitialCapacity); err != nil {
+		t.Errorf("memory with a per-tenant cap: unexpected error: %v", err)
+	} else if _, ok := st.(*tenantStore[Event]); !ok {
+		t.Errorf("memory with a per-tenant cap: expected a tenant store, got %T", st)
+	}
+	if _, err := openStore("memory", testMaxEvents, 100, 100, testInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap combined with a denied cap")
+	}
+	if _, err := openStore("sqlite:"+filepath.Join(t.TempDir(), "tenant.db"), testMaxEvents, 0, 100, testInitialCapacity); err == nil {
+		t.Error("expected error for a per-tenant cap on sqlite")
+	}
+}
+
+func TestSQLiteStore_AddsColumns(t *testing.T) {
+	// The schema the gRPC template used before events carried a reason.
+	path := filepath.Join(t.TempDir(), "events.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE events (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	key         TEXT    NOT NULL,
+	tenant_key  TEXT    NOT NULL,
+	method      TEXT    NOT NULL,
+	path        TEXT    NOT NULL,
+	allowed     INTEGER NOT NULL,
+	remaining   INTEGER NOT NULL,
+	"limit"     INTEGER NOT NULL,
+	timestamp   TEXT    NOT NULL,
+	status_code INTEGER NOT NULL,
+	request_id  TEXT    NOT NULL
+)`)
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO events (key, tenant_key, method, path, allowed, remaining, "limit", timestamp, status_code, request_id)
+VALUES ('old', 't', 'GET', '/', 1, 0, 0, '2026-02-16T21:00:00Z', 200, 'r')`)
+	}
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := openSQLiteStore(storeFields, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	ev := keyedEvents("new")[0]
+	ev.TenantKey, ev.RequestID, ev.Reason = ptr("t"), ptr("r"), ptr("tenant_key_rejected")
+	if err := st.Append(context.Background(), []Event{ev}); err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	st.Scan(context.Background(), 0, func(_ int64, ev Event) bool {
+		reasons = append(reasons, deref(ev.Reason))
+		return true
+	})
+	if !reflect.DeepEqual(reasons, []string{"tenant_key_rejected", ""}) {
+		t.Errorf("expected the new event's reason and none for the old one, got %q", reasons)
+	}
+	// The old event's size is filled in when the column is added.
+	if n, err := st.ApproxBytes(context.Background()); err != nil || n != int64(len("old")+len("new")) {
+		t.Errorf("expected both events sized, got %d (err=%v)", n, err)
+	}
+}
@@ -0,0 +1,219 @@
+package eventcore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultTopN is how many entries the top endpoints return without n.
+	defaultTopN = 10
+	// maxTopN caps n for the top endpoints.
+	maxTopN = 1000
+)
+
+// EventCounts breaks a group of events down by decision.
+type EventCounts struct {
+	Count   int64 `json:"count"`
+	Allowed int64 `json:"allowed"`
+	Denied  int64 `json:"denied"`
+}
+
+// TopPath is an entry of GET /events/top/paths.
+type TopPath struct {
+	Path string `json:"path"`
+	EventCounts
+}
+
+// TopKey is an entry of GET /events/top/keys.
+type TopKey struct {
+	Key string `json:"key"`
+	EventCounts
+}
+
+// eventGroup is a group of events sharing the value rankEvents grouped
+// them by.
+type eventGroup struct {
+	value string
+	EventCounts
+}
+
+// normalizePath collapses the path segments that are usually resource IDs
+// into placeholders: all-digit segments become {id} and UUIDs become
+// {uuid}. Other segments are kept as they are.
+func normalizePath(path string) string {
+	segs := strings.Split(path, "/")
+	changed := false
+	for i, seg := range segs {
+		switch {
+		case isNumeric(seg):
+			segs[i] = "{id}"
+		case isUUID(seg):
+			segs[i] = "{uuid}"
+		default:
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return path
+	}
+	return strings.Join(segs, "/")
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether s is a UUID in its canonical 8-4-4-4-12 hex form,
+// in either case.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := range len(s) {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			c := s[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseTopN parses the n parameter of the top endpoints.
+func parseTopN(v string) (int, error) {
+	if v == "" {
+		return defaultTopN, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid n parameter %q: must be a positive integer", v)
+	}
+	return min(n, maxTopN), nil
+}
+
+// rankEvents groups the stored events matching filter by groupBy in a
+// single scan and returns the n largest groups, by count, then denials,
+// then value.
+func (s *Service[E]) rankEvents(ctx context.Context, filter Filter, n int, groupBy func(Event) string) ([]eventGroup, error) {
+	counts := make(map[string]EventCounts)
+	err := s.Scan(ctx, filter, 0, func(_ int64, e E) bool {
+		ev := s.fields.View(e)
+		if !filter.Match(ev) {
+			return true
+		}
+		value := groupBy(ev)
+		c := counts[value]
+		c.Count++
+		if ev.Allowed {
+			c.Allowed++
+		} else {
+			c.Denied++
+		}
+		counts[value] = c
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]eventGroup, 0, len(counts))
+	for value, c := range counts {
+		groups = append(groups, eventGroup{value: value, EventCounts: c})
+	}
+	slices.SortFunc(groups, func(a, b eventGroup) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			cmp.Compare(b.Denied, a.Denied),
+			cmp.Compare(a.value, b.value),
+		)
+	})
+	return groups[:min(n, len(groups))], nil
+}
+
+// topGroups answers a top endpoint's request up to ranking: it parses the
+// filters and n, and ranks the matching events by groupBy. It writes the
+// error response itself and returns false if any step fails; noun names
+// what is ranked in the error.
+func (s *Service[E]) topGroups(w http.ResponseWriter, r *http.Request, noun string, groupBy func(Event) string) ([]eventGroup, bool) {
+	q := r.URL.Query()
+	filter, err := ParseFilter(q)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	n, err := parseTopN(q.Get("n"))
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	groups, err := s.rankEvents(r.Context(), filter, n, groupBy)
+	if err != nil {
+		s.logger.Error("failed to rank "+noun, "error", err)
+		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to rank " + noun})
+		return nil, false
+	}
+	return groups, true
+}
+
+// HandleTopPaths returns the n (default 10) paths with the most stored
+// events matching the usual filters, with each one's allowed and denied
+// counts. With normalize=true, paths are grouped by normalizePath, so
+// /users/1 and /users/2 count together as /users/{id}.
+func (s *Service[E]) HandleTopPaths(w http.ResponseWriter, r *http.Request) {
+	groupBy := func(ev Event) string { return ev.Path }
+	if v := r.URL.Query().Get("normalize"); v != "" {
+		normalize, err := strconv.ParseBool(v)
+		if err != nil {
+			WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid normalize parameter %q", v)})
+			return
+		}
+		if normalize {
+			groupBy = func(ev Event) string { return normalizePath(ev.Path) }
+		}
+	}
+	groups, ok := s.topGroups(w, r, "paths", groupBy)
+	if !ok {
+		return
+	}
+	paths := make([]TopPath, len(groups))
+	for i, g := range groups {
+		paths[i] = TopPath{Path: g.value, EventCounts: g.EventCounts}
+	}
+	WriteJSON(w, http.StatusOK, paths)
+}
+
+// HandleTopKeys returns the n (default 10) rate limit keys, typically
+// client IPs, with the most stored events matching the usual filters, with
+// each one's allowed and denied counts. Among keys with equal traffic the
+// one with more denials comes first.
+func (s *Service[E]) HandleTopKeys(w http.ResponseWriter, r *http.Request) {
+	groups, ok := s.topGroups(w, r, "keys", func(ev Event) string { return ev.Key })
+	if !ok {
+		return
+	}
+	keys := make([]TopKey, len(groups))
+	for i, g := range groups {
+		keys[i] = TopKey{Key: g.value, EventCounts: g.EventCounts}
+	}
+	WriteJSON(w, http.StatusOK, keys)
+}
@@ -0,0 +1,25 @@
+package eventcore
+
+import "testing"
+
+func TestNormalizePath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/users/123":             "/users/{id}",
+		"/users/123/orders/0042": "/users/{id}/orders/{id}",
+		"/orders/550e8400-e29b-41d4-a716-446655440000":           "/orders/{uuid}",
+		"/orders/550E8400-E29B-41D4-A716-446655440000/items":     "/orders/{uuid}/items",
+		"/users/7/sessions/550e8400-e29b-41d4-a716-446655440000": "/users/{id}/sessions/{uuid}",
+		"/api/v1/users": "/api/v1/users",
+		"/users/abc123": "/users/abc123",
+		"/users/12.5":   "/users/12.5",
+		"/orders/550e8400e29b41d4a716446655440000":     "/orders/550e8400e29b41d4a716446655440000",
+		"/orders/550e8400-e29b-41d4-a716-44665544000g": "/orders/550e8400-e29b-41d4-a716-44665544000g",
+		"/":     "/",
+		"":      "",
+		"/123/": "/{id}/",
+	} {
+		if got := normalizePath(path); got != want {
+			t.Errorf("normalizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
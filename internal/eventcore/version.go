@@ -0,0 +1,29 @@
+package eventcore
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build information, set with
+// -ldflags "-X $PKG.Version=v1.2.3 -X $PKG.Commit=abc1234", where $PKG is
+// github.com/edgequota/external-events-template/internal/eventcore.
+var (
+	Version = "dev"
+	Commit  = "dev"
+)
+
+type versionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// HandleVersion reports which build is running.
+func HandleVersion(w http.ResponseWriter, _ *http.Request) {
+	WriteJSON(w, http.StatusOK, versionResponse{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	})
+}
@@ -0,0 +1,51 @@
+package eventcore
+
+import (
+	"context"
+	"fmt"
+)
+
+// WatermarkRetryAfter is the Retry-After hint, in seconds, given to
+// publishers while the store is above a watermark.
+const WatermarkRetryAfter = "5"
+
+// Watermarks holds the backpressure thresholds of a Service. Once the
+// store holds a Soft fraction of Capacity, publishes still succeed but
+// carry a warning; at Hard, they are refused until it drains. A zero
+// fraction disables that watermark.
+type Watermarks struct {
+	Capacity   int
+	Soft, Hard float64
+}
+
+// fillLevel returns stored as a fraction of capacity, or nil when w is nil.
+func (w *Watermarks) fillLevel(stored int) *float64 {
+	if w == nil {
+		return nil
+	}
+	level := float64(stored) / float64(w.Capacity)
+	return &level
+}
+
+// CheckWatermarks compares the store's fill level against the watermarks.
+// It reports whether the hard watermark has been reached, and otherwise
+// returns a warning once the soft one has. Failing to count the store
+// isn't treated as pressure.
+func (s *Service[E]) CheckWatermarks(ctx context.Context) (full bool, warning string) {
+	if s.cfg.Watermarks == nil {
+		return false, ""
+	}
+	n, err := s.storage.Len(ctx)
+	if err != nil {
+		s.logger.Warn("failed to count events for backpressure", "error", err)
+		return false, ""
+	}
+	level := *s.cfg.Watermarks.fillLevel(n)
+	switch {
+	case s.cfg.Watermarks.Hard > 0 && level >= s.cfg.Watermarks.Hard:
+		return true, ""
+	case s.cfg.Watermarks.Soft > 0 && level >= s.cfg.Watermarks.Soft:
+		return false, fmt.Sprintf("event store is %.0f%% full, slow down", level*100)
+	}
+	return false, ""
+}